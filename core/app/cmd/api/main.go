@@ -3,11 +3,14 @@ package main
 import (
 	"core/config"
 	db "core/internal/adapters/database"
+	"core/internal/adapters/database/models"
 	routes "core/internal/adapters/http"
 	"core/internal/adapters/http/controllers"
 	"core/internal/adapters/http/middleware"
 	"core/internal/adapters/memory_storage"
+	"core/internal/adapters/ws"
 	"core/internal/core"
+	"core/internal/core/bots"
 	"core/internal/core/services"
 	ports "core/internal/ports"
 	"core/types"
@@ -25,6 +28,12 @@ func main() {
 		log.Fatalf("Failed to load .env file: %v", err)
 	}
 
+	// * initialize jwt signing keys
+	if err := core.InitKeyring(); err != nil {
+		log.Fatalf("Failed to initialize jwt keys: %v\n", err)
+		return
+	}
+
 	// * initialize database
 	db, err := db.New()
 	if err != nil {
@@ -47,30 +56,77 @@ func main() {
 	// * initialize logger
 	loggerService := core.NewLogger()
 
+	// * initialize mailer
+	mailer := core.NewMailer(loggerService)
+
 	// * initialize services
-	userService := services.NewUserService(loggerService, &repos.User)
+	userService := services.NewUserService(loggerService, &repos.User, &repos.Avatar, &repos.RefreshToken, &repos.Friendship)
+	accountService := services.NewAccountService(loggerService, mailer, &repos.User, &repos.UserToken, &repos.RefreshToken)
+	friendService := services.NewFriendService(loggerService, &repos.Friendship, &repos.User)
+	announcementService := services.NewAnnouncementService(loggerService, &repos.Announcement)
+	moderationService := services.NewModerationService(loggerService, &repos.Report, &repos.User, &repos.RefreshToken, &repos.IPBan)
 	// ... add more
 
 	// * initialize controllers
-	userController := controllers.NewUserController(userService)
+	userController := controllers.NewUserController(userService, accountService)
+	friendController := controllers.NewFriendController(friendService)
+	reportController := controllers.NewReportController(moderationService)
+	adminController := controllers.NewAdminController(moderationService, announcementService)
+	wsHandler := ws.NewWebSocketHandler(userService, friendService, moderationService)
 	// ... add more
 
+	// * listen to the messages sent to every node
+	if err := ws.ListenControl(); err != nil {
+		log.Fatalf("Failed to listen to control messages: %v\n", err)
+		return
+	}
+
+	// * give the admin role to the accounts of ADMIN_EMAILS and load the ip bans
+	moderationService.BootstrapAdmins()
+	if err := moderationService.SyncIPBans(); err != nil {
+		log.Fatalf("Failed to load ip bans: %v\n", err)
+		return
+	}
+
+	// * purge the deleted accounts once their grace period is over
+	go accountService.StartAccountPurge()
+
+	// * send the scheduled announcements
+	go announcementService.StartScheduler()
+
+	// * gives the queue slots that weren't used in time to the next users
+	go services.StartQueueSweeper()
+
+	// * attach the bots of ROOM_BOTS to their rooms
+	bots.Start()
+
 	// controllers := types.Controllers{User: userController, Room: roomController}
 
 	// * initialize middlewares
 	middlewares := types.Middlewares{
-		Auth: middleware.NewAuthMiddleware(&repos.User).Authenticate,
-		CSRF: middleware.ValidateCSRFToken(),
+		Auth:      middleware.NewAuthMiddleware(&repos.User, &repos.RefreshToken).Authenticate,
+		CSRF:      middleware.ValidateCSRFToken(),
+		Verified:  middleware.RequireVerified(),
+		Moderator: middleware.RequireRole(models.RoleModerator),
+		Admin:     middleware.RequireRole(models.RoleAdmin),
 	}
 
 	gin.SetMode(config.GinMode)
 	server := gin.New()
+
+	// * the client IP keys the login lockout and the IP bans, it's only read
+	// * from X-Forwarded-For when the request comes from a trusted proxy
+	if err := server.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+
 	globalMiddlewares := []gin.HandlerFunc{
 		config.SetupCors(),
+		middleware.RejectBannedIPs(moderationService),
 	}
 
 	server.Use(globalMiddlewares...)
-	routes.SetupRoutes(server, userController, middlewares)
+	routes.SetupRoutes(server, userController, friendController, reportController, adminController, wsHandler, middlewares)
 
 	if err := server.Run(":" + config.PORT); err != nil {
 		log.Fatal("Failed to serve", err)
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type databaseConfig struct {
@@ -14,18 +15,59 @@ type databaseConfig struct {
 	UseSSL   bool
 }
 
+type smtpConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
 var (
 	AppName            = "ghoulies"
 	GinMode            = os.Getenv("GIN_MODE") // server debug/prod mode
 	AllowOrigins       = os.Getenv("ALLOWED_ORIGINS")
+	TrustedProxies     = listEnv("TRUSTED_PROXIES") // * IPs or CIDRs allowed to set X-Forwarded-For, none when empty
 	PORT               = os.Getenv("PORT")
 	JwtSecret          = os.Getenv("JWT_SECRET")
-	ChatbotName        = os.Getenv("CHATBOT_NAME")
+	JwtKeys            = os.Getenv("JWT_KEYS")         // * "kid:secret,kid:secret" HMAC keys
+	JwtEd25519Keys     = os.Getenv("JWT_ED25519_KEYS") // * "kid:base64 seed,..." Ed25519 keys
+	JwtActiveKey       = os.Getenv("JWT_ACTIVE_KEY")   // * kid used to sign new tokens
+	JwtIssuer          = stringEnv("JWT_ISSUER", AppName)
+	JwtAudience        = stringEnv("JWT_AUDIENCE", AppName)
+	ChatbotName        = stringEnv("CHATBOT_NAME", AppName) // sender of the system messages
 	WelcomeRoomName    = os.Getenv("WELCOME_ROOM_NAME")
 	RedisServer        = os.Getenv("REDIS_SERVER")
 	RedisPassword      = os.Getenv("REDIS_PASSWORD")
 	WsConnectionsLimit = os.Getenv("WSCONN_LIMIT")
 
+	// * websocket upgrader
+	WsReadLimit        = intEnv("WS_READ_LIMIT", 8192) // max bytes per message sent by a client
+	WsReadBufferSize   = intEnv("WS_READ_BUFFER_SIZE", 1024)
+	WsWriteBufferSize  = intEnv("WS_WRITE_BUFFER_SIZE", 1024)
+	WsCompression, _   = strconv.ParseBool(os.Getenv("WS_COMPRESSION")) // permessage-deflate
+	WsCompressionLevel = intEnv("WS_COMPRESSION_LEVEL", 1)
+
+	// * days a deleted account is kept before it's purged
+	AccountDeletionGraceDays = intEnv("ACCOUNT_DELETION_GRACE_DAYS", 7)
+
+	// * system messages, {room} and {user} are replaced in the welcome text
+	RoomWelcomeText = stringEnv("ROOM_WELCOME_TEXT", "Welcome to {room}, {user}!")
+
+	// * bots attached on startup, "name" goes to the welcome room and
+	// * "name@room id" to another room, e.g. "greeter,greeter@lobby#0"
+	RoomBots = os.Getenv("ROOM_BOTS")
+
+	// * moderation
+	AdminEmails            = listEnv("ADMIN_EMAILS")                // accounts promoted to admin on startup
+	RoomHistoryLimit       = intEnv("ROOM_HISTORY_LIMIT", 50)       // messages kept per room
+	ReportEvidenceMessages = intEnv("REPORT_EVIDENCE_MESSAGES", 20) // last messages attached to a report
+
+	// * mail
+	AppUrl      = stringEnv("APP_URL", "http://localhost:3000") // used to build the links sent by mail
+	MailDriver  = stringEnv("MAIL_DRIVER", "log")               // smtp or log
+	MailFrom    = stringEnv("MAIL_FROM", "no-reply@localhost")
+	MailLogFile = os.Getenv("MAIL_LOG_FILE") // log mailer output, the logger when empty
+
 	sslFlag, _ = strconv.ParseBool(os.Getenv("SSL"))
 
 	Database = databaseConfig{
@@ -36,4 +78,44 @@ var (
 		Password: os.Getenv("POSTGRES_PASS"),
 		UseSSL:   sslFlag,
 	}
+
+	Smtp = smtpConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     stringEnv("SMTP_PORT", "587"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
 )
+
+// stringEnv reads an environment variable, using fallback when it's missing
+func stringEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// intEnv reads an integer environment variable, using fallback when it's
+// missing or malformed
+func intEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// listEnv reads a comma separated environment variable, the values are
+// trimmed and lowercased
+func listEnv(key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
@@ -25,7 +25,7 @@ func New() (*gorm.DB, error) {
 
 	fmt.Printf("Database connection established sslmode=%s\n", sslMode)
 
-	dbModels := []interface{}{&models.User{}}
+	dbModels := []interface{}{&models.User{}, &models.Avatar{}, &models.RefreshToken{}, &models.UserToken{}, &models.Friendship{}, &models.Report{}, &models.IPBan{}, &models.Announcement{}}
 
 	fmt.Printf("Auto-migrating database models")
 
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Announcement is a system message sent at SendAt, to a room or to every room
+// when RoomID is empty. Repeating announcements are sent every Interval
+// minutes until they are deleted.
+type Announcement struct {
+	gorm.Model
+	Message    string
+	RoomID     string
+	SendAt     time.Time `gorm:"index"`
+	Interval   int       // * minutes, 0 is sent once
+	CreatedBy  uint
+	LastSentAt *time.Time
+	Done       bool `gorm:"index"`
+}
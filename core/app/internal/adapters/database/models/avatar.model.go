@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Avatar struct {
+	gorm.Model
+	UserID         uint `gorm:"uniqueIndex"`
+	Body           string
+	PrimaryColor   string
+	SecondaryColor string
+	Accessories    []string `gorm:"serializer:json"`
+}
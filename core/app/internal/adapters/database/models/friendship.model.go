@@ -0,0 +1,28 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+	FriendshipBlocked  = "blocked"
+)
+
+// Friendship links two users. A pending friendship is a request from
+// RequesterID to AddresseeID, a blocked one is RequesterID blocking
+// AddresseeID. There is at most one friendship per pair of users.
+type Friendship struct {
+	gorm.Model
+	RequesterID uint   `gorm:"uniqueIndex:idx_friendship_pair"`
+	AddresseeID uint   `gorm:"uniqueIndex:idx_friendship_pair;index"`
+	Status      string `gorm:"index"`
+}
+
+// Other returns the user of the friendship that isn't userId
+func (f *Friendship) Other(userId uint) uint {
+	if f.RequesterID == userId {
+		return f.AddresseeID
+	}
+
+	return f.RequesterID
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IPBan rejects every request and connection from the IP, the ban is
+// permanent when ExpiresAt is nil
+type IPBan struct {
+	gorm.Model
+	IP        string `gorm:"uniqueIndex"`
+	Reason    string
+	BannedBy  uint
+	ExpiresAt *time.Time
+}
+
+func (ban *IPBan) IsActive() bool {
+	return ban.ExpiresAt == nil || ban.ExpiresAt.After(time.Now())
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an issued refresh token, tokens rotated from the same
+// login share the SessionID
+type RefreshToken struct {
+	gorm.Model
+	JTI        string `gorm:"uniqueIndex"`
+	UserID     uint   `gorm:"index"`
+	SessionID  string `gorm:"index"`
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string // * jti of the token issued when this one was rotated
+}
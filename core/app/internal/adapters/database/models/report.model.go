@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportReviewing = "reviewing"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Report is a complaint about a user of a room. Evidence is the room history
+// at the time of the report, reported guests have no account.
+type Report struct {
+	gorm.Model
+	ReporterID        uint   `gorm:"index"`
+	ReportedAccountID uint   `gorm:"index"`
+	ReportedUserID    string // * websocket user id
+	ReportedUsername  string
+	RoomID            string
+	RoomName          string
+	Reason            string
+	Details           string
+	Evidence          string `gorm:"type:jsonb"`
+	Status            string `gorm:"index"`
+	ReviewerID        uint
+	Note              string // * written by the reviewer
+	ResolvedAt        *time.Time
+}
+
+func (report *Report) IsClosed() bool {
+	return report.Status == ReportResolved || report.Status == ReportDismissed
+}
@@ -1,12 +1,55 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles, a role has the permissions of the lower ones
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
 type User struct {
 	gorm.Model
-	Email    string `gorm:"unique"`
-	Username string `gorm:"unique"`
-	Password string
+	Email           string `gorm:"unique"`
+	Username        string `gorm:"unique"`
+	Password        string
+	EmailVerifiedAt *time.Time
+	BannedAt        *time.Time
+	BanReason       string
+	Role            string `gorm:"default:user;not null"`
+}
+
+func (user *User) IsVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+func (user *User) IsBanned() bool {
+	return user.BannedAt != nil
+}
+
+// HasRole reports whether the user has the role or a higher one
+func (user *User) HasRole(role string) bool {
+	return RoleAtLeast(user.Role, role)
+}
+
+// RoleAtLeast reports whether role is min or a higher one, unknown roles
+// rank as users
+func RoleAtLeast(role string, min string) bool {
+	return roleRanks[role] >= roleRanks[min]
+}
+
+func IsValidRole(role string) bool {
+	_, exists := roleRanks[role]
+	return exists
 }
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"core/config"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerifyEmailRequestBody struct {
+	Token string `json:"token" binding:"required" example:"mZ3k9y0gk1p8H4m7..."`
+}
+
+type DeleteAccountRequestBody struct {
+	Password string `json:"password" binding:"required,max=72" example:"+5tRonG_P455w0rd_"`
+}
+
+type ForgotPasswordRequestBody struct {
+	Email string `json:"email" binding:"required,email" example:"alice@wonderland.tld"`
+}
+
+type ResetPasswordRequestBody struct {
+	Token    string `json:"token" binding:"required" example:"mZ3k9y0gk1p8H4m7..."`
+	Password string `json:"password" binding:"required,min=6,max=72" example:"+5tRonG_P455w0rd_"`
+}
+
+// Verify Email
+// @Summary Verify the email with the token sent by mail
+//
+//	@Tags         user
+//
+// @Param        body  body  VerifyEmailRequestBody  true  "Verification token"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/verify  [post]
+func (services *UserController) VerifyEmail(c *gin.Context) {
+	var reqBody VerifyEmailRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Account.Verify(reqBody.Token); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Resend Verification
+// @Summary Send a new verification mail
+//
+//	@Tags         user
+//
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/verify/resend  [post]
+func (services *UserController) ResendVerification(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Account.RequestVerification(userPtr); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Forgot Password
+// @Summary Mail a password reset link
+//
+//	@Description  Always succeeds, whether the email is registered or not
+//	@Tags         user
+//
+// @Param        body  body  ForgotPasswordRequestBody  true  "Account email"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/forgot-password  [post]
+func (services *UserController) ForgotPassword(c *gin.Context) {
+	var reqBody ForgotPasswordRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	services.Account.ForgotPassword(reqBody.Email)
+
+	c.Status(http.StatusOK)
+}
+
+// Reset Password
+// @Summary Set a new password with the token sent by mail
+//
+//	@Description  Logs out every session of the user
+//	@Tags         user
+//
+// @Param        body  body  ResetPasswordRequestBody  true  "Reset token and new password"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/reset-password  [post]
+func (services *UserController) ResetPassword(c *gin.Context) {
+	var reqBody ResetPasswordRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Account.ResetPassword(reqBody.Token, reqBody.Password); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.Status(http.StatusOK)
+}
+
+// Delete Account
+// @Summary Delete the user's account
+//
+//	@Description  Needs the password again. The account is disabled right away, logged out everywhere and purged after a grace period
+//	@Tags         user
+//
+// @Param        body  body  DeleteAccountRequestBody  true  "Current password"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      429  {object}  types.ErrorResponse "Locked out after too many failed attempts"
+// @Router /api/v1/user  [delete]
+func (services *UserController) DeleteAccount(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody DeleteAccountRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Account.DeleteAccount(userPtr, reqBody.Password, c.ClientIP()); err != nil {
+		abortWithError(c, loginErrorStatus(err), err)
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.Status(http.StatusOK)
+}
+
+// Export Account
+// @Summary Download everything stored about the user
+//
+//	@Description  Profile, avatar, owned rooms and friends as a JSON archive
+//	@Tags         user
+//
+// @Produce      json
+// @Success      200  {object}  services.AccountExport "Success response"
+// @Failure      500  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/export  [get]
+func (services *UserController) ExportAccount(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	export, err := services.User.ExportAccount(userPtr.ID)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-account-%d.json", config.AppName, userPtr.ID)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.IndentedJSON(http.StatusOK, export)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve runs the handler on a request with the body, user is what
+// Authenticate leaves in the context when it's not nil
+func serve(t *testing.T, method string, handler gin.HandlerFunc, user *models.User, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Handle(method, "/", func(c *gin.Context) {
+		if user != nil {
+			c.Set("user", user)
+		}
+	}, handler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, "/", strings.NewReader(body)))
+
+	return recorder
+}
+
+func decodeError(t *testing.T, recorder *httptest.ResponseRecorder) types.ErrorBody {
+	t.Helper()
+
+	var response types.ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid error body %q: %v", recorder.Body.String(), err)
+	}
+
+	return response.Error
+}
+
+func TestDeleteAccountRequest(t *testing.T) {
+	user := &models.User{Email: "alice@wonderland.tld"}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields map[string]string
+	}{
+		{name: "signed out", body: `{"password":"secret"}`, wantStatus: http.StatusUnauthorized, wantCode: types.ErrorCodeUnauthorized},
+		{name: "no password", user: user, body: `{}`, wantStatus: http.StatusBadRequest, wantCode: types.ErrorCodeValidation, wantFields: map[string]string{"password": "is required"}},
+		{name: "password too long", user: user, body: `{"password":"` + strings.Repeat("a", 73) + `"}`, wantStatus: http.StatusBadRequest, wantCode: types.ErrorCodeValidation, wantFields: map[string]string{"password": "must be no longer than 72 characters"}},
+		{name: "no body", user: user, body: ``, wantStatus: http.StatusBadRequest, wantCode: types.ErrorCodeBadRequest},
+	}
+
+	controller := &UserController{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(t, http.MethodDelete, controller.DeleteAccount, tt.user, tt.body)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			body := decodeError(t, recorder)
+			if body.Code != tt.wantCode {
+				t.Errorf("code %q, want %q", body.Code, tt.wantCode)
+			}
+
+			for field, message := range tt.wantFields {
+				if body.Fields[field] != message {
+					t.Errorf("field %s: %q, want %q", field, body.Fields[field], message)
+				}
+			}
+
+			if cookies := recorder.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("a failed deletion must keep the session, got cookies %v", cookies)
+			}
+		})
+	}
+}
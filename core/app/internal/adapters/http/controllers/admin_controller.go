@@ -0,0 +1,595 @@
+package controllers
+
+import (
+	"core/internal/core/services"
+	"core/types"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrorInvalidAnnouncementId = errors.New("invalid announcement id")
+)
+
+type AdminController struct {
+	Moderation    *services.ModerationService
+	Announcements *services.AnnouncementService
+}
+
+func NewAdminController(moderationService *services.ModerationService, announcementService *services.AnnouncementService) *AdminController {
+	return &AdminController{
+		Moderation:    moderationService,
+		Announcements: announcementService,
+	}
+}
+
+type ResolveReportRequestBody struct {
+	Status string `json:"status" binding:"required,oneof=resolved dismissed" example:"resolved"`
+	Action string `json:"action" binding:"omitempty,oneof=none ban" example:"ban"`
+	Note   string `json:"note" binding:"max=500" example:"insults in the evidence"`
+}
+
+type BanRequestBody struct {
+	Reason string `json:"reason" binding:"required,max=200" example:"spam"`
+}
+
+type SetRoleRequestBody struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin" example:"moderator"`
+}
+
+type CloseRoomRequestBody struct {
+	RoomId string `json:"roomId" binding:"required" example:"keep the block hot#334288"`
+	Reason string `json:"reason" binding:"max=200" example:"spam room"`
+}
+
+type BanIPRequestBody struct {
+	IP              string `json:"ip" binding:"required,ip" example:"203.0.113.7"`
+	Reason          string `json:"reason" binding:"required,max=200" example:"ban evasion"`
+	DurationMinutes int    `json:"durationMinutes" binding:"min=0" example:"1440"` // * 0 is permanent
+}
+
+type AnnouncementRequestBody struct {
+	Message string `json:"message" binding:"required,max=200" example:"the server restarts in 5 minutes"`
+	RoomId  string `json:"roomId" example:"keep the block hot#334288"` // * every room when empty
+}
+
+type ScheduleAnnouncementRequestBody struct {
+	Message  string `json:"message" binding:"required,max=200" example:"remember to be nice"`
+	RoomId   string `json:"roomId" example:"keep the block hot#334288"`  // * every room when empty
+	SendAt   int64  `json:"sendAt" binding:"min=0" example:"1718000000"` // * timestamp, now when 0
+	Interval int    `json:"interval" binding:"min=0" example:"60"`       // * minutes, 0 is sent once
+}
+
+type WelcomeTextRequestBody struct {
+	RoomId string `json:"roomId" binding:"required" example:"keep the block hot#334288"`
+	Text   string `json:"text" binding:"max=200" example:"Welcome to {room}, {user}! Keep it hot"`
+}
+
+func announcementIdParam(c *gin.Context) (uint, bool) {
+	announcementId, err := strconv.ParseUint(c.Param("announcementId"), 10, 64)
+	if err != nil || announcementId == 0 {
+		abortWithError(c, http.StatusBadRequest, ErrorInvalidAnnouncementId)
+		return 0, false
+	}
+
+	return uint(announcementId), true
+}
+
+// List Reports
+// @Summary List the reports, oldest first
+//
+//	@Tags         admin
+//
+// @Param        status  query  string  false  "open, reviewing, resolved or dismissed, every report when empty"
+// @Param        page    query  int     false  "Page number, 20 reports per page"
+// @Success      200  {object}  services.ReportsPage "Success response"
+// @Failure      403  {object}  types.ErrorResponse "Not an admin"
+// @Router /api/v1/admin/reports  [get]
+func (services *AdminController) ListReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+
+	reports, err := services.Moderation.ListReports(c.Query("status"), page)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// Get Report
+// @Summary Get a report with its evidence
+//
+//	@Tags         admin
+//
+// @Param        reportId  path  int  true  "Report id"
+// @Success      200  {object}  services.ReportResponse "Success response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/reports/{reportId}  [get]
+func (services *AdminController) GetReport(c *gin.Context) {
+	reportId, ok := reportIdParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.Moderation.GetReport(reportId)
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Review Report
+// @Summary Assign the report to the admin, its status becomes reviewing
+//
+//	@Tags         admin
+//
+// @Param        reportId  path  int  true  "Report id"
+// @Success      200  {object}  services.ReportResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/reports/{reportId}/review  [post]
+func (services *AdminController) ReviewReport(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	reportId, ok := reportIdParam(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.Moderation.ReviewReport(reportId, userPtr.ID)
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Resolve Report
+// @Summary Close the report, the "ban" action bans the reported account
+//
+//	@Tags         admin
+//
+// @Param        reportId  path  int                       true  "Report id"
+// @Param        body      body  ResolveReportRequestBody  true  "Resolution"
+// @Success      200  {object}  services.ReportResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/reports/{reportId}/resolve  [post]
+func (services *AdminController) ResolveReport(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	reportId, ok := reportIdParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody ResolveReportRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	report, err := services.Moderation.ResolveReport(reportId, userPtr.ID, resolution(reqBody))
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Ban Account
+// @Summary Ban the account, its sessions are revoked and its connections closed
+//
+//	@Tags         admin
+//
+// @Param        accountId  path  int             true  "Account id"
+// @Param        body       body  BanRequestBody  true  "Reason of the ban"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/users/{accountId}/ban  [post]
+func (services *AdminController) BanAccount(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody BanRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Moderation.Ban(userPtr.ID, accountId, reqBody.Reason); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Unban Account
+// @Summary Lift the ban of the account
+//
+//	@Tags         admin
+//
+// @Param        accountId  path  int  true  "Account id"
+// @Success      200
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/users/{accountId}/ban  [delete]
+func (services *AdminController) UnbanAccount(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Moderation.Unban(userPtr.ID, accountId); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Kick Account
+// @Summary Log the account out of every session and close its connections
+//
+//	@Tags         admin
+//
+// @Param        accountId  path  int  true  "Account id"
+// @Success      200
+// @Failure      403  {object}  types.ErrorResponse "The account is a moderator or an admin"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/users/{accountId}/kick  [post]
+func (services *AdminController) KickAccount(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Moderation.Kick(userPtr.ID, accountId); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Set Role
+// @Summary Change the role of the account, admin only
+//
+//	@Tags         admin
+//
+// @Param        accountId  path  int                 true  "Account id"
+// @Param        body       body  SetRoleRequestBody  true  "New role"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/users/{accountId}/role  [put]
+func (services *AdminController) SetRole(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody SetRoleRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Moderation.SetRole(userPtr.ID, accountId, reqBody.Role); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// List Live Rooms
+// @Summary List the live rooms with their occupants
+//
+//	@Tags         admin
+//
+// @Success      200  {array}  services.LiveRoom "Success response"
+// @Failure      403  {object}  types.ErrorResponse "Not a moderator"
+// @Router /api/v1/admin/rooms  [get]
+func (services *AdminController) ListRooms(c *gin.Context) {
+	rooms, err := services.Moderation.ListRooms()
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
+	}
+
+	c.JSON(http.StatusOK, rooms)
+}
+
+// Close Room
+// @Summary Remove every user from the room and delete it, admin only
+//
+//	@Description  The users stay connected and get a "roomClosed" event
+//	@Tags         admin
+//
+// @Param        body  body  CloseRoomRequestBody  true  "Room to close"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/rooms/close  [post]
+func (services *AdminController) CloseRoom(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody CloseRoomRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Moderation.CloseRoom(userPtr.ID, types.RoomId(reqBody.RoomId), reqBody.Reason); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// List IP Bans
+// @Summary List the active ip bans, admin only
+//
+//	@Tags         admin
+//
+// @Success      200  {array}  services.IPBanResponse "Success response"
+// @Failure      403  {object}  types.ErrorResponse "Not an admin"
+// @Router /api/v1/admin/ip-bans  [get]
+func (services *AdminController) ListIPBans(c *gin.Context) {
+	bans, err := services.Moderation.ListIPBans()
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
+	}
+
+	c.JSON(http.StatusOK, bans)
+}
+
+// Ban IP
+// @Summary Reject every request and connection from the ip, admin only
+//
+//	@Description  The open connections from the ip are closed. A duration of 0 is permanent
+//	@Tags         admin
+//
+// @Param        body  body  BanIPRequestBody  true  "IP and duration of the ban"
+// @Success      201  {object}  services.IPBanResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/ip-bans  [post]
+func (services *AdminController) BanIP(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody BanIPRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	duration := time.Duration(reqBody.DurationMinutes) * time.Minute
+
+	ban, err := services.Moderation.BanIP(userPtr.ID, reqBody.IP, reqBody.Reason, duration)
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ban)
+}
+
+// Unban IP
+// @Summary Lift the ban of the ip, admin only
+//
+//	@Tags         admin
+//
+// @Param        ip  path  string  true  "Banned ip"
+// @Success      200
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/ip-bans/{ip}  [delete]
+func (services *AdminController) UnbanIP(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Moderation.UnbanIP(userPtr.ID, c.Param("ip")); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Send Announcement
+// @Summary Send a system message to a room, or to every room, admin only
+//
+//	@Description  Clients get a "systemMessage" event sent by the chatbot
+//	@Tags         admin
+//
+// @Param        body  body  AnnouncementRequestBody  true  "Announcement"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/announcements  [post]
+func (services *AdminController) Announce(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody AnnouncementRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Announcements.Send(userPtr.ID, types.RoomId(reqBody.RoomId), reqBody.Message); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// List Scheduled Announcements
+// @Summary List the announcements that are still to be sent, admin only
+//
+//	@Tags         admin
+//
+// @Success      200  {array}  services.AnnouncementResponse "Success response"
+// @Failure      403  {object}  types.ErrorResponse "Not an admin"
+// @Router /api/v1/admin/announcements/scheduled  [get]
+func (services *AdminController) ListAnnouncements(c *gin.Context) {
+	announcements, err := services.Announcements.List()
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
+	}
+
+	c.JSON(http.StatusOK, announcements)
+}
+
+// Schedule Announcement
+// @Summary Schedule a system message, repeated every interval minutes when it's not 0, admin only
+//
+//	@Tags         admin
+//
+// @Param        body  body  ScheduleAnnouncementRequestBody  true  "Announcement"
+// @Success      201  {object}  services.AnnouncementResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/announcements/scheduled  [post]
+func (services *AdminController) ScheduleAnnouncement(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody ScheduleAnnouncementRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	sendAt := time.Time{}
+	if reqBody.SendAt > 0 {
+		sendAt = time.Unix(reqBody.SendAt, 0)
+	}
+
+	announcement, err := services.Announcements.Schedule(userPtr.ID, reqBody.Message, types.RoomId(reqBody.RoomId), sendAt, reqBody.Interval)
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// Cancel Announcement
+// @Summary Cancel a scheduled announcement, admin only
+//
+//	@Tags         admin
+//
+// @Param        announcementId  path  int  true  "Announcement id"
+// @Success      200
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/announcements/scheduled/{announcementId}  [delete]
+func (services *AdminController) CancelAnnouncement(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	announcementId, ok := announcementIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Announcements.Cancel(userPtr.ID, announcementId); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Set Welcome Text
+// @Summary Set the text sent to the users joining the room, admin only
+//
+//	@Description  {room} and {user} are replaced by the room and user names. An empty text restores the default one
+//	@Tags         admin
+//
+// @Param        body  body  WelcomeTextRequestBody  true  "Room and welcome text"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/admin/rooms/welcome  [put]
+func (services *AdminController) SetWelcomeText(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody WelcomeTextRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Announcements.SetWelcomeText(userPtr.ID, types.RoomId(reqBody.RoomId), reqBody.Text); err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func resolution(reqBody ResolveReportRequestBody) services.ReportResolution {
+	return services.ReportResolution{
+		Status: reqBody.Status,
+		Action: reqBody.Action,
+		Note:   reqBody.Note,
+	}
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"core/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	ErrorValidationFailed = errors.New("some fields are invalid")
+)
+
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:      types.ErrorCodeBadRequest,
+	http.StatusUnauthorized:    types.ErrorCodeUnauthorized,
+	http.StatusForbidden:       types.ErrorCodeForbidden,
+	http.StatusNotFound:        types.ErrorCodeNotFound,
+	http.StatusTooManyRequests: types.ErrorCodeTooManyRequests,
+}
+
+func init() {
+	// * report the fields by their json name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+
+			if name == "" {
+				return field.Name
+			}
+
+			return name
+		})
+	}
+}
+
+// abortWithError writes the error response and stops the handlers chain
+func abortWithError(c *gin.Context, status int, err error) {
+	code, exists := statusErrorCodes[status]
+	if !exists {
+		code = types.ErrorCodeInternal
+	}
+
+	c.AbortWithStatusJSON(status, types.ApiErrorCode(code, err))
+}
+
+// bindJSON decodes and validates the request body into dest, it writes a 400
+// with the invalid fields and returns false when it fails
+func bindJSON(c *gin.Context, dest interface{}) bool {
+	err := c.ShouldBindJSON(dest)
+	if err == nil {
+		return true
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		abortWithError(c, http.StatusBadRequest, ErrorMissingParameters)
+		return false
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fields[fieldError.Field()] = validationMessage(fieldError)
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, types.ApiValidationError(ErrorValidationFailed, fields))
+
+	return false
+}
+
+func validationMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters long", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be no longer than %s characters", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fieldError.Param())
+	default:
+		return fmt.Sprintf("failed the %s validation", fieldError.Tag())
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"core/types"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignupValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields map[string]string
+	}{
+		{
+			name:     "empty body",
+			body:     `{}`,
+			wantCode: types.ErrorCodeValidation,
+			wantFields: map[string]string{
+				"email":    "is required",
+				"username": "is required",
+				"password": "is required",
+			},
+		},
+		{
+			name:     "invalid fields",
+			body:     `{"email":"alice","username":"a","password":"` + strings.Repeat("a", 73) + `"}`,
+			wantCode: types.ErrorCodeValidation,
+			wantFields: map[string]string{
+				"email":    "must be a valid email",
+				"username": "must be at least 2 characters long",
+				"password": "must be no longer than 72 characters",
+			},
+		},
+		{
+			name:       "one invalid field",
+			body:       `{"email":"alice@wonderland.tld","username":"Alice","password":"short"}`,
+			wantCode:   types.ErrorCodeValidation,
+			wantFields: map[string]string{"password": "must be at least 6 characters long"},
+		},
+		{name: "malformed json", body: `{"email":`, wantCode: types.ErrorCodeBadRequest},
+		{name: "wrong type", body: `{"email":1}`, wantCode: types.ErrorCodeBadRequest},
+	}
+
+	controller := &UserController{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// * a handler that kept going after the error would append a
+			// * second body and fail decodeError
+			recorder := serve(t, http.MethodPost, controller.Signup, nil, tt.body)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+
+			body := decodeError(t, recorder)
+			if body.Code != tt.wantCode {
+				t.Errorf("code %q, want %q", body.Code, tt.wantCode)
+			}
+
+			if body.Message == "" {
+				t.Error("the error has no message")
+			}
+
+			if !reflect.DeepEqual(body.Fields, tt.wantFields) {
+				t.Errorf("fields %v, want %v", body.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidationFieldNames(t *testing.T) {
+	var reqBody struct {
+		Status   string `json:"status,omitempty" binding:"required,oneof=resolved dismissed"`
+		Untagged string `binding:"required"`
+		Hidden   string `json:"-" binding:"required"`
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"status":"open"}`))
+
+	if bindJSON(c, &reqBody) {
+		t.Fatal("the body must be invalid")
+	}
+
+	body := decodeError(t, recorder)
+
+	want := map[string]string{
+		"status":   "must be one of: resolved dismissed",
+		"Untagged": "is required",
+		"Hidden":   "is required",
+	}
+
+	if !reflect.DeepEqual(body.Fields, want) {
+		t.Errorf("fields %v, want %v", body.Fields, want)
+	}
+}
+
+func TestAbortWithError(t *testing.T) {
+	tests := []struct {
+		status   int
+		wantCode string
+	}{
+		{status: http.StatusBadRequest, wantCode: types.ErrorCodeBadRequest},
+		{status: http.StatusUnauthorized, wantCode: types.ErrorCodeUnauthorized},
+		{status: http.StatusForbidden, wantCode: types.ErrorCodeForbidden},
+		{status: http.StatusNotFound, wantCode: types.ErrorCodeNotFound},
+		{status: http.StatusTooManyRequests, wantCode: types.ErrorCodeTooManyRequests},
+		{status: http.StatusInternalServerError, wantCode: types.ErrorCodeInternal},
+		{status: http.StatusConflict, wantCode: types.ErrorCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			recorder := serve(t, http.MethodGet, func(c *gin.Context) {
+				abortWithError(c, tt.status, errors.New("failed"))
+			}, nil, "")
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status %d, want %d", recorder.Code, tt.status)
+			}
+
+			body := decodeError(t, recorder)
+			if body.Code != tt.wantCode || body.Message != "failed" || body.Fields != nil {
+				t.Errorf("got %+v, want code %q and message %q", body, tt.wantCode, "failed")
+			}
+		})
+	}
+}
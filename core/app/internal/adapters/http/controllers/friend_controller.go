@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"core/internal/core/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrorInvalidAccountId = errors.New("invalid account id")
+)
+
+type FriendController struct {
+	Friends *services.FriendService
+}
+
+func NewFriendController(friendService *services.FriendService) *FriendController {
+	return &FriendController{
+		Friends: friendService,
+	}
+}
+
+type FriendRequestBody struct {
+	Username string `json:"username" binding:"required,max=16" example:"Alice"`
+}
+
+func accountIdParam(c *gin.Context) (uint, bool) {
+	accountId, err := strconv.ParseUint(c.Param("accountId"), 10, 64)
+	if err != nil || accountId == 0 {
+		abortWithError(c, http.StatusBadRequest, ErrorInvalidAccountId)
+		return 0, false
+	}
+
+	return uint(accountId), true
+}
+
+// List Friends
+// @Summary Get the friends with their presence, the pending requests and the blocked users
+//
+//	@Tags         friends
+//
+// @Success      200  {object}  services.FriendsResponse "Success response"
+// @Failure      500  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends  [get]
+func (services *FriendController) List(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	friends, err := services.Friends.List(userPtr.ID)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
+	}
+
+	c.JSON(http.StatusOK, friends)
+}
+
+// Send Friend Request
+// @Summary Ask a user to be friends, a pending request from them is accepted instead
+//
+//	@Tags         friends
+//
+// @Param        body  body  FriendRequestBody  true  "Username of the user"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends/requests  [post]
+func (services *FriendController) SendRequest(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody FriendRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	if err := services.Friends.SendRequest(userPtr.ID, reqBody.Username); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Accept Friend Request
+// @Summary Accept the friend request sent by the account
+//
+//	@Tags         friends
+//
+// @Param        accountId  path  int  true  "Account id of the requester"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends/requests/{accountId}/accept  [post]
+func (services *FriendController) Accept(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Friends.Accept(userPtr.ID, accountId); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Remove Friend
+// @Summary Unfriend the account, or decline or cancel a pending request
+//
+//	@Tags         friends
+//
+// @Param        accountId  path  int  true  "Account id"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends/{accountId}  [delete]
+func (services *FriendController) Remove(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Friends.Remove(userPtr.ID, accountId); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Block User
+// @Summary Block the account, it can't send friend requests anymore
+//
+//	@Tags         friends
+//
+// @Param        accountId  path  int  true  "Account id"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends/{accountId}/block  [post]
+func (services *FriendController) Block(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Friends.Block(userPtr.ID, accountId); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Unblock User
+// @Summary Unblock the account
+//
+//	@Tags         friends
+//
+// @Param        accountId  path  int  true  "Account id"
+// @Success      200
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/friends/{accountId}/block  [delete]
+func (services *FriendController) Unblock(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.Friends.Unblock(userPtr.ID, accountId); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
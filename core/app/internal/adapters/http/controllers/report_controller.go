@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"core/internal/core/services"
+	"core/types"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrorInvalidReportId = errors.New("invalid report id")
+)
+
+type ReportController struct {
+	Moderation *services.ModerationService
+}
+
+func NewReportController(moderationService *services.ModerationService) *ReportController {
+	return &ReportController{
+		Moderation: moderationService,
+	}
+}
+
+type ReportRequestBody struct {
+	UserID  string `json:"userId" binding:"required" example:"4kd9s0a1"`
+	RoomID  string `json:"roomId" binding:"required" example:"keep the block hot#0"`
+	Reason  string `json:"reason" binding:"required,oneof=harassment spam hate impersonation other" example:"harassment"`
+	Details string `json:"details" binding:"max=500" example:"keeps insulting everyone"`
+}
+
+func reportIdParam(c *gin.Context) (uint, bool) {
+	reportId, err := strconv.ParseUint(c.Param("reportId"), 10, 64)
+	if err != nil || reportId == 0 {
+		abortWithError(c, http.StatusBadRequest, ErrorInvalidReportId)
+		return 0, false
+	}
+
+	return uint(reportId), true
+}
+
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrorReportNotFound), errors.Is(err, services.ErrorUserNotFound),
+		errors.Is(err, services.ErrorRoomNotExists), errors.Is(err, services.ErrorIPBanNotFound),
+		errors.Is(err, services.ErrorAnnouncementNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrorCannotModerateStaff):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrorTooManyReports):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrorSaveFailed):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Report User
+// @Summary Report a user of a room, the last messages of the room are attached as evidence
+//
+//	@Description  The reporter must be in the room or have written in it lately. Limited to 5 reports every 10 minutes
+//	@Tags         reports
+//
+// @Param        body  body  ReportRequestBody  true  "Reported user and reason"
+// @Success      201  {object}  services.ReportResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      429  {object}  types.ErrorResponse "Too many reports"
+// @Router /api/v1/reports  [post]
+func (services *ReportController) Create(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody ReportRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	report, err := services.Moderation.Report(userPtr.ID, types.RoomId(reqBody.RoomID), types.ReportUser{
+		UserID:  types.UserID(reqBody.UserID),
+		Reason:  reqBody.Reason,
+		Details: reqBody.Details,
+	})
+
+	if err != nil {
+		abortWithError(c, moderationErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
@@ -1,36 +1,51 @@
 package controllers
 
 import (
-	"core/internal/adapters/memory_storage"
+	"core/internal/core/services"
+	"core/types"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PopularRoomList struct {
 	RoomId     string `json:"roomId"`
+	Slug       string `json:"slug"`
 	RoomName   string `json:"roomName"`
 	TotalConns int    `json:"totalConns"`
 }
 
+type CreateInviteRequestBody struct {
+	RoomId    string `json:"roomId" binding:"required" example:"keep the block hot#334288"`
+	ExpiresIn int    `json:"expiresIn" binding:"min=0" example:"60"` // minutes, 1 day when it's 0
+}
+
 // GetRooms
 // @Summary      Retrieve popular websocket rooms
 //
-//	@Description  Get popular rooms
+//	@Description  Get popular rooms, filtered by tags when the "tag" param is repeated the rooms have every tag
 //	@Tags         rooms
 //
+// @Param        tag  query  []string  false  "Room tag from /api/v1/rooms/tags"  collectionFormat(multi)
 // @Success      200  {object}  []types.PopularRoomList
-// @Failure      500  {object}  map[string]any
+// @Failure      400  {object}  types.ErrorResponse
+// @Failure      500  {object}  types.ErrorResponse
 // @Router /api/v1/rooms [get]
 func GetRooms(c *gin.Context) {
 	fmt.Printf("Get Rooms was called -----------------------------")
 
-	// TODO: mover logica a room service
-	rooms, err := memory_storage.GetPopularRooms()
+	rooms, err := services.RoomDirectory(c.QueryArray("tag"))
+	if errors.Is(err, services.ErrorUnknownRoomTag) {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	if err != nil {
 		fmt.Printf("error from GetRooms service: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
 		return
 	}
 
@@ -40,3 +55,89 @@ func GetRooms(c *gin.Context) {
 		"rooms": rooms,
 	})
 }
+
+// GetRoomTags
+// @Summary      List the tags rooms can have
+//
+//	@Tags         rooms
+//
+// @Success      200  {object}  []string
+// @Router /api/v1/rooms/tags [get]
+func GetRoomTags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"tags": services.RoomTags,
+	})
+}
+
+// GetRoom
+// @Summary      Get a room by its id or its slug
+//
+//	@Tags         rooms
+//
+// @Param        roomRef  path  string  true  "Room id or slug"
+// @Success      200  {object}  types.PopularRoomList
+// @Failure      404  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/rooms/{roomRef} [get]
+func GetRoom(c *gin.Context) {
+	room, err := services.FindRoom(types.RoomId(c.Param("roomRef")))
+	if err != nil {
+		abortWithError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"room": room,
+	})
+}
+
+// CreateRoomInvite
+// @Summary      Create an invite link to a room
+//
+//	@Description  The token is sent as "invite" on the joinRoom event, in place of the password. Only the room owner and the staff can invite.
+//	@Tags         rooms
+//
+// @Param        body  body  CreateInviteRequestBody  true  "Room to invite to"
+// @Success      200  {object}  services.RoomInvite
+// @Failure      400  {object}  types.ErrorResponse
+// @Failure      403  {object}  types.ErrorResponse
+// @Failure      404  {object}  types.ErrorResponse
+// @Router /api/v1/rooms/invites [post]
+func CreateRoomInvite(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody CreateInviteRequestBody
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	inviter := &types.Client{
+		AccountID: userPtr.ID,
+		Username:  userPtr.Username,
+		Role:      userPtr.Role,
+	}
+
+	invite, err := services.CreateInvite(types.RoomId(reqBody.RoomId), inviter, time.Duration(reqBody.ExpiresIn)*time.Minute)
+	if err != nil {
+		abortWithError(c, inviteErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+func inviteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrorRoomNotExists):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrorInviteForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrorInvalidInviteExp):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
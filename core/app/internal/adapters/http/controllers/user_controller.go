@@ -19,13 +19,15 @@ const (
 )
 
 type UserController struct {
-	User *services.UserService
+	User    *services.UserService
+	Account *services.AccountService
 	// ... add more if needed
 }
 
-func NewUserController(userService *services.UserService) *UserController {
+func NewUserController(userService *services.UserService, accountService *services.AccountService) *UserController {
 	return &UserController{
-		User: userService,
+		User:    userService,
+		Account: accountService,
 	}
 }
 
@@ -33,14 +35,10 @@ type SignupSuccessResponse struct {
 	Success bool `json:"success" example:"true"`
 }
 
-type SignupErrorResponse struct {
-	Error string `json:"error" example:"Invalid/missing parameters"`
-}
-
 type SignupRequestBody struct {
-	Email    string `json:"email" example:"alice@wonderland.tld"`
-	Username string `json:"username" example:"Alice"`
-	Password string `json:"password" example:"+5tRonG_P455w0rd_"`
+	Email    string `json:"email" binding:"required,email,max=254" example:"alice@wonderland.tld"`
+	Username string `json:"username" binding:"required,min=2,max=16" example:"Alice"`
+	Password string `json:"password" binding:"required,min=6,max=72" example:"+5tRonG_P455w0rd_"`
 }
 
 type LoginRequestBody struct {
@@ -48,29 +46,50 @@ type LoginRequestBody struct {
 	Password string `json:"password" binding:"required,max=72" example:"+5tRonG_P455w0rd_"`
 }
 
-type LoginErrorResponse struct {
-	Error string `json:"error" example:"Invalid Email and/or Password"`
-}
-
 type RefreshTokenSuccessResponse struct {
 	AccessToken string `json:"accessToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6Ikp915J9..."`
 }
 
-type RefreshTokenErrorResponse struct {
-	Error string `json:"error" example:"something went wrong"`
+type UpdateUserRequestBody struct {
+	Username *string `json:"username" binding:"omitempty,min=2,max=16" example:"Alice"`
+	Password *string `json:"password" binding:"omitempty,min=6,max=72" example:"+5tRonG_P455w0rd_"`
 }
 
 var (
 	ErrorInvalidUser        = errors.New("invalid user")
 	ErrorSomethingWentWrong = errors.New("something went wrong")
 	ErrorMissingParameters  = errors.New("invalid/missing params")
+	ErrorNotAuthenticated   = errors.New("not authenticated")
 )
 
+// currentUser returns the user set by the auth middleware, it writes the
+// error response when it's missing
+func currentUser(c *gin.Context) (*models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		abortWithError(c, http.StatusUnauthorized, ErrorNotAuthenticated)
+		return nil, false
+	}
+
+	userPtr, ok := user.(*models.User)
+	if !ok {
+		abortWithError(c, http.StatusInternalServerError, ErrorInvalidUser)
+		return nil, false
+	}
+
+	return userPtr, true
+}
+
 func setAuthCookie(c *gin.Context, key string, value string, exp int) {
 	c.SetSameSite(DefaultSameSiteAttr)
 	c.SetCookie(key, value, exp, "/", "localhost", false, false)
 }
 
+func clearAuthCookies(c *gin.Context) {
+	setAuthCookie(c, core.CookieAccessToken, "", -1)
+	setAuthCookie(c, core.CookieRefreshToken, "", -1)
+}
+
 func setCsrfCookie(c *gin.Context, token string) {
 	c.SetSameSite(DefaultSameSiteAttr)
 	c.SetCookie(middleware.CSRFCookieKey, token, int(core.RefreshTokenExpTime.Seconds()), "/", "localhost", false, false)
@@ -83,27 +102,28 @@ func setCsrfCookie(c *gin.Context, token string) {
 //
 // @Param        body  body  SignupRequestBody  true  "User signup information"
 // @Success      201  {object}  SignupSuccessResponse "Success response"
-// @Failure      400  {object}  SignupErrorResponse "Failed response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
 // @Router /api/v1/user/signup [post]
 func (services *UserController) Signup(c *gin.Context) {
 	// * 1. Get email, username and password from request body
-	var reqBody struct {
-		Email    string
-		Username string
-		Password string
-	}
+	var reqBody SignupRequestBody
 
-	if c.Bind(&reqBody) != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(ErrorMissingParameters))
+	if !bindJSON(c, &reqBody) {
 		return
 	}
 
-	err := services.User.Signup(reqBody.Email, reqBody.Username, reqBody.Password)
+	user, err := services.User.Signup(reqBody.Email, reqBody.Username, reqBody.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(err))
+		abortWithError(c, http.StatusBadRequest, err)
+		return
 	}
 
-	c.Status(http.StatusOK)
+	// * the account is created even if the mail can't be sent, it can be resent
+	if err := services.Account.RequestVerification(user); err != nil {
+		fmt.Printf("failed to send verification mail: %v\n", err)
+	}
+
+	c.JSON(http.StatusCreated, SignupSuccessResponse{Success: true})
 }
 
 // User Login
@@ -114,24 +134,22 @@ func (services *UserController) Signup(c *gin.Context) {
 //
 // @Param        body  body  LoginRequestBody  true  "User login information"
 // @Success      200  {object}  LoginSuccessResponse "Success response"
-// @Failure      400  {object}  LoginErrorResponse "Failed response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Failure      403  {object}  types.ErrorResponse "The account is banned"
+// @Failure      429  {object}  types.ErrorResponse "Locked out after too many failed attempts"
 // @Router /api/v1/user/login [post]
 func (services *UserController) Login(c *gin.Context) {
 	// * Get email and password from req
-	var reqBody struct {
-		Email    string
-		Password string
-	}
+	var reqBody LoginRequestBody
 
-	if c.Bind(&reqBody) != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(ErrorSomethingWentWrong))
+	if !bindJSON(c, &reqBody) {
 		return
 	}
 
-	authTokens, err := services.User.Login(reqBody.Email, reqBody.Password)
+	authTokens, err := services.User.Login(reqBody.Email, reqBody.Password, c.ClientIP())
 	if err != nil {
 		fmt.Printf("Error: %s", err)
-		c.JSON(http.StatusBadRequest, types.ApiError(err))
+		abortWithError(c, loginErrorStatus(err), err)
 		return
 	}
 
@@ -140,7 +158,8 @@ func (services *UserController) Login(c *gin.Context) {
 
 	token, err := middleware.GetCSRFToken()
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
 	}
 
 	setCsrfCookie(c, *token)
@@ -148,60 +167,109 @@ func (services *UserController) Login(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func loginErrorStatus(err error) int {
+	if errors.Is(err, services.ErrorTooManyLoginAttempts) {
+		return http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, services.ErrorAccountBanned) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusBadRequest
+}
+
 // Refresh Token
-// @Summary Get a new access token
+// @Summary Rotate the refresh token and get a new tokens pair
 //
+//	@Description  Uses the refresh token cookie and the X-Csrf-Token header. Reusing a rotated refresh token revokes its session
 //	@Tags         user
 //
 // @Success      200  {object}  RefreshTokenSuccessResponse "Success response"
-// @Failure      400  {object}  RefreshTokenErrorResponse "Failed response"
-// @Router /api/v1/user/refresh  [get]
+// @Failure      401  {object}  types.ErrorResponse "Failed response"
+// @Failure      403  {object}  types.ErrorResponse "CSRF tokens mismatch"
+// @Router /api/v1/user/refresh  [post]
 func (services *UserController) Refresh(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, types.ApiError(fmt.Errorf("something went wrong 1 %v, %v", user, exists)))
-		return
-	}
-
-	userPtr, ok := user.(*models.User)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, types.ApiError(ErrorInvalidUser))
+	refreshToken, err := c.Cookie(core.CookieRefreshToken)
+	if err != nil {
+		abortWithError(c, http.StatusUnauthorized, ErrorNotAuthenticated)
 		return
 	}
 
-	response, err := services.User.RefreshToken(*userPtr)
+	authTokens, err := services.User.RefreshToken(refreshToken)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err)
+		clearAuthCookies(c)
+		abortWithError(c, http.StatusUnauthorized, err)
 		return
 	}
 
 	token, err := middleware.GetCSRFToken()
 	if err != nil {
 		fmt.Printf("csrf token")
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
 	}
 
 	setCsrfCookie(c, *token)
-	setAuthCookie(c, core.CookieAccessToken, response.AccessToken, int((time.Hour * 24).Seconds()))
+	setAuthCookie(c, core.CookieAccessToken, authTokens.AccessToken, int((time.Hour * 24).Seconds()))
+	setAuthCookie(c, core.CookieRefreshToken, authTokens.RefreshToken, int((time.Hour * 24).Seconds()))
 
 	c.Status(http.StatusOK)
 }
 
-func (services *UserController) GetUserProfile(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.Status(http.StatusUnauthorized)
+// Logout
+// @Summary Revoke the current session
+//
+//	@Tags         user
+//
+// @Success      200
+// @Router /api/v1/user/logout  [post]
+func (services *UserController) Logout(c *gin.Context) {
+	if refreshToken, err := c.Cookie(core.CookieRefreshToken); err == nil {
+		if err := services.User.Logout(refreshToken); err != nil {
+			fmt.Printf("failed to revoke session: %v\n", err)
+		}
 	}
 
-	userPtr, ok := user.(*models.User)
+	clearAuthCookies(c)
+
+	c.Status(http.StatusOK)
+}
+
+// Logout Everywhere
+// @Summary Revoke every session of the user
+//
+//	@Tags         user
+//
+// @Success      200
+// @Failure      500  {object}  types.ErrorResponse
+// @Router /api/v1/user/logout-all  [post]
+func (services *UserController) LogoutEverywhere(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.User.LogoutEverywhere(userPtr.ID); err != nil {
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.Status(http.StatusOK)
+}
+
+func (services *UserController) GetUserProfile(c *gin.Context) {
+	userPtr, ok := currentUser(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, types.ApiError(ErrorInvalidUser))
 		return
 	}
 
 	user, err := services.User.GetProfile(userPtr.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(err))
+		abortWithError(c, http.StatusNotFound, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, types.ApiResponse{
@@ -210,27 +278,25 @@ func (services *UserController) GetUserProfile(c *gin.Context) {
 }
 
 func (services *UserController) UpdateUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.Status(http.StatusUnauthorized)
-	}
-
-	userPtr, ok := user.(*models.User)
+	userPtr, ok := currentUser(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, types.ApiError(ErrorInvalidUser))
 		return
 	}
 
-	var reqBody types.UpdateUser
+	var reqBody UpdateUserRequestBody
 
-	if c.ShouldBindJSON(&reqBody) != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(ErrorMissingParameters))
+	if !bindJSON(c, &reqBody) {
 		return
 	}
 
-	authTokens, err := services.User.Update(userPtr.ID, reqBody)
+	updateUser := types.UpdateUser{
+		UserName: reqBody.Username,
+		Password: reqBody.Password,
+	}
+
+	authTokens, err := services.User.Update(userPtr.ID, c.GetString(middleware.ContextSessionKey), updateUser)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, types.ApiError(err))
+		abortWithError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -239,10 +305,77 @@ func (services *UserController) UpdateUser(c *gin.Context) {
 
 	token, err := middleware.GetCSRFToken()
 	if err != nil {
-		c.Status(http.StatusInternalServerError)
+		abortWithError(c, http.StatusInternalServerError, ErrorSomethingWentWrong)
+		return
 	}
 
 	setCsrfCookie(c, *token)
 
 	c.Status(http.StatusOK)
 }
+
+type AvatarResponse struct {
+	Avatar types.Avatar `json:"avatar"`
+}
+
+// Avatar Catalog
+// @Summary Get the parts an avatar can be made of
+//
+//	@Tags         user
+//
+// @Success      200  {object}  services.AvatarCatalogResponse "Success response"
+// @Router /api/v1/user/avatar/catalog  [get]
+func (services *UserController) GetAvatarCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, services.User.GetAvatarCatalog())
+}
+
+// Get Avatar
+// @Summary Get the user's avatar
+//
+//	@Tags         user
+//
+// @Success      200  {object}  AvatarResponse "Success response"
+// @Failure      401
+// @Router /api/v1/user/avatar  [get]
+func (services *UserController) GetAvatar(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, AvatarResponse{
+		Avatar: services.User.GetAvatar(userPtr.ID),
+	})
+}
+
+// Update Avatar
+// @Summary Customize the user's avatar with parts from the catalog
+//
+//	@Tags         user
+//
+// @Param        body  body  types.Avatar  true  "Avatar parts"
+// @Success      200  {object}  AvatarResponse "Success response"
+// @Failure      400  {object}  types.ErrorResponse "Failed response"
+// @Router /api/v1/user/avatar  [put]
+func (services *UserController) UpdateAvatar(c *gin.Context) {
+	userPtr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var reqBody types.Avatar
+
+	if !bindJSON(c, &reqBody) {
+		return
+	}
+
+	avatar, err := services.User.UpdateAvatar(userPtr.ID, reqBody)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AvatarResponse{
+		Avatar: *avatar,
+	})
+}
@@ -3,39 +3,65 @@ package middleware
 import (
 	"core/internal/core"
 	repositories "core/internal/ports"
+	"core/types"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	ContextSessionKey = "sessionId"
+)
+
+var (
+	ErrorUnauthorized = errors.New("unauthorized")
+)
+
 type AuthMiddleware struct {
-	userRepo *repositories.UserRepoContext
+	userRepo         *repositories.UserRepoContext
+	refreshTokenRepo *repositories.RefreshTokenRepoContext
 }
 
-func NewAuthMiddleware(userRepo *repositories.UserRepoContext) *AuthMiddleware {
+func NewAuthMiddleware(userRepo *repositories.UserRepoContext, refreshTokenRepo *repositories.RefreshTokenRepoContext) *AuthMiddleware {
 	return &AuthMiddleware{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
 	}
 }
 
+// Authenticate accepts only the access token, the refresh token is reserved
+// to the refresh endpoint
 func (ctx *AuthMiddleware) Authenticate(c *gin.Context) {
-	tokenString, _ := c.Cookie(core.CookieRefreshToken)
+	tokenString, _ := c.Cookie(core.CookieAccessToken)
 
-	userData, err := core.DecodeToken(tokenString)
+	userData, err := core.DecodeToken(tokenString, core.TokenTypeAccess)
 	if err != nil {
 		fmt.Println("decode token error:", err)
-		c.Status(http.StatusUnauthorized)
+		abortUnauthorized(c)
 		return
 	}
 
-	user, err := ctx.userRepo.GetById(userData.Sub)
+	// * access tokens stop working as soon as their session is revoked
+	if !ctx.refreshTokenRepo.IsSessionActive(userData.SessionID) {
+		fmt.Println("session is not active:", userData.SessionID)
+		abortUnauthorized(c)
+		return
+	}
+
+	user, err := ctx.userRepo.GetById(float64(userData.Sub))
 	if err != nil {
 		fmt.Println("get by id error:", err)
-		c.Status(http.StatusUnauthorized)
+		abortUnauthorized(c)
 		return
 	}
 
 	c.Set("user", user)
+	c.Set(ContextSessionKey, userData.SessionID)
 	c.Next()
 }
+
+func abortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, types.ApiErrorCode(types.ErrorCodeUnauthorized, ErrorUnauthorized))
+}
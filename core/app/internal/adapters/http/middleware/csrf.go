@@ -37,7 +37,7 @@ func ValidateCSRFToken() gin.HandlerFunc {
 		tokenHeader := c.GetHeader(CSRFHeaderKey)
 		decodedTokenHeader, err := url.QueryUnescape(tokenHeader)
 		if err != nil {
-			c.JSON(http.StatusForbidden, types.ApiError(ErrorTokenMismatch))
+			c.JSON(http.StatusForbidden, types.ApiErrorCode(types.ErrorCodeForbidden, ErrorTokenMismatch))
 			c.Abort()
 			return
 		}
@@ -45,7 +45,7 @@ func ValidateCSRFToken() gin.HandlerFunc {
 		tokenCookie, err := c.Cookie(CSRFCookieKey)
 
 		if err != nil || tokenCookie != decodedTokenHeader {
-			c.JSON(http.StatusForbidden, types.ApiError(ErrorTokenMismatch))
+			c.JSON(http.StatusForbidden, types.ApiErrorCode(types.ErrorCodeForbidden, ErrorTokenMismatch))
 			c.Abort()
 
 			return
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"core/internal/core/services"
+	"core/types"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RejectBannedIPs stops every request from a banned IP, websocket upgrades
+// included. The IP is the remote address unless the request comes through one
+// of the TRUSTED_PROXIES, so a forged X-Forwarded-For doesn't get around a ban.
+func RejectBannedIPs(moderationService *services.ModerationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if moderationService.IsIPBanned(c.ClientIP()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, types.ApiErrorCode(types.ErrorCodeForbidden, services.ErrorIPBanned))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrorMissingRole = errors.New("you don't have permission to do this")
+)
+
+// RequireRole lets through the accounts with the role or a higher one, it
+// expects the user set by Authenticate
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			abortUnauthorized(c)
+			return
+		}
+
+		userPtr, ok := user.(*models.User)
+		if !ok || !userPtr.HasRole(role) {
+			c.JSON(http.StatusForbidden, types.ApiErrorCode(types.ErrorCodeForbidden, ErrorMissingRole))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
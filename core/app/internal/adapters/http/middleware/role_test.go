@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		user     any // * what Authenticate left in the context, nil when unset
+		role     string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "no user", role: models.RoleModerator, wantCode: http.StatusUnauthorized, wantErr: types.ErrorCodeUnauthorized},
+		{name: "not a user", user: "alice", role: models.RoleModerator, wantCode: http.StatusForbidden, wantErr: types.ErrorCodeForbidden},
+		{name: "user", user: &models.User{Role: models.RoleUser}, role: models.RoleModerator, wantCode: http.StatusForbidden, wantErr: types.ErrorCodeForbidden},
+		{name: "unknown role ranks as user", user: &models.User{Role: "owner"}, role: models.RoleModerator, wantCode: http.StatusForbidden, wantErr: types.ErrorCodeForbidden},
+		{name: "moderator", user: &models.User{Role: models.RoleModerator}, role: models.RoleModerator, wantCode: http.StatusOK},
+		{name: "admin has the moderator role", user: &models.User{Role: models.RoleAdmin}, role: models.RoleModerator, wantCode: http.StatusOK},
+		{name: "moderator isn't admin", user: &models.User{Role: models.RoleModerator}, role: models.RoleAdmin, wantCode: http.StatusForbidden, wantErr: types.ErrorCodeForbidden},
+		{name: "admin", user: &models.User{Role: models.RoleAdmin}, role: models.RoleAdmin, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			}, RequireRole(tt.role), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d", recorder.Code, tt.wantCode)
+			}
+
+			if tt.wantErr == "" {
+				return
+			}
+
+			var body types.ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid error body %q: %v", recorder.Body.String(), err)
+			}
+
+			if body.Error.Code != tt.wantErr {
+				t.Errorf("error code %q, want %q", body.Error.Code, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsValidRole(t *testing.T) {
+	for _, role := range []string{models.RoleUser, models.RoleModerator, models.RoleAdmin} {
+		if !models.IsValidRole(role) {
+			t.Errorf("%q must be valid", role)
+		}
+	}
+
+	for _, role := range []string{"", "owner", "Admin"} {
+		if models.IsValidRole(role) {
+			t.Errorf("%q must be invalid", role)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"core/internal/adapters/database/models"
+	"core/internal/core/services"
+	"core/types"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerified rejects the accounts whose email isn't verified yet, it
+// expects the user set by Authenticate
+func RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			abortUnauthorized(c)
+			return
+		}
+
+		userPtr, ok := user.(*models.User)
+		if !ok || !userPtr.IsVerified() {
+			c.JSON(http.StatusForbidden, types.ApiErrorCode(types.ErrorCodeForbidden, services.ErrorEmailNotVerified))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
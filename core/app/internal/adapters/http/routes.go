@@ -14,10 +14,10 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, userController *controllers.UserController, middlewares types.Middlewares) {
+func SetupRoutes(r *gin.Engine, userController *controllers.UserController, friendController *controllers.FriendController, reportController *controllers.ReportController, adminController *controllers.AdminController, wsHandler *ws.WebSocketHandler, middlewares types.Middlewares) {
 	// WebSocket API
-	r.GET("/ws", ws.HandleWebSocket)
-	r.POST("/ws", ws.HandleWebSocket)
+	r.GET("/ws", wsHandler.HandleWebSocket)
+	r.POST("/ws", wsHandler.HandleWebSocket)
 
 	// REST API
 	apiv1 := r.Group("/api/v1")
@@ -26,14 +26,71 @@ func SetupRoutes(r *gin.Engine, userController *controllers.UserController, midd
 		{
 			userGroup.POST("/login", userController.Login)
 			userGroup.POST("/signup", userController.Signup)
-			userGroup.GET("/refresh", middlewares.Auth, userController.Refresh)
-			userGroup.POST("/update", middlewares.Auth, middlewares.CSRF, userController.UpdateUser)
+			userGroup.POST("/refresh", middlewares.CSRF, userController.Refresh)
+			userGroup.POST("/logout", middlewares.CSRF, userController.Logout)
+			userGroup.POST("/logout-all", middlewares.Auth, middlewares.CSRF, userController.LogoutEverywhere)
+			userGroup.POST("/verify", userController.VerifyEmail)
+			userGroup.POST("/verify/resend", middlewares.Auth, middlewares.CSRF, userController.ResendVerification)
+			userGroup.POST("/forgot-password", userController.ForgotPassword)
+			userGroup.POST("/reset-password", userController.ResetPassword)
+			userGroup.POST("/update", middlewares.Auth, middlewares.Verified, middlewares.CSRF, userController.UpdateUser)
 			userGroup.GET("/profile", middlewares.Auth, userController.GetUserProfile)
+			userGroup.GET("/export", middlewares.Auth, userController.ExportAccount)
+			userGroup.DELETE("", middlewares.Auth, middlewares.CSRF, userController.DeleteAccount)
+			userGroup.GET("/avatar/catalog", userController.GetAvatarCatalog)
+			userGroup.GET("/avatar", middlewares.Auth, userController.GetAvatar)
+			userGroup.PUT("/avatar", middlewares.Auth, middlewares.Verified, middlewares.CSRF, userController.UpdateAvatar)
+		}
+
+		friendGroup := apiv1.Group("/friends", middlewares.Auth)
+		{
+			friendGroup.GET("", friendController.List)
+			friendGroup.POST("/requests", middlewares.CSRF, friendController.SendRequest)
+			friendGroup.POST("/requests/:accountId/accept", middlewares.CSRF, friendController.Accept)
+			friendGroup.DELETE("/:accountId", middlewares.CSRF, friendController.Remove)
+			friendGroup.POST("/:accountId/block", middlewares.CSRF, friendController.Block)
+			friendGroup.DELETE("/:accountId/block", middlewares.CSRF, friendController.Unblock)
+		}
+
+		reportGroup := apiv1.Group("/reports", middlewares.Auth)
+		{
+			reportGroup.POST("", middlewares.CSRF, reportController.Create)
+		}
+
+		// * moderators handle the reports and the accounts, the rest is for admins
+		adminGroup := apiv1.Group("/admin", middlewares.Auth, middlewares.Moderator)
+		{
+			adminGroup.GET("/reports", adminController.ListReports)
+			adminGroup.GET("/reports/:reportId", adminController.GetReport)
+			adminGroup.POST("/reports/:reportId/review", middlewares.CSRF, adminController.ReviewReport)
+			adminGroup.POST("/reports/:reportId/resolve", middlewares.CSRF, adminController.ResolveReport)
+			adminGroup.POST("/users/:accountId/ban", middlewares.CSRF, adminController.BanAccount)
+			adminGroup.DELETE("/users/:accountId/ban", middlewares.CSRF, adminController.UnbanAccount)
+			adminGroup.POST("/users/:accountId/kick", middlewares.CSRF, adminController.KickAccount)
+			adminGroup.PUT("/users/:accountId/role", middlewares.Admin, middlewares.CSRF, adminController.SetRole)
+			adminGroup.GET("/rooms", adminController.ListRooms)
+			adminGroup.POST("/rooms/close", middlewares.Admin, middlewares.CSRF, adminController.CloseRoom)
+			adminGroup.GET("/ip-bans", middlewares.Admin, adminController.ListIPBans)
+			adminGroup.POST("/ip-bans", middlewares.Admin, middlewares.CSRF, adminController.BanIP)
+			adminGroup.DELETE("/ip-bans/:ip", middlewares.Admin, middlewares.CSRF, adminController.UnbanIP)
+			adminGroup.PUT("/rooms/welcome", middlewares.Admin, middlewares.CSRF, adminController.SetWelcomeText)
+			adminGroup.POST("/announcements", middlewares.Admin, middlewares.CSRF, adminController.Announce)
+			adminGroup.GET("/announcements/scheduled", middlewares.Admin, adminController.ListAnnouncements)
+			adminGroup.POST("/announcements/scheduled", middlewares.Admin, middlewares.CSRF, adminController.ScheduleAnnouncement)
+			adminGroup.DELETE("/announcements/scheduled/:announcementId", middlewares.Admin, middlewares.CSRF, adminController.CancelAnnouncement)
+		}
+
+		wsGroup := apiv1.Group("/ws")
+		{
+			wsGroup.POST("/ticket", middlewares.Auth, middlewares.CSRF, wsHandler.IssueTicket)
 		}
 
 		roomGroup := apiv1.Group("/rooms")
 		{
 			roomGroup.GET("", controllers.GetRooms)
+			roomGroup.GET("/tags", controllers.GetRoomTags)
+			roomGroup.GET("/:roomRef", controllers.GetRoom)
+			roomGroup.POST("/invites", middlewares.Auth, middlewares.CSRF, controllers.CreateRoomInvite)
 		}
 	}
 
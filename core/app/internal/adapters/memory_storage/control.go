@@ -0,0 +1,90 @@
+package memory_storage
+
+import (
+	"context"
+	types "core/types"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// * node to node messages that aren't bound to a room
+	controlChannel string = "control"
+)
+
+// PublishControl sends msg to every node, including this one
+func PublishControl(msg types.ControlMessage) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed marshalling control message: %w", err)
+	}
+
+	if err := redisClient.Publish(ctx, controlChannel, payload).Err(); err != nil {
+		return fmt.Errorf("failed publishing control message: %w", err)
+	}
+
+	return nil
+}
+
+// SubscribeControl calls handle for every control message published while
+// the node runs
+func SubscribeControl(handle func(types.ControlMessage)) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	pubsub := redisClient.Subscribe(context.Background(), controlChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to control channel: %w", err)
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var controlMsg types.ControlMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &controlMsg); err != nil {
+				log.Printf("invalid control message: %v", err)
+				continue
+			}
+
+			handle(controlMsg)
+		}
+	}()
+
+	return nil
+}
+
+// DisconnectAccount closes the websocket connections of the account on every
+// node
+func DisconnectAccount(accountId uint, reason string) error {
+	return PublishControl(types.ControlMessage{
+		Type:      types.ControlDisconnectAccount,
+		AccountID: accountId,
+		Reason:    reason,
+	})
+}
+
+// DisconnectSession closes the websocket connections opened with the login
+// session on every node
+func DisconnectSession(sessionId string, reason string) error {
+	return PublishControl(types.ControlMessage{
+		Type:      types.ControlDisconnectSession,
+		SessionID: sessionId,
+		Reason:    reason,
+	})
+}
+
+// PublishBlock tells every node that accountId blocked or unblocked
+// targetAccountId, the connections of both accounts update their filters
+func PublishBlock(accountId uint, targetAccountId uint, blocked bool) error {
+	return PublishControl(types.ControlMessage{
+		Type:            types.ControlAccountBlocked,
+		AccountID:       accountId,
+		TargetAccountID: targetAccountId,
+		Blocked:         blocked,
+	})
+}
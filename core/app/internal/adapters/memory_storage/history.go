@@ -0,0 +1,65 @@
+package memory_storage
+
+import (
+	"core/config"
+	types "core/types"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// * newest first list of the chat messages of a room
+	roomHistoryKeyFormat string = "roomhistory:%s"
+
+	// * the history outlives the room so that it can still be reported
+	roomHistoryTTL = 24 * time.Hour
+)
+
+// PushRoomHistory keeps the message in the room history, only the last
+// config.RoomHistoryLimit messages are kept
+func PushRoomHistory(roomId types.RoomId, entry types.HistoryEntry) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed marshalling history entry: %w", err)
+	}
+
+	historyKey := fmt.Sprintf(roomHistoryKeyFormat, roomId)
+
+	pipe := redisClient.TxPipeline()
+	pipe.LPush(ctx, historyKey, entryJSON)
+	pipe.LTrim(ctx, historyKey, 0, int64(config.RoomHistoryLimit-1))
+	pipe.Expire(ctx, historyKey, roomHistoryTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("could not save room history: %w", err)
+	}
+
+	return nil
+}
+
+// GetRoomHistory returns the last count messages of the room, oldest first
+func GetRoomHistory(roomId types.RoomId, count int) ([]types.HistoryEntry, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	entriesJSON, err := redisClient.LRange(ctx, fmt.Sprintf(roomHistoryKeyFormat, roomId), 0, int64(count-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get room history: %w", err)
+	}
+
+	history := make([]types.HistoryEntry, 0, len(entriesJSON))
+	for i := len(entriesJSON) - 1; i >= 0; i-- {
+		var entry types.HistoryEntry
+		if err := json.Unmarshal([]byte(entriesJSON[i]), &entry); err != nil {
+			continue
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
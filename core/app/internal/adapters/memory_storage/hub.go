@@ -0,0 +1,207 @@
+package memory_storage
+
+import (
+	"context"
+	"core/internal/core/wire"
+	types "core/types"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// roomSubscription is the single Redis subscription this node holds for a
+// room, shared by every local member of that room.
+type roomSubscription struct {
+	pubsub  *redis.PubSub
+	members map[types.UserID]*types.MessageClient
+}
+
+// roomHub keeps one subscription per active room on this node and fans the
+// published payloads out to the local members.
+type roomHub struct {
+	mu    sync.Mutex
+	rooms map[types.RoomId]*roomSubscription
+}
+
+var hub = &roomHub{
+	rooms: make(map[types.RoomId]*roomSubscription),
+}
+
+// SubscribeRoom registers the client as a local member of the room. The Redis
+// subscription is opened when the first local member joins.
+func SubscribeRoom(mc *types.MessageClient, roomId types.RoomId) error {
+	hub.mu.Lock()
+	if sub, exists := hub.rooms[roomId]; exists {
+		sub.members[mc.Client.ID] = mc
+		hub.mu.Unlock()
+		return nil
+	}
+	hub.mu.Unlock()
+
+	// * subscribing waits on Redis, the other rooms keep going meanwhile
+	pubsub, err := subscribeChannel(roomId)
+	if err != nil {
+		return err
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// * another member of the room may have subscribed in the meantime
+	sub, exists := hub.rooms[roomId]
+	if exists {
+		if err := pubsub.Close(); err != nil {
+			fmt.Printf("failed to close subscription for room %s: %v\n", roomId, err)
+		}
+	} else {
+		sub = &roomSubscription{
+			pubsub:  pubsub,
+			members: make(map[types.UserID]*types.MessageClient),
+		}
+
+		hub.rooms[roomId] = sub
+		go sub.fanOut(roomId)
+	}
+
+	sub.members[mc.Client.ID] = mc
+
+	return nil
+}
+
+func subscribeChannel(roomId types.RoomId) (*redis.PubSub, error) {
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelCtx()
+
+	pubsub := redisClient.Subscribe(context.Background(), string(roomId))
+
+	// * wait for the confirmation so that broadcasts sent right after
+	// * joining are not lost
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to room %s: %w", roomId, err)
+	}
+
+	return pubsub, nil
+}
+
+// UnsubscribeRoom removes the client from the local members of the room and
+// closes the Redis subscription once the last local member is gone.
+func UnsubscribeRoom(userId types.UserID, roomId types.RoomId) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	sub, exists := hub.rooms[roomId]
+	if !exists {
+		return
+	}
+
+	delete(sub.members, userId)
+
+	if len(sub.members) == 0 {
+		if err := sub.pubsub.Close(); err != nil {
+			fmt.Printf("failed to close subscription for room %s: %v\n", roomId, err)
+		}
+
+		delete(hub.rooms, roomId)
+	}
+}
+
+// DropRoom closes the subscription of a room that no longer exists and
+// returns its local members
+func DropRoom(roomId types.RoomId) []*types.MessageClient {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	sub, exists := hub.rooms[roomId]
+	if !exists {
+		return nil
+	}
+
+	members := make([]*types.MessageClient, 0, len(sub.members))
+	for _, mc := range sub.members {
+		members = append(members, mc)
+	}
+
+	if err := sub.pubsub.Close(); err != nil {
+		fmt.Printf("failed to close subscription for room %s: %v\n", roomId, err)
+	}
+
+	delete(hub.rooms, roomId)
+
+	return members
+}
+
+// roomEnvelope is what is published on a room channel, the sender and
+// target are only read by the hub and never reach the clients
+type roomEnvelope struct {
+	From    types.Sender // * empty for server events, which are never filtered
+	To      types.UserID // * only this member and the sender get a targeted message
+	Payload []byte       // * WsPayload with the internal encoding
+}
+
+// delivers reports whether the member gets the message
+func (envelope *roomEnvelope) delivers(mc *types.MessageClient) bool {
+	if len(envelope.To) > 0 && mc.Client.ID != envelope.To && mc.Client.ID != envelope.From.UserID {
+		return false
+	}
+
+	if len(envelope.From.UserID) == 0 || mc.Client.ID == envelope.From.UserID {
+		return true
+	}
+
+	return !mc.Filter.Rejects(envelope.From)
+}
+
+func (sub *roomSubscription) fanOut(roomId types.RoomId) {
+	// * the channel is closed by pubsub.Close when the last member leaves
+	for msg := range sub.pubsub.Channel() {
+		var envelope roomEnvelope
+		if err := wire.Internal.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+			fmt.Printf("failed to decode envelope for room %s: %v\n", roomId, err)
+			continue
+		}
+
+		hub.mu.Lock()
+		members := make([]*types.MessageClient, 0, len(sub.members))
+		for _, mc := range sub.members {
+			members = append(members, mc)
+		}
+		hub.mu.Unlock()
+
+		envelope.deliver(roomId, members)
+	}
+}
+
+// deliver queues the payload on the members it's for, without blocking
+func (envelope *roomEnvelope) deliver(roomId types.RoomId, members []*types.MessageClient) {
+	// * encode the payload once per codec used by the members
+	frames := make(map[wire.Codec][]byte)
+
+	for _, mc := range members {
+		// * blocks and mutes are applied per recipient
+		if !envelope.delivers(mc) {
+			continue
+		}
+
+		codec := wire.FromSubprotocol(mc.Subprotocol)
+		frame, encoded := frames[codec]
+		if !encoded {
+			var err error
+			frame, err = wire.Transcode(envelope.Payload, wire.Internal, codec)
+			if err != nil {
+				fmt.Printf("failed to transcode payload for room %s: %v\n", roomId, err)
+				continue
+			}
+
+			frames[codec] = frame
+		}
+
+		select {
+		case mc.Send <- frame:
+		default:
+			fmt.Printf("dropping message for %s in room %s: send buffer is full\n", mc.Client.ID, roomId)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package memory_storage
+
+import (
+	"core/internal/core/wire"
+	types "core/types"
+	"testing"
+)
+
+func testMember(userId types.UserID, subprotocol string, buffer int) *types.MessageClient {
+	return &types.MessageClient{
+		Client:      &types.Client{ID: userId},
+		Subprotocol: subprotocol,
+		Send:        make(chan []byte, buffer),
+	}
+}
+
+func testEnvelope(t *testing.T, from types.Sender, to types.UserID) *roomEnvelope {
+	t.Helper()
+
+	payload, err := wire.Internal.Marshal(types.WsPayload{Event: "broadcastMessage", Data: map[string]string{"msg": "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// * go through the encoding used on the channel like fanOut does
+	encoded, err := wire.Internal.Marshal(roomEnvelope{From: from, To: to, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var envelope roomEnvelope
+	if err := wire.Internal.Unmarshal(encoded, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	return &envelope
+}
+
+func TestDelivers(t *testing.T) {
+	alice := types.Sender{UserID: "alice", AccountID: 1}
+	guest := types.Sender{UserID: "guest"}
+
+	tests := []struct {
+		name   string
+		from   types.Sender
+		to     types.UserID
+		member types.UserID
+		filter func(filter *types.MessageFilter)
+		want   bool
+	}{
+		{name: "broadcast", from: alice, member: "bob", want: true},
+		{name: "server event", member: "bob", want: true},
+		{name: "server event to a muted user", member: "bob", filter: func(f *types.MessageFilter) { f.Mute("", true) }, want: true},
+		{name: "blocked account", from: alice, member: "bob", filter: func(f *types.MessageFilter) { f.Block(1, true) }, want: false},
+		{name: "unblocked account", from: alice, member: "bob", filter: func(f *types.MessageFilter) { f.Block(1, true); f.Block(1, false) }, want: true},
+		{name: "blocked in the other direction", from: alice, member: "bob", filter: func(f *types.MessageFilter) { f.SetBlocked([]uint{2, 1}) }, want: false},
+		{name: "muted user", from: alice, member: "bob", filter: func(f *types.MessageFilter) { f.Mute("alice", true) }, want: false},
+		{name: "muted guest", from: guest, member: "bob", filter: func(f *types.MessageFilter) { f.Mute("guest", true) }, want: false},
+		{name: "guest isn't a blocked account", from: guest, member: "bob", filter: func(f *types.MessageFilter) { f.Block(0, true) }, want: true},
+		{name: "own message", from: alice, member: "alice", filter: func(f *types.MessageFilter) { f.Mute("alice", true) }, want: true},
+		{name: "whisper target", from: alice, to: "bob", member: "bob", want: true},
+		{name: "whisper sender", from: alice, to: "bob", member: "alice", want: true},
+		{name: "whisper to someone else", from: alice, to: "bob", member: "carol", want: false},
+		{name: "whisper from a blocked account", from: alice, to: "bob", member: "bob", filter: func(f *types.MessageFilter) { f.Block(1, true) }, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := testMember(tt.member, wire.SubprotocolJSON, 1)
+			if tt.filter != nil {
+				tt.filter(&mc.Filter)
+			}
+
+			envelope := testEnvelope(t, tt.from, tt.to)
+			if got := envelope.delivers(mc); got != tt.want {
+				t.Errorf("delivers %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	alice := types.Sender{UserID: "alice", AccountID: 1}
+
+	sender := testMember("alice", wire.SubprotocolJSON, 1)
+	jsonMember := testMember("bob", wire.SubprotocolJSON, 1)
+	msgPackMember := testMember("carol", wire.SubprotocolMsgPack, 1)
+	blocking := testMember("dave", wire.SubprotocolJSON, 1)
+	blocking.Filter.Block(1, true)
+	full := testMember("erin", wire.SubprotocolJSON, 1)
+	full.Send <- []byte("pending")
+
+	members := []*types.MessageClient{sender, jsonMember, msgPackMember, blocking, full}
+
+	envelope := testEnvelope(t, alice, "")
+	envelope.deliver("room#1", members)
+
+	tests := []struct {
+		name  string
+		mc    *types.MessageClient
+		codec wire.Codec // * nil when nothing is delivered
+	}{
+		{name: "sender", mc: sender, codec: wire.JSON},
+		{name: "json member", mc: jsonMember, codec: wire.JSON},
+		{name: "msgpack member", mc: msgPackMember, codec: wire.MsgPack},
+		{name: "blocking member", mc: blocking},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.codec == nil {
+				if len(tt.mc.Send) != 0 {
+					t.Fatalf("got %d frames, want none", len(tt.mc.Send))
+				}
+
+				return
+			}
+
+			if len(tt.mc.Send) != 1 {
+				t.Fatalf("got %d frames, want 1", len(tt.mc.Send))
+			}
+
+			frame := <-tt.mc.Send
+			decoded, err := tt.codec.DecodeEnvelope(frame)
+			if err != nil {
+				t.Fatalf("frame isn't encoded with the codec of the member: %v", err)
+			}
+
+			if decoded.Event != "broadcastMessage" {
+				t.Errorf("event %q, want %q", decoded.Event, "broadcastMessage")
+			}
+		})
+	}
+
+	t.Run("full buffer", func(t *testing.T) {
+		if len(full.Send) != 1 || string(<-full.Send) != "pending" {
+			t.Fatal("the frame of a full buffer must be dropped")
+		}
+	})
+}
+
+func TestDeliverInvalidPayload(t *testing.T) {
+	mc := testMember("bob", wire.SubprotocolJSON, 1)
+
+	envelope := &roomEnvelope{Payload: []byte{0xc1}}
+	envelope.deliver("room#1", []*types.MessageClient{mc})
+
+	if len(mc.Send) != 0 {
+		t.Fatalf("got %d frames, want none", len(mc.Send))
+	}
+}
@@ -0,0 +1,64 @@
+package memory_storage
+
+import (
+	types "core/types"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// * the banned IPs are checked on every request, the database is the
+	// * source of truth and fills them on startup
+	ipBanKeyFormat string = "ipban:%s"
+)
+
+// BanIP rejects the IP until ttl is over, forever when ttl is 0
+func BanIP(ip string, reason string, ttl time.Duration) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.Set(ctx, fmt.Sprintf(ipBanKeyFormat, ip), reason, ttl).Err(); err != nil {
+		return fmt.Errorf("could not ban ip: %w", err)
+	}
+
+	return nil
+}
+
+func UnbanIP(ip string) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.Del(ctx, fmt.Sprintf(ipBanKeyFormat, ip)).Err(); err != nil {
+		return fmt.Errorf("could not unban ip: %w", err)
+	}
+
+	return nil
+}
+
+func IsIPBanned(ip string) (bool, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	err := redisClient.Get(ctx, fmt.Sprintf(ipBanKeyFormat, ip)).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not check ip ban: %w", err)
+	}
+
+	return true, nil
+}
+
+// DisconnectIP closes the websocket connections opened from the IP on every
+// node
+func DisconnectIP(ip string, reason string) error {
+	return PublishControl(types.ControlMessage{
+		Type:   types.ControlDisconnectIP,
+		IP:     ip,
+		Reason: reason,
+	})
+}
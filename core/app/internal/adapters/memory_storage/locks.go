@@ -0,0 +1,112 @@
+package memory_storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	lockKeyFormat string = "lock:%s"
+
+	lockRetryDelay = 20 * time.Millisecond
+)
+
+var (
+	ErrorLockTimeout = errors.New("timed out waiting for the lock")
+)
+
+// * the lock is only extended or deleted by the holder of its token, a node
+// * that stalled past the ttl can't touch the lock taken by another one
+var (
+	refreshLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// Lock is a named lock taken by this node, shared by every node and expiring
+// on its own
+type Lock struct {
+	name  string
+	token string // * random value stored in the lock, tells the holders apart
+}
+
+// TryLock takes the named lock for ttl, it returns nil when another node holds
+// it
+func TryLock(name string, ttl time.Duration) (*Lock, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	lock := &Lock{name: name, token: uuid.NewString()}
+
+	locked, err := redisClient.SetNX(ctx, fmt.Sprintf(lockKeyFormat, name), lock.token, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not take lock %s: %w", name, err)
+	}
+
+	if !locked {
+		return nil, nil
+	}
+
+	return lock, nil
+}
+
+// AcquireLock waits up to wait for the named lock, for the short sections
+// that every node runs
+func AcquireLock(name string, ttl time.Duration, wait time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(wait)
+
+	for {
+		lock, err := TryLock(name, ttl)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrorLockTimeout, name)
+		}
+
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// Refresh extends the lock, it reports false when the lock expired in the
+// meantime and may be held by another node
+func (lock *Lock) Refresh(ttl time.Duration) (bool, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	key := fmt.Sprintf(lockKeyFormat, lock.name)
+
+	refreshed, err := refreshLockScript.Run(ctx, redisClient, []string{key}, lock.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("could not refresh lock %s: %w", lock.name, err)
+	}
+
+	return refreshed == 1, nil
+}
+
+// Release frees the lock when it's still held by this node
+func (lock *Lock) Release() error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	key := fmt.Sprintf(lockKeyFormat, lock.name)
+
+	if err := releaseLockScript.Run(ctx, redisClient, []string{key}, lock.token).Err(); err != nil {
+		return fmt.Errorf("could not release lock %s: %w", lock.name, err)
+	}
+
+	return nil
+}
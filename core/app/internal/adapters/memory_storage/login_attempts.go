@@ -0,0 +1,78 @@
+package memory_storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	loginFailuresKeyFormat string = "loginfail:%s"
+	loginLockKeyFormat     string = "loginlock:%s"
+)
+
+// LoginLockedFor returns how long the logins of key are still locked, zero
+// when they aren't
+func LoginLockedFor(key string) (time.Duration, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	ttl, err := redisClient.PTTL(ctx, fmt.Sprintf(loginLockKeyFormat, key)).Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("could not get login lock: %w", err)
+	}
+
+	// * PTTL is negative when the lock doesn't exist
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// RecordLoginFailure counts a failed login for key and returns the failures
+// within window. The window starts with the first failure.
+func RecordLoginFailure(key string, window time.Duration) (int64, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	failuresKey := fmt.Sprintf(loginFailuresKeyFormat, key)
+
+	// * the window is created with its expiration, the counter can't be left
+	// * without one
+	pipe := redisClient.TxPipeline()
+	pipe.SetNX(ctx, failuresKey, 0, window)
+	failures := pipe.Incr(ctx, failuresKey)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("could not count login failure: %w", err)
+	}
+
+	return failures.Val(), nil
+}
+
+// LockLogin rejects the logins of key for duration
+func LockLogin(key string, duration time.Duration) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.Set(ctx, fmt.Sprintf(loginLockKeyFormat, key), 1, duration).Err(); err != nil {
+		return fmt.Errorf("could not lock login: %w", err)
+	}
+
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins of key
+func ResetLoginFailures(key string) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	err := redisClient.Del(ctx, fmt.Sprintf(loginFailuresKeyFormat, key), fmt.Sprintf(loginLockKeyFormat, key)).Err()
+	if err != nil {
+		return fmt.Errorf("could not reset login failures: %w", err)
+	}
+
+	return nil
+}
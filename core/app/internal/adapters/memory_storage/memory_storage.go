@@ -3,6 +3,8 @@ package memory_storage
 import (
 	"context"
 	"core/config"
+	"core/internal/core/wire"
+	util "core/internal/utils"
 	types "core/types"
 	"encoding/json"
 	"fmt"
@@ -45,9 +47,11 @@ func New() error {
 	// }
 
 	password := "12345"
+	welcomeRoomId := string(WelcomeRoomId())
 
 	welcomeRoom := types.RoomData{
 		Name:           config.WelcomeRoomName,
+		Slug:           util.Slugify(config.WelcomeRoomName),
 		Users:          []types.User{},
 		UsersPositions: []string{},
 		UserIdxMap:     make(map[types.UserID]types.UserIdx),
@@ -60,7 +64,9 @@ func New() error {
 		return fmt.Errorf("failed marshalling client data: %v", err)
 	}
 
-	welcomeRoomId := fmt.Sprintf(types.RoomIdFormat, welcomeRoom.Name, "0")
+	if err := redisClient.HSet(ctx, roomSlugsKey, welcomeRoom.Slug, welcomeRoomId).Err(); err != nil {
+		return fmt.Errorf("failed saving welcome room slug to Redis: %s", err)
+	}
 
 	if roomData, err := redisClient.HGet(ctx, roomsKey, welcomeRoomId).Result(); err != redis.Nil || len(roomData) == 0 {
 		err = redisClient.HSet(ctx, roomsKey, welcomeRoomId, welcomeRoomJSON).Err()
@@ -74,44 +80,55 @@ func New() error {
 	return nil
 }
 
-func UserSubscribe(mc *types.MessageClient, roomId types.RoomId) {
-	ctx, cancelCtx := context.WithTimeout(context.Background(), pubsubCtxTimeout)
-	defer cancelCtx()
+// WelcomeRoomId is the id of the room created on startup
+func WelcomeRoomId() types.RoomId {
+	return types.RoomId(fmt.Sprintf(types.RoomIdFormat, config.WelcomeRoomName, "0"))
+}
 
-	pubsub := redisClient.Subscribe(ctx, string(roomId))
-	defer pubsub.Close()
+func BroadcastRoom(roomId types.RoomId, event string, data interface{}) {
+	publishRoom(roomId, roomEnvelope{}, event, data)
+}
 
-	controlCh := pubsub.Channel()
+// BroadcastRoomFrom sends a message written by sender to the room, members
+// that blocked or muted the sender don't get it
+func BroadcastRoomFrom(roomId types.RoomId, sender types.Sender, event string, data interface{}) {
+	publishRoom(roomId, roomEnvelope{From: sender}, event, data)
+}
 
-	for {
-		select {
-		case msg := <-controlCh:
-			mc.Send <- []byte(msg.Payload)
-		case <-ctx.Done():
-			log.Println("Context canceled, exiting subscribe loop.")
-			return
-		}
-	}
+// WhisperRoom sends a message written by sender to a single member of the
+// room, the sender gets it too
+func WhisperRoom(roomId types.RoomId, sender types.Sender, to types.UserID, event string, data interface{}) {
+	publishRoom(roomId, roomEnvelope{From: sender, To: to}, event, data)
 }
 
-func BroadcastRoom(roomId types.RoomId, event string, data interface{}) {
+func publishRoom(roomId types.RoomId, envelope roomEnvelope, event string, data interface{}) {
 	ctx, cancelCtx := context.WithTimeout(context.Background(), pubsubCtxTimeout)
 	defer cancelCtx()
 
-	payload := make(map[string]interface{})
-	payload["Event"] = event
-	payload["Data"] = data
+	payload := types.WsPayload{
+		Event: event,
+		Data:  data,
+	}
 
-	fmt.Printf("Broadcasting payload: %v\n", payload["Data"])
+	fmt.Printf("Broadcasting payload: %v\n", payload.Data)
 
-	// serialize payload to json so that redis accepts it
-	JSONPayload, err := json.Marshal(payload)
+	// * serialize payload with the internal encoding, the hub transcodes it
+	// * for every client on delivery
+	encodedPayload, err := wire.Internal.Marshal(payload)
 	if err != nil {
 		fmt.Printf("Error on serialize payload: %v\n", err)
 		return
 	}
 
-	err = redisClient.Publish(ctx, string(roomId), JSONPayload).Err()
+	envelope.Payload = encodedPayload
+
+	encodedEnvelope, err := wire.Internal.Marshal(envelope)
+	if err != nil {
+		fmt.Printf("Error on serialize envelope: %v\n", err)
+		return
+	}
+
+	err = redisClient.Publish(ctx, string(roomId), encodedEnvelope).Err()
 	if err != nil {
 		fmt.Printf("Error on publish %v\n", err)
 	}
@@ -149,6 +166,12 @@ func AddClient(data *types.Client) {
 	if !added {
 		log.Printf("Client with ID %s already exists", string(data.ID))
 	}
+
+	if data.AccountID != 0 {
+		if err := addAccountClient(data.AccountID, data.ID); err != nil {
+			log.Printf("Error indexing client %s: %s", string(data.ID), err)
+		}
+	}
 }
 
 func GetClient(clientID types.UserID) (*types.Client, error) {
@@ -172,6 +195,12 @@ func DeleteClient(clientID types.UserID) error {
 	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
 	defer cancelCtx()
 
+	if client, err := GetClient(clientID); err == nil && client.AccountID != 0 {
+		if err := removeAccountClient(client.AccountID, clientID); err != nil {
+			log.Printf("Error removing client %s from its account: %s", string(clientID), err)
+		}
+	}
+
 	// Use HDEL to remove the client entry from the hash
 	err := redisClient.HDel(ctx, clientsKey, string(clientID)).Err()
 	if err != nil {
@@ -195,20 +224,41 @@ func UpdateUser(clientID types.UserID, updateData *types.UpdateUser) error {
 		return fmt.Errorf("could not unmarshal client data: %w", err)
 	}
 
-	newClientData := types.Client{
-		ID: client.ID,
-	}
-
+	// * only the given fields are updated, the rest of the client data is kept
 	if updateData.RoomId != nil {
-		newClientData.RoomId = types.RoomId(*updateData.RoomId)
+		client.RoomId = types.RoomId(*updateData.RoomId)
 	}
 
 	if updateData.UserName != nil {
-		newClientData.Username = *updateData.UserName
+		client.Username = *updateData.UserName
+	}
+
+	if updateData.Avatar != nil {
+		client.Avatar = *updateData.Avatar
+	}
+
+	if updateData.Role != nil {
+		client.Role = *updateData.Role
+	}
+
+	if updateData.QueueRoomId != nil {
+		client.QueueRoomId = types.RoomId(*updateData.QueueRoomId)
+	}
+
+	if updateData.WatchRoomId != nil {
+		client.WatchRoomId = types.RoomId(*updateData.WatchRoomId)
+	}
+
+	if updateData.AccountID != nil && client.AccountID != *updateData.AccountID {
+		client.AccountID = *updateData.AccountID
+
+		if err := addAccountClient(client.AccountID, clientID); err != nil {
+			return err
+		}
 	}
 
 	// Marshal the updated client data back to JSON
-	updatedClientJSON, err := json.Marshal(newClientData)
+	updatedClientJSON, err := json.Marshal(client)
 	if err != nil {
 		return fmt.Errorf("could not marshal updated client data: %w", err)
 	}
@@ -222,19 +272,22 @@ func UpdateUser(clientID types.UserID, updateData *types.UpdateUser) error {
 	return nil
 }
 
-func CreateRoom(roomName string, roomId types.RoomId, roomData types.RoomData) {
+// CreateRoom saves a new room, it reports false when the id is taken
+func CreateRoom(roomId types.RoomId, roomData types.RoomData) (bool, error) {
 	roomJson, err := json.Marshal(roomData)
 	if err != nil {
-		log.Fatalf("Error marshalling room data: %s", err)
+		return false, fmt.Errorf("failed marshalling room data: %w", err)
 	}
 
 	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
 	defer cancelCtx()
 
-	err = redisClient.HSet(ctx, roomsKey, string(roomId), roomJson).Err()
+	created, err := redisClient.HSetNX(ctx, roomsKey, string(roomId), roomJson).Result()
 	if err != nil {
-		log.Fatalf("Error saving room data to Redis: %s", err)
+		return false, fmt.Errorf("failed saving room data: %w", err)
 	}
+
+	return created, nil
 }
 
 func GetRoom(roomId types.RoomId) (*types.RoomData, bool) {
@@ -254,7 +307,8 @@ func GetRoom(roomId types.RoomId) (*types.RoomData, bool) {
 	return &roomData, true
 }
 
-func GetPopularRooms() ([]types.PopularRoomList, error) {
+// GetPopularRooms returns up to popularRoomsLimit rooms for which match is true
+func GetPopularRooms(match func(room *types.RoomData) bool) ([]types.PopularRoomList, error) {
 	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
 	defer cancelCtx()
 
@@ -264,16 +318,11 @@ func GetPopularRooms() ([]types.PopularRoomList, error) {
 		return nil, fmt.Errorf("failed to get room keys: %v", err)
 	}
 
-	totalRooms := len(roomIds)
-	if totalRooms == 0 {
-		return rooms, nil
-	}
-
-	if totalRooms > popularRoomsLimit {
-		roomIds = roomIds[:popularRoomsLimit]
-	}
-
 	for _, roomId := range roomIds {
+		if len(rooms) == popularRoomsLimit {
+			break
+		}
+
 		roomJSON, err := redisClient.HGet(ctx, roomsKey, roomId).Result()
 		if err != nil {
 			if err == redis.Nil {
@@ -295,12 +344,80 @@ func GetPopularRooms() ([]types.PopularRoomList, error) {
 			continue
 		}
 
-		rooms = append(rooms, types.PopularRoomList{
-			RoomId:      types.RoomId(roomId),
-			RoomName:    roomData.Name,
-			TotalConns:  len(roomData.Users),
-			IsProtected: roomData.IsProtected,
-		})
+		if !match(&roomData) {
+			continue
+		}
+
+		rooms = append(rooms, newRoomListing(types.RoomId(roomId), &roomData))
+	}
+
+	return rooms, nil
+}
+
+// GetRoomListing returns the room as it's shown in the directory
+func GetRoomListing(roomId types.RoomId) (*types.PopularRoomList, bool) {
+	roomData, exists := GetRoom(roomId)
+	if !exists {
+		return nil, false
+	}
+
+	listing := newRoomListing(roomId, roomData)
+	return &listing, true
+}
+
+func newRoomListing(roomId types.RoomId, roomData *types.RoomData) types.PopularRoomList {
+	tags := roomData.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return types.PopularRoomList{
+		RoomId:      roomId,
+		Slug:        roomData.Slug,
+		RoomName:    roomData.Name,
+		TotalConns:  len(roomData.Users),
+		IsProtected: roomData.IsProtected,
+		Description: roomData.Description,
+		Topic:       roomData.Topic,
+		Tags:        tags,
+	}
+}
+
+// GetRooms returns every room
+func GetRooms() (map[types.RoomId]types.RoomData, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	roomsJSON, err := redisClient.HGetAll(ctx, roomsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rooms: %v", err)
+	}
+
+	rooms := make(map[types.RoomId]types.RoomData, len(roomsJSON))
+	for roomId, roomJSON := range roomsJSON {
+		var roomData types.RoomData
+		if err := json.Unmarshal([]byte(roomJSON), &roomData); err != nil {
+			fmt.Printf("failed to unmarshal room JSON for key %s: %v", roomId, err)
+			continue
+		}
+
+		rooms[types.RoomId(roomId)] = roomData
+	}
+
+	return rooms, nil
+}
+
+// GetOwnedRooms returns the rooms created by the account
+func GetOwnedRooms(ownerId uint) (map[types.RoomId]types.RoomData, error) {
+	rooms, err := GetRooms()
+	if err != nil {
+		return nil, err
+	}
+
+	for roomId, roomData := range rooms {
+		if roomData.OwnerID == 0 || roomData.OwnerID != ownerId {
+			delete(rooms, roomId)
+		}
 	}
 
 	return rooms, nil
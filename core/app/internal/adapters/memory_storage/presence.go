@@ -0,0 +1,133 @@
+package memory_storage
+
+import (
+	types "core/types"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// * set of the clients ids of an account, the clients live in clientsKey
+	accountClientsKeyFormat string = "accountclients:%d"
+)
+
+func addAccountClient(accountId uint, clientID types.UserID) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.SAdd(ctx, fmt.Sprintf(accountClientsKeyFormat, accountId), string(clientID)).Err(); err != nil {
+		return fmt.Errorf("could not add account client: %w", err)
+	}
+
+	return nil
+}
+
+func removeAccountClient(accountId uint, clientID types.UserID) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.SRem(ctx, fmt.Sprintf(accountClientsKeyFormat, accountId), string(clientID)).Err(); err != nil {
+		return fmt.Errorf("could not remove account client: %w", err)
+	}
+
+	return nil
+}
+
+// GetAccountClients returns the connected clients of the account on every
+// node. Clients that are gone without cleaning up are dropped from the set.
+func GetAccountClients(accountId uint) ([]types.Client, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	setKey := fmt.Sprintf(accountClientsKeyFormat, accountId)
+
+	clientIds, err := redisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get account clients: %w", err)
+	}
+
+	clients := []types.Client{}
+	for _, clientId := range clientIds {
+		clientJSON, err := redisClient.HGet(ctx, clientsKey, clientId).Result()
+		if err == redis.Nil {
+			redisClient.SRem(ctx, setKey, clientId)
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get client: %w", err)
+		}
+
+		var client types.Client
+		if err := json.Unmarshal([]byte(clientJSON), &client); err != nil {
+			continue
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// GetPresence returns where the account is connected
+func GetPresence(accountId uint) (*types.Presence, error) {
+	clients, err := GetAccountClients(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &types.Presence{
+		AccountID: accountId,
+		Online:    len(clients) > 0,
+	}
+
+	for _, client := range clients {
+		presence.Username = client.Username
+
+		if len(client.RoomId) == 0 {
+			continue
+		}
+
+		presence.RoomId = client.RoomId
+		if room, exists := GetRoom(client.RoomId); exists {
+			presence.RoomName = room.Name
+		}
+
+		break
+	}
+
+	return presence, nil
+}
+
+// DeliverAccounts sends the event to the connections of the accounts on every
+// node
+func DeliverAccounts(accountIds []uint, event string, data interface{}) error {
+	if len(accountIds) == 0 {
+		return nil
+	}
+
+	return PublishControl(types.ControlMessage{
+		Type:       types.ControlDeliverAccounts,
+		AccountIDs: accountIds,
+		Payload: &types.WsPayload{
+			Event: event,
+			Data:  data,
+		},
+	})
+}
+
+// DeliverUser sends the event to the connection of the user, whatever node
+// it's on
+func DeliverUser(userId types.UserID, event string, data interface{}) error {
+	return PublishControl(types.ControlMessage{
+		Type:   types.ControlDeliverUser,
+		UserID: userId,
+		Payload: &types.WsPayload{
+			Event: event,
+			Data:  data,
+		},
+	})
+}
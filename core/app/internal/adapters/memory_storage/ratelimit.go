@@ -0,0 +1,31 @@
+package memory_storage
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	rateLimitKeyFormat string = "ratelimit:%s"
+)
+
+// AllowRate counts a hit for key and reports whether it stays within limit
+// hits per window. The window starts with the first hit.
+func AllowRate(key string, limit int64, window time.Duration) (bool, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	rateKey := fmt.Sprintf(rateLimitKeyFormat, key)
+
+	// * the window is created with its expiration, the counter can't be left
+	// * without one
+	pipe := redisClient.TxPipeline()
+	pipe.SetNX(ctx, rateKey, 0, window)
+	hits := pipe.Incr(ctx, rateKey)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("could not count hit: %w", err)
+	}
+
+	return hits.Val() <= limit, nil
+}
@@ -0,0 +1,191 @@
+package memory_storage
+
+import (
+	"core/types"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	roomQueueKeyFormat         string = "roomqueue:%s"
+	roomPriorityQueueKeyFormat string = "roomqueue:%s:priority" // * users that skip the queue
+	roomReservationsKey        string = "roomreservations"      // * "user id room id" => unix expiration
+)
+
+func roomQueueKeys(roomId types.RoomId) []string {
+	return []string{
+		fmt.Sprintf(roomPriorityQueueKeyFormat, roomId),
+		fmt.Sprintf(roomQueueKeyFormat, roomId),
+	}
+}
+
+// EnqueueRoom adds the user at the end of the queue of the room, the priority
+// users go before the rest
+func EnqueueRoom(roomId types.RoomId, userId types.UserID, priority bool) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	key := fmt.Sprintf(roomQueueKeyFormat, roomId)
+	if priority {
+		key = fmt.Sprintf(roomPriorityQueueKeyFormat, roomId)
+	}
+
+	if err := redisClient.RPush(ctx, key, string(userId)).Err(); err != nil {
+		return fmt.Errorf("could not queue user: %w", err)
+	}
+
+	return nil
+}
+
+// DequeueRoom pops the first user of the queue, it's empty when nobody waits
+func DequeueRoom(roomId types.RoomId) (types.UserID, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	for _, key := range roomQueueKeys(roomId) {
+		userId, err := redisClient.LPop(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("could not dequeue user: %w", err)
+		}
+
+		return types.UserID(userId), nil
+	}
+
+	return "", nil
+}
+
+// GetRoomQueue returns the users waiting for the room in order
+func GetRoomQueue(roomId types.RoomId) ([]types.UserID, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	queue := []types.UserID{}
+	for _, key := range roomQueueKeys(roomId) {
+		userIds, err := redisClient.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("could not get room queue: %w", err)
+		}
+
+		for _, userId := range userIds {
+			queue = append(queue, types.UserID(userId))
+		}
+	}
+
+	return queue, nil
+}
+
+func RemoveFromRoomQueue(roomId types.RoomId, userId types.UserID) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	for _, key := range roomQueueKeys(roomId) {
+		if err := redisClient.LRem(ctx, key, 0, string(userId)).Err(); err != nil {
+			return fmt.Errorf("could not remove user from queue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func DeleteRoomQueue(roomId types.RoomId) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.Del(ctx, roomQueueKeys(roomId)...).Err(); err != nil {
+		return fmt.Errorf("could not delete room queue: %w", err)
+	}
+
+	return nil
+}
+
+// Reservation is a slot of a room held for a user admitted from the queue
+type Reservation struct {
+	RoomId types.RoomId
+	UserId types.UserID
+}
+
+func reservationMember(roomId types.RoomId, userId types.UserID) string {
+	// * the user ids have no spaces, the room ids may have
+	return fmt.Sprintf("%s %s", userId, roomId)
+}
+
+func parseReservationMember(member string) (Reservation, bool) {
+	userId, roomId, found := strings.Cut(member, " ")
+	if !found {
+		return Reservation{}, false
+	}
+
+	return Reservation{
+		RoomId: types.RoomId(roomId),
+		UserId: types.UserID(userId),
+	}, true
+}
+
+// ScheduleReservation records when the slot held for the user expires, every
+// node sees it
+func ScheduleReservation(roomId types.RoomId, userId types.UserID, expiresAt time.Time) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	err := redisClient.ZAdd(ctx, roomReservationsKey, &redis.Z{
+		Score:  float64(expiresAt.Unix()),
+		Member: reservationMember(roomId, userId),
+	}).Err()
+
+	if err != nil {
+		return fmt.Errorf("could not schedule reservation: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimExpiredReservations removes the reservations expired at now and returns
+// them. Each one is returned to a single node.
+func ClaimExpiredReservations(now time.Time) ([]Reservation, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	members, err := redisClient.ZRangeByScore(ctx, roomReservationsKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprint(now.Unix()),
+	}).Result()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get expired reservations: %w", err)
+	}
+
+	claimed := []Reservation{}
+	for _, member := range members {
+		// * another node removed it first
+		if removed, err := redisClient.ZRem(ctx, roomReservationsKey, member).Result(); err != nil || removed == 0 {
+			continue
+		}
+
+		reservation, valid := parseReservationMember(member)
+		if !valid {
+			continue
+		}
+
+		claimed = append(claimed, reservation)
+	}
+
+	return claimed, nil
+}
+
+func DeleteReservation(roomId types.RoomId, userId types.UserID) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.ZRem(ctx, roomReservationsKey, reservationMember(roomId, userId)).Err(); err != nil {
+		return fmt.Errorf("could not delete reservation: %w", err)
+	}
+
+	return nil
+}
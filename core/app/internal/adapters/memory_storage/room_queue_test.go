@@ -0,0 +1,37 @@
+package memory_storage
+
+import (
+	types "core/types"
+	"testing"
+)
+
+func TestReservationMember(t *testing.T) {
+	tests := []struct {
+		name   string
+		roomId types.RoomId
+		userId types.UserID
+	}{
+		{name: "room id", roomId: "room#1", userId: "42"},
+		{name: "room id with spaces", roomId: "my room#1", userId: "42"},
+		{name: "empty room id", roomId: "", userId: "42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation, valid := parseReservationMember(reservationMember(tt.roomId, tt.userId))
+			if !valid {
+				t.Fatal("member isn't valid")
+			}
+
+			if reservation.RoomId != tt.roomId || reservation.UserId != tt.userId {
+				t.Errorf("got %+v, want room %q and user %q", reservation, tt.roomId, tt.userId)
+			}
+		})
+	}
+}
+
+func TestParseInvalidReservationMember(t *testing.T) {
+	if _, valid := parseReservationMember("42"); valid {
+		t.Error("a member without room id must be invalid")
+	}
+}
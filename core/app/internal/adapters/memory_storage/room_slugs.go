@@ -0,0 +1,50 @@
+package memory_storage
+
+import (
+	"core/types"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	roomSlugsKey string = "roomslugs" // * slug => room id
+)
+
+// ReserveRoomSlug points the slug to the room, it reports false when another
+// room has it
+func ReserveRoomSlug(slug string, roomId types.RoomId) (bool, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	reserved, err := redisClient.HSetNX(ctx, roomSlugsKey, slug, string(roomId)).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not reserve room slug: %w", err)
+	}
+
+	return reserved, nil
+}
+
+func GetRoomIdBySlug(slug string) (types.RoomId, bool) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	roomId, err := redisClient.HGet(ctx, roomSlugsKey, slug).Result()
+	if err == redis.Nil || err != nil {
+		return "", false
+	}
+
+	return types.RoomId(roomId), true
+}
+
+func DeleteRoomSlug(slug string) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.HDel(ctx, roomSlugsKey, slug).Err(); err != nil {
+		return fmt.Errorf("could not delete room slug: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package memory_storage
+
+import (
+	"core/types"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// * set of the user ids spectating a room, on every node
+	roomWatchersKeyFormat string = "roomwatchers:%s"
+)
+
+// AddRoomWatcher adds the user to the spectators of the room and returns how
+// many there are with it
+func AddRoomWatcher(roomId types.RoomId, userId types.UserID) (int64, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	key := fmt.Sprintf(roomWatchersKeyFormat, roomId)
+
+	pipe := redisClient.TxPipeline()
+	pipe.SAdd(ctx, key, string(userId))
+	count := pipe.SCard(ctx, key)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("could not add room watcher: %w", err)
+	}
+
+	return count.Val(), nil
+}
+
+func RemoveRoomWatcher(roomId types.RoomId, userId types.UserID) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.SRem(ctx, fmt.Sprintf(roomWatchersKeyFormat, roomId), string(userId)).Err(); err != nil {
+		return fmt.Errorf("could not remove room watcher: %w", err)
+	}
+
+	return nil
+}
+
+// GetRoomWatchers returns the spectators of the room. The users that are gone
+// or watch another room without cleaning up are dropped from the set.
+func GetRoomWatchers(roomId types.RoomId) ([]types.UserID, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	key := fmt.Sprintf(roomWatchersKeyFormat, roomId)
+
+	userIds, err := redisClient.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get room watchers: %w", err)
+	}
+
+	watchers := []types.UserID{}
+	for _, userId := range userIds {
+		clientJSON, err := redisClient.HGet(ctx, clientsKey, userId).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("could not get client: %w", err)
+		}
+
+		var client types.Client
+		if err == redis.Nil || json.Unmarshal([]byte(clientJSON), &client) != nil || client.WatchRoomId != roomId {
+			redisClient.SRem(ctx, key, userId)
+			continue
+		}
+
+		watchers = append(watchers, types.UserID(userId))
+	}
+
+	return watchers, nil
+}
+
+func DeleteRoomWatchers(roomId types.RoomId) error {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	if err := redisClient.Del(ctx, fmt.Sprintf(roomWatchersKeyFormat, roomId)).Err(); err != nil {
+		return fmt.Errorf("could not delete room watchers: %w", err)
+	}
+
+	return nil
+}
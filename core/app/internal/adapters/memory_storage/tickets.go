@@ -0,0 +1,70 @@
+package memory_storage
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	wsTicketKeyFormat string = "wsticket:%s"
+)
+
+var (
+	ErrorTicketNotFound = errors.New("ticket not found or already used")
+)
+
+// CreateTicket stores a single use ticket that authenticates the account on
+// the websocket upgrade, the connection belongs to the login session that
+// issued it
+func CreateTicket(accountId uint, sessionId string, ttl time.Duration) (string, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate ticket: %w", err)
+	}
+
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+
+	value := fmt.Sprintf("%d:%s", accountId, sessionId)
+
+	err := redisClient.Set(ctx, fmt.Sprintf(wsTicketKeyFormat, ticket), value, ttl).Err()
+	if err != nil {
+		return "", fmt.Errorf("could not save ticket: %w", err)
+	}
+
+	return ticket, nil
+}
+
+// RedeemTicket returns the account and the session of the ticket and deletes
+// it
+func RedeemTicket(ticket string) (uint, string, error) {
+	ctx, cancelCtx := NewContextWithTimeout(10 * time.Second)
+	defer cancelCtx()
+
+	value, err := redisClient.GetDel(ctx, fmt.Sprintf(wsTicketKeyFormat, ticket)).Result()
+	if err == redis.Nil {
+		return 0, "", ErrorTicketNotFound
+	}
+
+	if err != nil {
+		return 0, "", fmt.Errorf("could not get ticket: %w", err)
+	}
+
+	rawAccountId, sessionId, _ := strings.Cut(value, ":")
+
+	accountId, err := strconv.ParseUint(rawAccountId, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid ticket value: %w", err)
+	}
+
+	return uint(accountId), sessionId, nil
+}
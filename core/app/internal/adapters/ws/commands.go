@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"core/internal/core/services"
+	"core/types"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// commandCall is a chat command sent by a connection
+type commandCall struct {
+	roomId types.RoomId
+	role   services.RoomRole // * room role of the sender
+	args   string
+}
+
+type commandHandler func(conn *connection, call commandCall) error
+
+type chatCommand struct {
+	usage  string
+	role   services.RoomRole // * lowest room role allowed to run it
+	handle commandHandler
+}
+
+var (
+	chatCommands = map[string]chatCommand{}
+	commandNames []string // * by order of registration, listed by /help
+)
+
+// command registers a chat command, the chat messages starting with "/name"
+// run it instead of being broadcast
+func command(name string, usage string, role services.RoomRole, handle commandHandler) {
+	chatCommands[name] = chatCommand{
+		usage:  usage,
+		role:   role,
+		handle: handle,
+	}
+
+	commandNames = append(commandNames, name)
+}
+
+// runCommand runs the chat command of msg, like the event handlers its errors
+// only go back to the connection
+func (conn *connection) runCommand(msg string) error {
+	roomId, err := conn.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	name, args := parseCommand(msg)
+
+	cmd, exists := chatCommands[name]
+	if !exists {
+		return services.ErrorUnknownCommand
+	}
+
+	role, err := services.UserRoomRole(roomId, conn.mc.Client)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(conn, commandCall{
+		roomId: roomId,
+		role:   role,
+		args:   args,
+	})
+}
+
+// parseCommand splits "/name args" into the lowercase name and the arguments
+func parseCommand(msg string) (string, string) {
+	name, args, _ := strings.Cut(strings.TrimPrefix(msg, "/"), " ")
+	return strings.ToLower(name), strings.TrimSpace(args)
+}
+
+// run checks the role of the sender before handling the call, the usage
+// errors get the usage of the command
+func (cmd chatCommand) run(conn *connection, call commandCall) error {
+	if call.role < cmd.role {
+		return services.ErrorCommandForbidden
+	}
+
+	err := cmd.handle(conn, call)
+	if errors.Is(err, services.ErrorCommandUsage) {
+		return fmt.Errorf("%w: %s", err, cmd.usage)
+	}
+
+	return err
+}
+
+// commandUsages returns the usage of the commands the role can run
+func commandUsages(role services.RoomRole) []string {
+	usages := make([]string, 0, len(commandNames))
+	for _, name := range commandNames {
+		if cmd := chatCommands[name]; role >= cmd.role {
+			usages = append(usages, cmd.usage)
+		}
+	}
+
+	return usages
+}
+
+func handleMeCommand(conn *connection, call commandCall) error {
+	if call.args == "" {
+		return services.ErrorCommandUsage
+	}
+
+	return services.BroadcastAction(types.Msg{
+		From:   conn.userId,
+		RoomId: call.roomId,
+		Msg:    call.args,
+	}, conn.mc, conn.userId)
+}
+
+func handleWhisperCommand(conn *connection, call commandCall) error {
+	userName, msg, _ := strings.Cut(call.args, " ")
+	if userName == "" || strings.TrimSpace(msg) == "" {
+		return services.ErrorCommandUsage
+	}
+
+	user, err := services.FindRoomUser(call.roomId, userName)
+	if err != nil {
+		return err
+	}
+
+	return services.Whisper(types.DirectMsg{
+		Msg:      strings.TrimSpace(msg),
+		ToUserId: user.UserID,
+	}, conn.mc, conn.userId)
+}
+
+func handleRollCommand(conn *connection, call commandCall) error {
+	sides := services.DefaultRollSides
+	if call.args != "" {
+		var err error
+		if sides, err = strconv.Atoi(call.args); err != nil {
+			return services.ErrorCommandUsage
+		}
+	}
+
+	return services.Roll(call.roomId, conn.mc, conn.userId, sides)
+}
+
+func handleKickCommand(conn *connection, call commandCall) error {
+	if call.args == "" {
+		return services.ErrorCommandUsage
+	}
+
+	user, err := services.FindRoomUser(call.roomId, call.args)
+	if err != nil {
+		return err
+	}
+
+	return services.KickUser(call.roomId, conn.mc.Client, user.UserID)
+}
+
+func handleMuteCommand(conn *connection, call commandCall) error {
+	if call.args == "" {
+		return services.ErrorCommandUsage
+	}
+
+	user, err := services.FindRoomUser(call.roomId, call.args)
+	if err != nil {
+		return err
+	}
+
+	return services.MuteUser(conn.mc, conn.userId, user.UserID, true)
+}
+
+func handleTopicCommand(conn *connection, call commandCall) error {
+	return services.SetTopic(call.roomId, conn.mc.Client, call.args)
+}
+
+// handleHelpCommand lists the commands the sender can run in the room
+func handleHelpCommand(conn *connection, call commandCall) error {
+	return services.SendUserSystemMessage(conn.mc, "Commands: "+strings.Join(commandUsages(call.role), ", "))
+}
@@ -0,0 +1,138 @@
+package ws
+
+import (
+	"core/internal/core/services"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		msg      string
+		wantName string
+		wantArgs string
+	}{
+		{msg: "/help", wantName: "help"},
+		{msg: "/ROLL 20", wantName: "roll", wantArgs: "20"},
+		{msg: "/whisper Bob  see you later ", wantName: "whisper", wantArgs: "Bob  see you later"},
+		{msg: "/me   waves", wantName: "me", wantArgs: "waves"},
+		{msg: "/", wantName: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			name, args := parseCommand(tt.msg)
+			if name != tt.wantName || args != tt.wantArgs {
+				t.Errorf("got %q %q, want %q %q", name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRunCommandRole(t *testing.T) {
+	var handled bool
+	cmd := chatCommand{
+		usage: "/test <arg>",
+		role:  services.RoomOwner,
+		handle: func(conn *connection, call commandCall) error {
+			handled = true
+			if call.args == "" {
+				return services.ErrorCommandUsage
+			}
+
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		role        services.RoomRole
+		args        string
+		wantErr     error
+		wantHandled bool
+	}{
+		{name: "member", role: services.RoomMember, args: "x", wantErr: services.ErrorCommandForbidden},
+		{name: "owner", role: services.RoomOwner, args: "x", wantHandled: true},
+		{name: "staff", role: services.RoomStaff, args: "x", wantHandled: true},
+		{name: "usage error", role: services.RoomOwner, wantErr: services.ErrorCommandUsage, wantHandled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled = false
+
+			err := cmd.run(nil, commandCall{role: tt.role, args: tt.args})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("handled %v, want %v", handled, tt.wantHandled)
+			}
+
+			// * the sender is told how to use the command
+			if errors.Is(err, services.ErrorCommandUsage) && !strings.HasSuffix(err.Error(), cmd.usage) {
+				t.Errorf("usage error %q doesn't give the usage", err)
+			}
+		})
+	}
+}
+
+func TestCommandUsageErrors(t *testing.T) {
+	// * the arguments are checked before the room is looked up
+	tests := []struct {
+		name string
+		args string
+	}{
+		{name: "me", args: ""},
+		{name: "whisper", args: ""},
+		{name: "whisper", args: "Bob"},
+		{name: "whisper", args: "Bob   "},
+		{name: "roll", args: "many"},
+		{name: "kick", args: ""},
+		{name: "mute", args: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.args, func(t *testing.T) {
+			cmd, exists := chatCommands[tt.name]
+			if !exists {
+				t.Fatalf("command %s isn't registered", tt.name)
+			}
+
+			err := cmd.run(nil, commandCall{role: services.RoomStaff, args: tt.args})
+			if !errors.Is(err, services.ErrorCommandUsage) {
+				t.Fatalf("got err %v, want %v", err, services.ErrorCommandUsage)
+			}
+
+			if !strings.HasSuffix(err.Error(), cmd.usage) {
+				t.Errorf("usage error %q doesn't give the usage", err)
+			}
+		})
+	}
+}
+
+func TestCommandUsages(t *testing.T) {
+	memberUsages := []string{"/me <action>", "/whisper <user> <message>", "/roll [sides]", "/mute <user>", "/help"}
+	ownerUsages := []string{"/me <action>", "/whisper <user> <message>", "/roll [sides]", "/mute <user>", "/kick <user>", "/topic [text]", "/help"}
+
+	tests := []struct {
+		name string
+		role services.RoomRole
+		want []string
+	}{
+		{name: "member", role: services.RoomMember, want: memberUsages},
+		{name: "owner", role: services.RoomOwner, want: ownerUsages},
+		{name: "staff", role: services.RoomStaff, want: ownerUsages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandUsages(tt.role); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
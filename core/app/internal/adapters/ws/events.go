@@ -0,0 +1,434 @@
+package ws
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	"core/internal/core/services"
+	"core/internal/core/wire"
+	"core/types"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// connection is the state of a websocket connection shared by its event
+// handlers
+type connection struct {
+	handler    *WebSocketHandler
+	mc         *types.MessageClient
+	codec      wire.Codec // * negotiated through the websocket subprotocol
+	userId     types.UserID
+	session    *types.Session
+	expiration *time.Timer // * closes the connection when the session expires, nil for guests
+}
+
+type eventHandler func(conn *connection, data []byte) error
+
+// eventHandlers maps the event names to their handlers, errors returned by
+// a handler are sent back to the client as an "error" event
+var eventHandlers = map[string]eventHandler{}
+
+var (
+	// * dropped frames, they aren't answered with an "error" event
+	errInvalidFrame = errors.New("invalid frame")
+	errUnknownEvent = errors.New("unknown event")
+)
+
+// dispatch decodes the frame with the connection's codec and runs the handler
+// of its event
+func (conn *connection) dispatch(frame []byte) error {
+	payload, err := conn.codec.DecodeEnvelope(frame)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errInvalidFrame, conn.codec.Subprotocol(), err)
+	}
+
+	handle, exists := eventHandlers[payload.Event]
+	if !exists {
+		return fmt.Errorf("%w: %q", errUnknownEvent, payload.Event)
+	}
+
+	return handle(conn, payload.Data)
+}
+
+// on registers a typed handler, the event data is decoded into T with the
+// connection's codec before calling it
+func on[T any](event string, handle func(conn *connection, reqData T) error) {
+	eventHandlers[event] = func(conn *connection, data []byte) error {
+		var reqData T
+		if err := parsePayload(conn.codec, data, &reqData); err != nil {
+			return err
+		}
+
+		return handle(conn, reqData)
+	}
+}
+
+func init() {
+	on("newRoom", handleNewRoom)
+	on("joinRoom", handleJoinRoom)
+	on("broadcastMessage", handleBroadcastMessage)
+	on("updatePosition", handleUpdatePosition)
+	on("updateTyping", handleUpdateTyping)
+	on("emote", handleEmote)
+	on("leaveRoom", handleLeaveRoom)
+	on("authenticate", handleAuthenticate)
+	on("joinFriend", handleJoinFriend)
+	on("whisper", handleWhisper)
+	on("muteUser", handleMuteUser)
+	on("unmuteUser", handleUnmuteUser)
+	on("report", handleReport)
+	on("createInvite", handleCreateInvite)
+	on("joinQueue", handleJoinQueue)
+	on("leaveQueue", handleLeaveQueue)
+	on("watchRoom", handleWatchRoom)
+	on("unwatchRoom", handleUnwatchRoom)
+
+	// * chat messages starting with "/"
+	command("me", "/me <action>", services.RoomMember, handleMeCommand)
+	command("whisper", "/whisper <user> <message>", services.RoomMember, handleWhisperCommand)
+	command("roll", "/roll [sides]", services.RoomMember, handleRollCommand)
+	command("mute", "/mute <user>", services.RoomMember, handleMuteCommand)
+	command("kick", "/kick <user>", services.RoomOwner, handleKickCommand)
+	command("topic", "/topic [text]", services.RoomOwner, handleTopicCommand)
+	command("help", "/help", services.RoomMember, handleHelpCommand)
+}
+
+// watchExpiration closes the connection once the credentials of its session
+// expire
+func (conn *connection) watchExpiration() {
+	if conn.session.IsGuest() {
+		return
+	}
+
+	if conn.expiration != nil {
+		conn.expiration.Stop()
+	}
+
+	userConn := conn.mc.Client.Conn
+	conn.expiration = time.AfterFunc(time.Until(conn.session.ExpiresAt), func() {
+		closeWithCode(userConn, CloseTokenExpired, core.ErrorTokenHasExpired.Error())
+	})
+}
+
+// joinFriendsPresence sends the presence of the friends to the connection and
+// tells the friends the account is online
+func (conn *connection) joinFriendsPresence() {
+	if conn.session.IsGuest() {
+		return
+	}
+
+	presence, err := conn.handler.Friends.FriendsPresence(conn.session.AccountID)
+	if err != nil {
+		fmt.Printf("failed to get friends presence: %v\n", err)
+	} else {
+		trySend(conn.mc, types.WsPayload{
+			Event: services.FriendsPresenceEvent,
+			Data:  presence,
+		})
+	}
+
+	conn.notifyPresence()
+}
+
+// loadBlocked fills the message filter with the accounts blocked in either
+// direction
+func (conn *connection) loadBlocked() {
+	if conn.session.IsGuest() {
+		return
+	}
+
+	blockedIds, err := conn.handler.Friends.BlockedIds(conn.session.AccountID)
+	if err != nil {
+		fmt.Printf("failed to get blocked accounts: %v\n", err)
+		return
+	}
+
+	conn.mc.Filter.SetBlocked(blockedIds)
+}
+
+// notifyPresence tells the friends where the account is now
+func (conn *connection) notifyPresence() {
+	if conn.session.IsGuest() {
+		return
+	}
+
+	conn.handler.Friends.NotifyPresence(conn.session.AccountID)
+}
+
+// currentRoom returns the room the connection is in
+func (conn *connection) currentRoom() (types.RoomId, error) {
+	client, err := memory_storage.GetClient(conn.userId)
+	if err != nil || len(client.RoomId) == 0 {
+		return "", services.ErrorUserNotInRoom
+	}
+
+	return client.RoomId, nil
+}
+
+// isOwnerFriend reports whether the account of the connection is a friend of
+// the owner of the room
+func (conn *connection) isOwnerFriend(roomId types.RoomId) bool {
+	if conn.session.IsGuest() {
+		return false
+	}
+
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists || room.OwnerID == 0 {
+		return false
+	}
+
+	return conn.handler.Friends.AreFriends(conn.session.AccountID, room.OwnerID)
+}
+
+// userName is the name the connection shows in rooms, accounts always use
+// their username
+func (conn *connection) userName(requested string) (string, error) {
+	if !conn.session.IsGuest() {
+		return conn.session.Username, nil
+	}
+
+	return conn.handler.User.GuestName(requested)
+}
+
+func handleNewRoom(conn *connection, reqData types.NewRoom) error {
+	userName, err := conn.userName(reqData.UserName)
+	if err != nil {
+		return err
+	}
+
+	reqData.UserName = userName
+
+	if err := services.NewRoom(reqData, conn.mc, conn.userId); err != nil {
+		return err
+	}
+
+	conn.notifyPresence()
+
+	return nil
+}
+
+func handleJoinRoom(conn *connection, reqData types.JoinRoom) error {
+	userName, err := conn.userName(reqData.UserName)
+	if err != nil {
+		return err
+	}
+
+	reqData.UserName = userName
+
+	if err := services.JoinRoom(reqData, conn.mc, conn.userId); err != nil {
+		return err
+	}
+
+	conn.notifyPresence()
+
+	return nil
+}
+
+// handleJoinQueue waits for a slot of a full room, the user gets a
+// "queueReady" event once it can send joinRoom
+func handleJoinQueue(conn *connection, reqData types.JoinRoom) error {
+	userName, err := conn.userName(reqData.UserName)
+	if err != nil {
+		return err
+	}
+
+	reqData.UserName = userName
+
+	return services.JoinQueue(reqData, conn.mc, conn.userId, conn.isOwnerFriend(reqData.RoomId))
+}
+
+func handleLeaveQueue(conn *connection, reqData types.LeaveQueue) error {
+	services.LeaveQueue(conn.userId)
+
+	return nil
+}
+
+// handleWatchRoom subscribes the connection to a room it's not in, the
+// spectators get its events without an avatar
+func handleWatchRoom(conn *connection, reqData types.WatchRoom) error {
+	return services.WatchRoom(reqData, conn.mc, conn.userId)
+}
+
+func handleUnwatchRoom(conn *connection, reqData types.UnwatchRoom) error {
+	services.UnwatchRoom(conn.userId)
+
+	return nil
+}
+
+func handleBroadcastMessage(conn *connection, reqData types.Msg) error {
+	// * messages are always sent on behalf of the connection
+	reqData.From = conn.userId
+
+	if strings.HasPrefix(reqData.Msg, "/") {
+		return conn.runCommand(reqData.Msg)
+	}
+
+	return services.BroadcastMessage(reqData, conn.mc, conn.userId)
+}
+
+// * the room events below go to the room of the connection, whatever the
+// * roomId sent by the client
+
+func handleUpdatePosition(conn *connection, reqData types.UpdateUserPos) error {
+	roomId, err := conn.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	services.UpdateUserPosition(roomId, conn.userId, reqData.Dest)
+
+	return nil
+}
+
+func handleUpdateTyping(conn *connection, reqData types.UpdateUserTyping) error {
+	roomId, err := conn.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	services.UpdateUserTyping(roomId, conn.userId, reqData.IsTyping)
+
+	return nil
+}
+
+func handleEmote(conn *connection, reqData types.UserEmote) error {
+	roomId, err := conn.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	return services.UpdateUserEmote(roomId, conn.userId, reqData.Emote)
+}
+
+func handleLeaveRoom(conn *connection, reqData types.UserLeave) error {
+	// * users can only leave on their own behalf
+	reqData.UserId = string(conn.userId)
+
+	services.LeaveRoom(reqData, conn.userId)
+	conn.notifyPresence()
+
+	return nil
+}
+
+// handleAuthenticate signs a guest connection in without leaving its room
+func handleAuthenticate(conn *connection, reqData types.Authenticate) error {
+	if !conn.session.IsGuest() {
+		return services.ErrorAlreadyAuthenticated
+	}
+
+	if reqData.Ticket == "" {
+		return services.ErrorInvalidTicket
+	}
+
+	session, err := conn.handler.User.AuthenticateWs(services.WsCredentials{
+		Ticket: reqData.Ticket,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	avatar := conn.handler.User.GetAvatar(session.AccountID)
+
+	// * the message client shares the session
+	conn.mc.SetIdentity(*session, avatar)
+
+	conn.watchExpiration()
+
+	if err := services.UpgradeGuest(conn.userId, session, avatar); err != nil {
+		return err
+	}
+
+	conn.loadBlocked()
+	conn.joinFriendsPresence()
+
+	return nil
+}
+
+// handleJoinFriend joins the room a friend is in, the room password is still
+// required
+func handleJoinFriend(conn *connection, reqData types.JoinFriend) error {
+	if conn.session.IsGuest() {
+		return services.ErrorSignInRequired
+	}
+
+	presence, err := conn.handler.Friends.FriendPresence(conn.session.AccountID, reqData.AccountID)
+	if err != nil {
+		return err
+	}
+
+	if len(presence.RoomId) == 0 {
+		return services.ErrorFriendNotInRoom
+	}
+
+	return handleJoinRoom(conn, types.JoinRoom{
+		RoomId:   presence.RoomId,
+		Password: reqData.Password,
+	})
+}
+
+func handleWhisper(conn *connection, reqData types.DirectMsg) error {
+	return services.Whisper(reqData, conn.mc, conn.userId)
+}
+
+func handleMuteUser(conn *connection, reqData types.MuteUser) error {
+	return services.MuteUser(conn.mc, conn.userId, reqData.UserID, true)
+}
+
+func handleUnmuteUser(conn *connection, reqData types.MuteUser) error {
+	return services.MuteUser(conn.mc, conn.userId, reqData.UserID, false)
+}
+
+// handleReport reports a user of the connection's room
+func handleReport(conn *connection, reqData types.ReportUser) error {
+	if conn.session.IsGuest() {
+		return services.ErrorSignInRequired
+	}
+
+	roomId, err := conn.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	report, err := conn.handler.Moderation.Report(conn.session.AccountID, roomId, reqData)
+	if err != nil {
+		return err
+	}
+
+	type ReportReceived struct {
+		ID     uint   `json:"id"`
+		Status string `json:"status"`
+	}
+
+	trySend(conn.mc, types.WsPayload{
+		Event: "reportReceived",
+		Data:  ReportReceived{ID: report.ID, Status: report.Status},
+	})
+
+	return nil
+}
+
+// handleCreateInvite sends back an invite to the room, only the room owner
+// and the staff get one
+func handleCreateInvite(conn *connection, reqData types.CreateInvite) error {
+	roomId := reqData.RoomId
+	if len(roomId) == 0 {
+		var err error
+		if roomId, err = conn.currentRoom(); err != nil {
+			return err
+		}
+	}
+
+	invite, err := services.CreateInvite(roomId, conn.mc.Client, time.Duration(reqData.ExpiresIn)*time.Minute)
+	if err != nil {
+		return err
+	}
+
+	trySend(conn.mc, types.WsPayload{
+		Event: "inviteCreated",
+		Data:  invite,
+	})
+
+	return nil
+}
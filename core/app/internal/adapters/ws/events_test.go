@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"core/internal/core/wire"
+	"core/types"
+	"errors"
+	"testing"
+)
+
+type echoRequest struct {
+	Value string `json:"value"`
+}
+
+func TestDispatch(t *testing.T) {
+	var received *echoRequest
+	on("testEcho", func(conn *connection, reqData echoRequest) error {
+		received = &reqData
+		return nil
+	})
+	t.Cleanup(func() { delete(eventHandlers, "testEcho") })
+
+	for _, codec := range []wire.Codec{wire.JSON, wire.MsgPack} {
+		conn := &connection{codec: codec}
+
+		frame := func(event string, data interface{}) []byte {
+			encoded, err := codec.Marshal(types.WsPayload{Event: event, Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return encoded
+		}
+
+		tests := []struct {
+			name         string
+			frame        []byte
+			wantErr      error
+			wantReceived string
+		}{
+			{name: "known event", frame: frame("testEcho", echoRequest{Value: "boo"}), wantReceived: "boo"},
+			{name: "known event without data", frame: frame("testEcho", nil), wantReceived: ""},
+			{name: "unknown event", frame: frame("haunt", echoRequest{Value: "boo"}), wantErr: errUnknownEvent},
+			{name: "no event", frame: frame("", nil), wantErr: errUnknownEvent},
+			{name: "invalid frame", frame: []byte{0xc1}, wantErr: errInvalidFrame},
+			{name: "empty frame", frame: []byte{}, wantErr: errInvalidFrame},
+		}
+
+		for _, tt := range tests {
+			t.Run(codec.Subprotocol()+" "+tt.name, func(t *testing.T) {
+				received = nil
+
+				err := conn.dispatch(tt.frame)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("got err %v, want %v", err, tt.wantErr)
+					}
+
+					if received != nil {
+						t.Errorf("handler ran with %+v", received)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if received == nil || received.Value != tt.wantReceived {
+					t.Errorf("handler got %+v, want %q", received, tt.wantReceived)
+				}
+			})
+		}
+	}
+}
+
+func TestDispatchInvalidData(t *testing.T) {
+	on("testEcho", func(conn *connection, reqData echoRequest) error {
+		t.Errorf("handler ran with %+v", reqData)
+		return nil
+	})
+	t.Cleanup(func() { delete(eventHandlers, "testEcho") })
+
+	for _, codec := range []wire.Codec{wire.JSON, wire.MsgPack} {
+		t.Run(codec.Subprotocol(), func(t *testing.T) {
+			frame, err := codec.Marshal(types.WsPayload{Event: "testEcho", Data: []int{1, 2}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// * the data errors go back to the client, unlike the dropped frames
+			err = (&connection{codec: codec}).dispatch(frame)
+			if err == nil || errors.Is(err, errInvalidFrame) || errors.Is(err, errUnknownEvent) {
+				t.Fatalf("got err %v, want a data error", err)
+			}
+		})
+	}
+}
@@ -2,17 +2,21 @@ package ws
 
 import (
 	"core/config"
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/http/middleware"
 	"core/internal/adapters/memory_storage"
 	"core/internal/core"
 	"core/internal/core/services"
+	"core/internal/core/wire"
 	util "core/internal/utils"
 	"core/types"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -20,6 +24,10 @@ import (
 
 // Upgrader is used to upgrade an HTTP connection to a WebSocket connection.
 var upgrader = websocket.Upgrader{
+	Subprotocols:      wire.Subprotocols(),
+	ReadBufferSize:    config.WsReadBufferSize,
+	WriteBufferSize:   config.WsWriteBufferSize,
+	EnableCompression: config.WsCompression, // * negotiates permessage-deflate when the client supports it
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("origin")
 
@@ -39,11 +47,70 @@ var upgrader = websocket.Upgrader{
 }
 
 var (
-	activeConnections sync.Map
+	activeConnections sync.Map // * types.UserID => *types.MessageClient of this node
 )
 
+const (
+	// * room events are dropped for a client whose buffer is full instead of
+	// * blocking the fan-out to the rest of the room
+	sendBufferSize = 256
+	closeWriteWait = time.Second
+
+	// * application close codes (4000-4999)
+	CloseTokenExpired   = 4001
+	CloseSessionRevoked = 4002
+	CloseUnauthorized   = 4003
+)
+
+type WebSocketHandler struct {
+	User       *services.UserService
+	Friends    *services.FriendService
+	Moderation *services.ModerationService
+}
+
+func NewWebSocketHandler(userService *services.UserService, friendService *services.FriendService, moderationService *services.ModerationService) *WebSocketHandler {
+	return &WebSocketHandler{
+		User:       userService,
+		Friends:    friendService,
+		Moderation: moderationService,
+	}
+}
+
+// IssueTicket
+// @Summary Get a single use ticket to authenticate the websocket upgrade
+//
+//	@Description  The ticket is sent as the "ticket" query param of /ws
+//	@Tags         ws
+//
+// @Success      200  {object}  services.WsTicketResponse "Success response"
+// @Failure      500  {object}  types.ErrorResponse
+// @Router /api/v1/ws/ticket [post]
+func (ctx *WebSocketHandler) IssueTicket(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, types.ApiErrorCode(types.ErrorCodeUnauthorized, services.ErrorUnauthorized))
+		return
+	}
+
+	userPtr, ok := user.(*models.User)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, types.ApiErrorCode(types.ErrorCodeInternal, services.ErrorUnauthorized))
+		return
+	}
+
+	ticket, err := ctx.User.IssueWsTicket(userPtr.ID, c.GetString(middleware.ContextSessionKey))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, types.ApiErrorCode(types.ErrorCodeInternal, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
 // HandleWebSocket handles incoming WebSocket connections.
-func HandleWebSocket(c *gin.Context) {
+func (ctx *WebSocketHandler) HandleWebSocket(c *gin.Context) {
+	// * authenticate once on the upgrade, before reading any event
+	session, authErr := ctx.User.AuthenticateWs(wsCredentials(c))
 
 	userConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -51,6 +118,21 @@ func HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	if authErr != nil {
+		log.Printf("Rejecting connection from %s: %v", userConn.RemoteAddr(), authErr)
+		closeWithCode(userConn, authCloseCode(authErr), authErr.Error())
+		return
+	}
+
+	// * frames over the limit close the connection with 1009 (message too big)
+	userConn.SetReadLimit(int64(config.WsReadLimit))
+
+	if config.WsCompression {
+		if err := userConn.SetCompressionLevel(config.WsCompressionLevel); err != nil {
+			log.Printf("Invalid compression level %d: %v", config.WsCompressionLevel, err)
+		}
+	}
+
 	id, err := util.GetRandomId()
 	userId := types.UserID(id)
 
@@ -60,16 +142,27 @@ func HandleWebSocket(c *gin.Context) {
 
 	// Create a new client
 	client := &types.Client{
-		ID:       userId,
-		RoomId:   "",
-		Username: "",
-		Conn:     userConn,
+		ID:        userId,
+		AccountID: session.AccountID,
+		RoomId:    "",
+		Username:  session.Username,
+		Avatar:    services.RandomAvatar(), // * guests get a random avatar for the whole connection
+		Conn:      userConn,
+		Role:      session.Role,
+	}
+
+	if !session.IsGuest() {
+		// * accounts look the same on every room
+		client.Avatar = ctx.User.GetAvatar(session.AccountID)
 	}
 
 	messageClient := &types.MessageClient{
-		Client: client,
-		Send:   make(chan []byte),
-		ConnMu: sync.Mutex{},
+		Client:      client,
+		Session:     session,
+		Subprotocol: userConn.Subprotocol(),
+		Send:        make(chan []byte, sendBufferSize),
+		ConnMu:      sync.Mutex{},
+		IP:          c.ClientIP(), // * same address as the IP ban middleware
 	}
 
 	// ! goroutines
@@ -78,7 +171,7 @@ func HandleWebSocket(c *gin.Context) {
 	// borrar salas vacias
 
 	// * Register the new client to Redis
-	activeConnections.Store(userId, client)
+	activeConnections.Store(userId, messageClient)
 	memory_storage.AddClient(client)
 	log.Println("A user connected:", userConn.RemoteAddr())
 
@@ -89,8 +182,11 @@ func HandleWebSocket(c *gin.Context) {
 			return
 		}
 
-		// 1. Remove user from the room info
+		// 1. Remove user from the room info, the queue it waits in and the
+		// room it watches
 		services.RemoveUser(user.ID, user.RoomId)
+		services.LeaveQueue(user.ID)
+		services.UnwatchRoom(user.ID)
 
 		// 2. close the ws connection
 		userConn.Close()
@@ -101,114 +197,217 @@ func HandleWebSocket(c *gin.Context) {
 		// 4. delete the client from redis
 		memory_storage.DeleteClient(userId)
 
+		// 5. tell the friends the account went offline
+		ctx.Friends.NotifyPresence(client.AccountID)
+	}()
+
+	conn := &connection{
+		handler: ctx,
+		mc:      messageClient,
+		codec:   wire.FromSubprotocol(messageClient.Subprotocol),
+		userId:  userId,
+		session: session,
+	}
+
+	// * close the connection once the credentials it was opened with expire
+	conn.watchExpiration()
+	defer func() {
+		if conn.expiration != nil {
+			conn.expiration.Stop()
+		}
 	}()
 
+	conn.loadBlocked()
+	conn.joinFriendsPresence()
+
 	// Main loop to listen for messages
 	for {
-		var payload types.WsPayload
+		_, frame, err := userConn.ReadMessage()
+		if errors.Is(err, websocket.ErrReadLimit) {
+			log.Printf("Closing connection %s: message exceeds %d bytes", userId, config.WsReadLimit)
+			break
+		}
 
-		err := userConn.ReadJSON(&payload)
 		if err != nil {
-			fmt.Printf("Error reading JSON: %v", err)
+			fmt.Printf("Error reading message: %v", err)
 			fmt.Printf("User is leaving: %v", userId)
 
 			break
 		}
 
-		var username string
-		authorization := payload.Authorization
-		if authorization != "" {
-			user, err := core.DecodeToken(authorization)
-			fmt.Printf("user %v\n", user)
-
-			if err != nil {
-				fmt.Printf("unauthorized")
-			}
+		err = conn.dispatch(frame)
+		if errors.Is(err, errInvalidFrame) || errors.Is(err, errUnknownEvent) {
+			log.Printf("Dropping frame of %s: %v\n", userId, err)
+			continue
+		}
 
-			username = user.Username
+		if err != nil {
+			services.SendPayload(messageClient, types.WsPayload{
+				Event: "error",
+				Data:  types.ApiError(err),
+			})
 		}
+	}
+}
 
-		switch payload.Event {
-		case "newRoom":
-			var reqData types.NewRoom
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s\n", err)
-			}
+// ListenControl handles the control messages published by any node
+func ListenControl() error {
+	return memory_storage.SubscribeControl(handleControl)
+}
 
-			services.NewRoom(reqData, messageClient, userId)
+func handleControl(msg types.ControlMessage) {
+	switch msg.Type {
+	case types.ControlDisconnectAccount:
+		activeConnections.Range(func(_, value any) bool {
+			mc := value.(*types.MessageClient)
+			if accountId := mc.AccountID(); accountId != 0 && accountId == msg.AccountID {
+				closeWithCode(mc.Client.Conn, CloseSessionRevoked, msg.Reason)
+			}
 
-		case "joinRoom":
-			var reqData types.JoinRoom
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s\n", err)
+			return true
+		})
+	case types.ControlDisconnectSession:
+		activeConnections.Range(func(_, value any) bool {
+			mc := value.(*types.MessageClient)
+			if sessionId := mc.SessionID(); sessionId != "" && sessionId == msg.SessionID {
+				closeWithCode(mc.Client.Conn, CloseSessionRevoked, msg.Reason)
 			}
 
-			if username != "" {
-				reqData.UserName = username
+			return true
+		})
+	case types.ControlDeliverAccounts:
+		if msg.Payload == nil {
+			return
+		}
+
+		accounts := make(map[uint]struct{}, len(msg.AccountIDs))
+		for _, accountId := range msg.AccountIDs {
+			accounts[accountId] = struct{}{}
+		}
+
+		activeConnections.Range(func(_, value any) bool {
+			mc := value.(*types.MessageClient)
+			accountId := mc.AccountID()
+			if _, exists := accounts[accountId]; exists && accountId != 0 {
+				trySend(mc, *msg.Payload)
 			}
 
-			err = services.JoinRoom(reqData, messageClient, userId)
-			if err != nil {
-				services.SendPayload(messageClient, types.WsPayload{
-					Event: "error",
-					Data:  types.ApiError(err),
-				})
+			return true
+		})
+	case types.ControlDeliverUser:
+		if msg.Payload == nil {
+			return
+		}
+
+		if value, exists := activeConnections.Load(msg.UserID); exists {
+			trySend(value.(*types.MessageClient), *msg.Payload)
+		}
+	case types.ControlAccountBlocked:
+		// * a block hides the messages both ways
+		activeConnections.Range(func(_, value any) bool {
+			mc := value.(*types.MessageClient)
+			switch accountId := mc.AccountID(); {
+			case accountId == 0:
+			case accountId == msg.AccountID:
+				mc.Filter.Block(msg.TargetAccountID, msg.Blocked)
+			case accountId == msg.TargetAccountID:
+				mc.Filter.Block(msg.AccountID, msg.Blocked)
 			}
 
-		case "broadcastMessage":
-			var reqData types.Msg
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s\n", err)
-				continue
+			return true
+		})
+	case types.ControlCloseRoom:
+		// * the room is gone, its local members stay connected without a room
+		for _, mc := range memory_storage.DropRoom(msg.RoomId) {
+			trySend(mc, types.WsPayload{
+				Event: "roomClosed",
+				Data: types.RoomClosed{
+					RoomId: msg.RoomId,
+					Reason: msg.Reason,
+				},
+			})
+		}
+	case types.ControlKickUser:
+		memory_storage.UnsubscribeRoom(msg.UserID, msg.RoomId)
+
+		if value, exists := activeConnections.Load(msg.UserID); exists {
+			trySend(value.(*types.MessageClient), types.WsPayload{
+				Event: "roomKicked",
+				Data: types.RoomKicked{
+					RoomId: msg.RoomId,
+					By:     msg.Reason,
+				},
+			})
+		}
+	case types.ControlDisconnectIP:
+		activeConnections.Range(func(_, value any) bool {
+			mc := value.(*types.MessageClient)
+			if mc.IP == msg.IP {
+				closeWithCode(mc.Client.Conn, CloseSessionRevoked, msg.Reason)
 			}
 
-			services.BroadcastMessage(reqData, messageClient, userId)
+			return true
+		})
+	default:
+		log.Println("Unknown control message received:", msg.Type)
+	}
+}
 
-		case "updatePosition":
-			var reqData types.UpdateUserPos
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s", err)
-				continue
-			}
+// wsCredentials reads the ticket or the auth cookies sent on the upgrade
+func wsCredentials(c *gin.Context) services.WsCredentials {
+	accessToken, _ := c.Cookie(core.CookieAccessToken)
+	refreshToken, _ := c.Cookie(core.CookieRefreshToken)
 
-			services.UpdateUserPosition(reqData.RoomId, types.UserID(reqData.UserId), reqData.Dest)
+	return services.WsCredentials{
+		Ticket:       c.Query("ticket"),
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+}
 
-		case "updateTyping":
-			var reqData types.UpdateUserTyping
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s", err)
-				continue
-			}
+func authCloseCode(err error) int {
+	if errors.Is(err, core.ErrorTokenHasExpired) {
+		return CloseTokenExpired
+	}
 
-			services.UpdateUserTyping(types.RoomId(reqData.RoomId), types.UserID(reqData.UserId), reqData.IsTyping)
+	return CloseUnauthorized
+}
 
-		case "leaveRoom":
-			var reqData types.UserLeave
-			err := parsePayload(payload.Data, &reqData)
-			if err != nil {
-				fmt.Printf("Error: %s", err)
-				continue
-			}
+// closeWithCode sends a close frame before closing the connection, it's safe
+// to call concurrently with the writer goroutine
+func closeWithCode(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWriteWait)); err != nil {
+		fmt.Printf("failed to send close frame: %v\n", err)
+	}
 
-			services.LeaveRoom(reqData, userId, &activeConnections)
+	conn.Close()
+}
 
-		default:
-			log.Println("Unknown event received:", payload.Event)
-		}
+// trySend queues the payload without blocking, it's dropped when the client's
+// buffer is full
+func trySend(mc *types.MessageClient, payload types.WsPayload) {
+	encodedPayload, err := wire.FromSubprotocol(mc.Subprotocol).Marshal(payload)
+	if err != nil {
+		fmt.Printf("failed encoding %s payload: %v\n", payload.Event, err)
+		return
+	}
+
+	select {
+	case mc.Send <- encodedPayload:
+	default:
+		fmt.Printf("dropping %s for %s: send buffer is full\n", payload.Event, mc.Client.ID)
 	}
 }
 
 func hdlClientMessages(mc *types.MessageClient) {
+	messageType := wire.FromSubprotocol(mc.Subprotocol).MessageType()
+
 	for {
 		select {
 		case msg := <-mc.Send:
 			mc.ConnMu.Lock()
-			err := mc.Client.Conn.WriteMessage(websocket.TextMessage, msg)
+			err := mc.Client.Conn.WriteMessage(messageType, msg)
 			mc.ConnMu.Unlock()
 			if err != nil {
 				fmt.Printf("write error: %v\n", err)
@@ -218,15 +417,13 @@ func hdlClientMessages(mc *types.MessageClient) {
 	}
 }
 
-func parsePayload(data interface{}, dest interface{}) error {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed marshaling data: %w", err)
+func parsePayload(codec wire.Codec, data []byte, dest interface{}) error {
+	if len(data) == 0 {
+		return nil
 	}
 
-	err = json.Unmarshal(jsonData, dest)
-	if err != nil {
-		return fmt.Errorf("failed unmarshaling data: %w", err)
+	if err := codec.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed decoding data: %w", err)
 	}
 
 	return nil
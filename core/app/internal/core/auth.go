@@ -3,10 +3,11 @@ package core
 import (
 	"core/config"
 	"errors"
-	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 const (
@@ -16,107 +17,129 @@ const (
 	CookieRefreshToken  = "refreshToken"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 var (
 	ErrorFailedAccessTokenGen = errors.New("failed to generate tokens pair")
 	ErrorInvalidToken         = errors.New("invalid token")
 	ErrorTokenHasExpired      = errors.New("token has expired")
-
-	JwtEncAlgorithm = jwt.SigningMethodHS256
 )
 
+// Claims of the access and refresh tokens, typ keeps one from being used in
+// place of the other
+type Claims struct {
+	Username  string `json:"username"`
+	Type      string `json:"typ"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
 type JwtPayload struct {
-	Sub      float64 // User id
-	Username string
-	Exp      float64 // Exp timestamp
+	Sub       uint // User id
+	Username  string
+	Exp       time.Time
+	ID        string // Token id (jti)
+	SessionID string // Shared by the tokens rotated from the same login
 }
 
 type AuthTokensResponse struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+
+	// * stored server-side to rotate and revoke the refresh token
+	SessionID             string    `json:"-"`
+	RefreshTokenID        string    `json:"-"`
+	RefreshTokenExpiresAt time.Time `json:"-"`
 }
 
 type UserAuth interface {
-	GetTokensPair(userId float64, username string) (*AuthTokensResponse, error)
-	DecodeToken(tokenString string) (*JwtPayload, error)
-	GetRefreshToken(userId float64)
+	GetTokensPair(userId uint, username string, sessionId string) (*AuthTokensResponse, error)
+	DecodeToken(tokenString string, tokenType string) (*JwtPayload, error)
 }
 
-func GetTokensPair(userId uint, username string) (*AuthTokensResponse, error) {
-	accessTokenStr, err := GenerateToken(userId, username, "access")
+// NewSessionId identifies the tokens issued from a login
+func NewSessionId() string {
+	return uuid.NewString()
+}
+
+func GetTokensPair(userId uint, username string, sessionId string) (*AuthTokensResponse, error) {
+	accessTokenStr, err := GenerateToken(userId, username, sessionId, uuid.NewString(), TokenTypeAccess, time.Now().Add(AccessTokenExpTime))
 	if err != nil {
 		return nil, ErrorFailedAccessTokenGen
 	}
 
-	refreshTokenStr, err := GenerateToken(userId, username, "refresh")
+	refreshTokenId := uuid.NewString()
+	refreshTokenExp := time.Now().Add(RefreshTokenExpTime)
+
+	refreshTokenStr, err := GenerateToken(userId, username, sessionId, refreshTokenId, TokenTypeRefresh, refreshTokenExp)
 	if err != nil {
 		return nil, ErrorFailedAccessTokenGen
 	}
 
 	return &AuthTokensResponse{
-		AccessToken:  *accessTokenStr,
-		RefreshToken: *refreshTokenStr,
+		AccessToken:           *accessTokenStr,
+		RefreshToken:          *refreshTokenStr,
+		SessionID:             sessionId,
+		RefreshTokenID:        refreshTokenId,
+		RefreshTokenExpiresAt: refreshTokenExp,
 	}, nil
 }
 
-func DecodeToken(tokenString string) (*JwtPayload, error) {
+// DecodeToken verifies the token and its type (access or refresh)
+func DecodeToken(tokenString string, tokenType string) (*JwtPayload, error) {
+	var claims Claims
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return []byte(config.JwtSecret), nil
-	})
+	err := ParseClaims(tokenString, &claims)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrorTokenHasExpired
+	}
 
 	if err != nil {
 		return nil, ErrorInvalidToken
 	}
 
-	var payload *JwtPayload
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		// * Validate token expiration
-
-		username := claims["username"].(string)
-		sub := claims["sub"].(float64)
-		exp := claims["exp"].(float64)
-		currentTime := float64(time.Now().Unix())
-
-		if currentTime > exp {
-			return nil, ErrorTokenHasExpired
-		}
+	if claims.Type != tokenType {
+		return nil, ErrorInvalidToken
+	}
 
-		payload = &JwtPayload{
-			Sub:      sub,
-			Username: username,
-			Exp:      exp,
-		}
+	sub, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, ErrorInvalidToken
 	}
 
-	return payload, nil
+	return &JwtPayload{
+		Sub:       uint(sub),
+		Username:  claims.Username,
+		Exp:       claims.ExpiresAt.Time,
+		ID:        claims.ID,
+		SessionID: claims.SessionID,
+	}, nil
 }
 
-func GenerateToken(userId uint, username string, tokenType string) (*string, error) {
-	var claims jwt.MapClaims
-	if tokenType == "refresh" {
-		claims = jwt.MapClaims{
-			"sub":      userId,
-			"username": username,
-			"exp":      time.Now().Add(RefreshTokenExpTime).Unix(),
-		}
-	} else if tokenType == "access" {
-		claims = jwt.MapClaims{
-			"sub":      userId,
-			"username": username,
-			"exp":      time.Now().Add(AccessTokenExpTime).Unix(),
-		}
+func GenerateToken(userId uint, username string, sessionId string, tokenId string, tokenType string, exp time.Time) (*string, error) {
+	now := time.Now()
+	claims := Claims{
+		Username:  username,
+		Type:      tokenType,
+		SessionID: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.JwtIssuer,
+			Audience:  jwt.ClaimStrings{config.JwtAudience},
+			Subject:   strconv.FormatUint(uint64(userId), 10),
+			ID:        tokenId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
 	}
 
-	refreshToken := jwt.NewWithClaims(JwtEncAlgorithm, claims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(config.JwtSecret))
+	tokenString, err := SignClaims(claims)
 	if err != nil {
 		return nil, err
 	}
 
-	return &refreshTokenString, nil
+	return &tokenString, nil
 }
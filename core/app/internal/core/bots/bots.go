@@ -0,0 +1,103 @@
+package bots
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/internal/core/services"
+	"core/types"
+	"fmt"
+	"sync"
+)
+
+// Bot reacts to the events of the room it's attached to. The handlers of a bot
+// are called one at a time, never for the bot's own actions.
+type Bot interface {
+	Name() string // * username shown in the room
+	OnJoin(room *Room, user types.User)
+	OnLeave(room *Room, user types.User)
+	OnMessage(room *Room, msg Message)
+}
+
+// Factory creates a bot, every room the bot is attached to gets its own
+type Factory func() Bot
+
+// Message is a chat message seen by a bot, whispers are the ones sent to it
+type Message struct {
+	UserID   types.UserID
+	Username string
+	Msg      string
+	Whisper  bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a bot available to ROOM_BOTS, it's called from the init of
+// the bot's file
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("bot %q is already registered", name))
+	}
+
+	registry[name] = factory
+
+	// * nobody can sign up or join as one of the bots
+	services.ReserveUsername(factory().Name())
+}
+
+func lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, exists := registry[name]
+	return factory, exists
+}
+
+// Room is what a bot can do in the room it's attached to, the actions go
+// through the same room services as the websocket events of the users
+type Room struct {
+	Id     types.RoomId
+	UserId types.UserID // * the bot's user in the room
+	mc     *types.MessageClient
+}
+
+// Say sends a chat message to the whole room
+func (room *Room) Say(msg string) error {
+	return services.BroadcastMessage(types.Msg{
+		From:   room.UserId,
+		RoomId: room.Id,
+		Msg:    msg,
+	}, room.mc, room.UserId)
+}
+
+// Whisper sends a message that only the user sees
+func (room *Room) Whisper(userId types.UserID, msg string) error {
+	return services.Whisper(types.DirectMsg{
+		Msg:      msg,
+		ToUserId: userId,
+	}, room.mc, room.UserId)
+}
+
+// Emote plays an emote of the catalog on the bot's avatar
+func (room *Room) Emote(emote string) error {
+	return services.UpdateUserEmote(room.Id, room.UserId, emote)
+}
+
+// Move walks the bot's avatar to the tile, it returns right away
+func (room *Room) Move(row int, col int) {
+	go services.UpdateUserPosition(room.Id, room.UserId, fmt.Sprintf("%d,%d", row, col))
+}
+
+// Users returns the users in the room, the bot included
+func (room *Room) Users() []types.User {
+	roomData, exists := memory_storage.GetRoom(room.Id)
+	if !exists {
+		return nil
+	}
+
+	return roomData.Users
+}
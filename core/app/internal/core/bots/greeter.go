@@ -0,0 +1,33 @@
+package bots
+
+import (
+	"core/types"
+	"fmt"
+)
+
+func init() {
+	Register("greeter", func() Bot { return &greeter{} })
+}
+
+// greeter waves at the users joining its room
+type greeter struct{}
+
+func (*greeter) Name() string { return "Greeter" }
+
+func (*greeter) OnJoin(room *Room, user types.User) {
+	if user.IsBot {
+		return
+	}
+
+	if err := room.Say(fmt.Sprintf("Hi %s!", user.UserName)); err != nil {
+		fmt.Printf("greeter couldn't say hi: %v\n", err)
+	}
+
+	if err := room.Emote("wave"); err != nil {
+		fmt.Printf("greeter couldn't wave: %v\n", err)
+	}
+}
+
+func (*greeter) OnLeave(room *Room, user types.User) {}
+
+func (*greeter) OnMessage(room *Room, msg Message) {}
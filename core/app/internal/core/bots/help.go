@@ -0,0 +1,59 @@
+package bots
+
+import (
+	"core/internal/core/services"
+	"core/types"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func init() {
+	Register("help", func() Bot { return &helper{} })
+}
+
+// helper whispers what can be done in the room to the users asking it. The
+// "/help" typed in the chat is a command listing the commands, the bot is
+// asked with a whisper.
+type helper struct{}
+
+func (*helper) Name() string { return "Helper" }
+
+func (*helper) OnJoin(room *Room, user types.User) {}
+
+func (*helper) OnLeave(room *Room, user types.User) {}
+
+func (*helper) OnMessage(room *Room, msg Message) {
+	if !isHelpRequest(msg) {
+		return
+	}
+
+	for _, line := range helpLines() {
+		if err := room.Whisper(msg.UserID, line); err != nil {
+			fmt.Printf("helper couldn't whisper to %s: %v\n", msg.UserID, err)
+			return
+		}
+	}
+}
+
+// isHelpRequest reports whether the message whispered to the bot asks for help
+func isHelpRequest(msg Message) bool {
+	text := strings.ToLower(strings.TrimSpace(msg.Msg))
+
+	return msg.Whisper && (text == "help" || text == "/help")
+}
+
+// helpLines are whispered one each, whispers are cut at the message limit
+func helpLines() []string {
+	emotes := make([]string, 0, len(services.EmoteCatalog))
+	for name := range services.EmoteCatalog {
+		emotes = append(emotes, name)
+	}
+
+	sort.Strings(emotes)
+
+	return []string{
+		"Type /help in the chat for the commands you can use",
+		"Emotes: " + strings.Join(emotes, ", "),
+	}
+}
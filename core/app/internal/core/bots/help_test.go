@@ -0,0 +1,55 @@
+package bots
+
+import (
+	"core/internal/core/services"
+	"strings"
+	"testing"
+)
+
+func TestIsHelpRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want bool
+	}{
+		{name: "whispered help", msg: Message{Msg: "help", Whisper: true}, want: true},
+		{name: "whispered /help", msg: Message{Msg: " /HELP ", Whisper: true}, want: true},
+		{name: "help said in the room", msg: Message{Msg: "help"}, want: false},
+		{name: "other whisper", msg: Message{Msg: "help me move", Whisper: true}, want: false},
+		{name: "empty whisper", msg: Message{Whisper: true}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isHelpRequest(tt.msg); got != tt.want {
+				t.Errorf("isHelpRequest(%+v) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpLinesListEveryEmote(t *testing.T) {
+	lines := helpLines()
+
+	var emotes string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Emotes: ") {
+			emotes = strings.TrimPrefix(line, "Emotes: ")
+		}
+	}
+
+	listed := strings.Split(emotes, ", ")
+	if len(listed) != len(services.EmoteCatalog) {
+		t.Fatalf("listed %v, want the %d emotes of the catalog", listed, len(services.EmoteCatalog))
+	}
+
+	for i, name := range listed {
+		if _, exists := services.EmoteCatalog[name]; !exists {
+			t.Errorf("%q isn't in the catalog", name)
+		}
+
+		if i > 0 && listed[i-1] > name {
+			t.Errorf("emotes aren't sorted: %v", listed)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package bots
+
+import (
+	"core/config"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core/services"
+	"core/internal/core/wire"
+	util "core/internal/utils"
+	"core/types"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// * a bot runs on the node holding its lock, the others take over once
+	// * the lock expires
+	botLockTTL     = time.Minute
+	botLockRefresh = botLockTTL / 3
+	botRetry       = botLockTTL / 2
+
+	botSendBufferSize = 256
+)
+
+var (
+	ErrorLockLost = errors.New("bot lock lost")
+)
+
+// Attachment is a bot of the registry attached to a room
+type Attachment struct {
+	Bot    string
+	RoomId types.RoomId
+}
+
+// Start attaches the bots of ROOM_BOTS to their rooms, each one in its own
+// goroutine. A bot stops when its room is closed.
+func Start() {
+	for _, attachment := range parseAttachments(config.RoomBots) {
+		factory, exists := lookup(attachment.Bot)
+		if !exists {
+			log.Printf("Unknown bot %q in ROOM_BOTS\n", attachment.Bot)
+			continue
+		}
+
+		go run(attachment, factory)
+	}
+}
+
+func parseAttachments(value string) []Attachment {
+	attachments := []Attachment{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, roomId, found := strings.Cut(entry, "@")
+		attachment := Attachment{
+			Bot:    strings.ToLower(strings.TrimSpace(name)),
+			RoomId: memory_storage.WelcomeRoomId(),
+		}
+
+		if found {
+			attachment.RoomId = types.RoomId(strings.TrimSpace(roomId))
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments
+}
+
+func run(attachment Attachment, factory Factory) {
+	lockName := fmt.Sprintf("bot:%s:%s", attachment.Bot, attachment.RoomId)
+
+	for {
+		lock, err := memory_storage.TryLock(lockName, botLockTTL)
+		if err != nil {
+			log.Println(err)
+		}
+
+		if lock != nil {
+			err := serve(lock, attachment.RoomId, factory())
+			if errors.Is(err, services.ErrorRoomNotExists) {
+				if err := lock.Release(); err != nil {
+					log.Println(err)
+				}
+
+				log.Printf("Bot %s stopped: %s is gone\n", attachment.Bot, attachment.RoomId)
+				return
+			}
+
+			log.Printf("Bot %s left %s: %v\n", attachment.Bot, attachment.RoomId, err)
+		}
+
+		time.Sleep(botRetry)
+	}
+}
+
+// serve joins the room as a user without websocket connection and feeds the
+// room events to the bot until the room is closed or the lock is lost
+func serve(lock *memory_storage.Lock, roomId types.RoomId, bot Bot) error {
+	id, err := util.GetRandomId()
+	if err != nil {
+		return fmt.Errorf("error generating random id: %v", err)
+	}
+
+	userId := types.UserID(id)
+
+	client := &types.Client{
+		ID:       userId,
+		Username: bot.Name(),
+		Avatar:   services.RandomAvatar(),
+		IsBot:    true,
+	}
+
+	messageClient := &types.MessageClient{
+		Client:      client,
+		Session:     &types.Session{Username: bot.Name()},
+		Subprotocol: wire.SubprotocolJSON,
+		Send:        make(chan []byte, botSendBufferSize),
+	}
+
+	roomData, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return services.ErrorRoomNotExists
+	}
+
+	removeStale(roomId, roomData, bot.Name())
+
+	memory_storage.AddClient(client)
+	defer memory_storage.DeleteClient(userId)
+
+	joinData := types.JoinRoom{
+		RoomId:   roomId,
+		UserName: bot.Name(),
+		Password: roomData.Password, // * bots don't need to know the password
+	}
+
+	if err := services.JoinRoom(joinData, messageClient, userId); err != nil {
+		return err
+	}
+
+	defer services.RemoveUser(userId, roomId)
+
+	r := &runner{
+		bot: bot,
+		room: &Room{
+			Id:     roomId,
+			UserId: userId,
+			mc:     messageClient,
+		},
+	}
+
+	refresh := time.NewTicker(botLockRefresh)
+	defer refresh.Stop()
+
+	refreshedAt := time.Now()
+
+	for {
+		select {
+		case frame := <-messageClient.Send:
+			err := r.handle(frame)
+			if errors.Is(err, services.ErrorRoomNotExists) {
+				return err
+			}
+
+			if err != nil {
+				log.Printf("Bot %s: %v\n", bot.Name(), err)
+			}
+		case <-refresh.C:
+			refreshed, err := lock.Refresh(botLockTTL)
+			if err != nil {
+				log.Println(err)
+
+				// * the lock may have expired while Redis was unreachable
+				if time.Since(refreshedAt) >= botLockTTL {
+					return ErrorLockLost
+				}
+
+				continue
+			}
+
+			if !refreshed {
+				return ErrorLockLost
+			}
+
+			refreshedAt = time.Now()
+		}
+	}
+}
+
+// removeStale removes the bot left in the room by a node that went down, the
+// lock guarantees it's not running anywhere else
+func removeStale(roomId types.RoomId, roomData *types.RoomData, name string) {
+	for _, user := range roomData.Users {
+		if user.IsBot && user.UserName == name {
+			services.RemoveUser(user.UserID, roomId)
+			memory_storage.DeleteClient(user.UserID)
+		}
+	}
+}
+
+// runner turns the room events received by a bot into calls to its handlers
+type runner struct {
+	bot   Bot
+	room  *Room
+	users map[types.UserID]types.User // * last scene, nil until the bot is in it
+}
+
+func (r *runner) handle(frame []byte) error {
+	envelope, err := wire.JSON.DecodeEnvelope(frame)
+	if err != nil {
+		return fmt.Errorf("failed decoding frame: %w", err)
+	}
+
+	switch envelope.Event {
+	case "updateScene":
+		var scene types.UpdateScene
+		if err := wire.JSON.Unmarshal(envelope.Data, &scene); err != nil {
+			return fmt.Errorf("failed decoding scene: %w", err)
+		}
+
+		r.updateScene(scene.Users)
+	case "broadcastMessage", "whisper":
+		var msg struct {
+			Msg        string       `json:"msg"`
+			From       string       `json:"from"`
+			FromUserId types.UserID `json:"fromUserId"`
+		}
+
+		if err := wire.JSON.Unmarshal(envelope.Data, &msg); err != nil {
+			return fmt.Errorf("failed decoding message: %w", err)
+		}
+
+		// * the whispers sent by the bot come back to it too
+		if msg.FromUserId == r.room.UserId {
+			return nil
+		}
+
+		r.bot.OnMessage(r.room, Message{
+			UserID:   msg.FromUserId,
+			Username: msg.From,
+			Msg:      msg.Msg,
+			Whisper:  envelope.Event == "whisper",
+		})
+	case "roomClosed":
+		return services.ErrorRoomNotExists
+	}
+
+	return nil
+}
+
+// updateScene compares the users with the last scene to find who joined and
+// who left
+func (r *runner) updateScene(users []types.User) {
+	current := make(map[types.UserID]types.User, len(users))
+	for _, user := range users {
+		current[user.UserID] = user
+	}
+
+	if r.users == nil {
+		// * the users that were there before the bot aren't joining
+		if _, joined := current[r.room.UserId]; joined {
+			r.users = current
+		}
+
+		return
+	}
+
+	previous := r.users
+	r.users = current
+
+	for userId, user := range current {
+		if _, exists := previous[userId]; !exists && userId != r.room.UserId {
+			r.bot.OnJoin(r.room, user)
+		}
+	}
+
+	for userId, user := range previous {
+		if _, exists := current[userId]; !exists {
+			r.bot.OnLeave(r.room, user)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package bots
+
+import (
+	"core/types"
+	"slices"
+	"testing"
+)
+
+// recorder is a bot that remembers who joined and left
+type recorder struct {
+	joined []types.UserID
+	left   []types.UserID
+}
+
+func (*recorder) Name() string { return "Recorder" }
+
+func (bot *recorder) OnJoin(room *Room, user types.User) {
+	bot.joined = append(bot.joined, user.UserID)
+}
+
+func (bot *recorder) OnLeave(room *Room, user types.User) {
+	bot.left = append(bot.left, user.UserID)
+}
+
+func (*recorder) OnMessage(room *Room, msg Message) {}
+
+func scene(userIds ...types.UserID) []types.User {
+	users := []types.User{}
+	for _, userId := range userIds {
+		users = append(users, types.User{UserID: userId})
+	}
+
+	return users
+}
+
+func TestUpdateScene(t *testing.T) {
+	const botId types.UserID = "bot"
+
+	tests := []struct {
+		name       string
+		scenes     [][]types.User
+		wantJoined []types.UserID
+		wantLeft   []types.UserID
+	}{
+		{
+			name:   "scene before the bot joined",
+			scenes: [][]types.User{scene("alice"), scene("alice", "bob")},
+		},
+		{
+			name:   "users already there don't join",
+			scenes: [][]types.User{scene("alice", botId)},
+		},
+		{
+			name:       "user joins",
+			scenes:     [][]types.User{scene("alice", botId), scene("alice", botId, "bob")},
+			wantJoined: []types.UserID{"bob"},
+		},
+		{
+			name:     "user leaves",
+			scenes:   [][]types.User{scene("alice", botId), scene(botId)},
+			wantLeft: []types.UserID{"alice"},
+		},
+		{
+			name:       "users join and leave at once",
+			scenes:     [][]types.User{scene("alice", botId), scene(botId, "bob", "carol")},
+			wantJoined: []types.UserID{"bob", "carol"},
+			wantLeft:   []types.UserID{"alice"},
+		},
+		{
+			name:   "moves don't join again",
+			scenes: [][]types.User{scene("alice", botId), scene(botId, "alice"), scene("alice", botId)},
+		},
+		{
+			name:       "user comes back",
+			scenes:     [][]types.User{scene("alice", botId), scene(botId), scene(botId, "alice")},
+			wantJoined: []types.UserID{"alice"},
+			wantLeft:   []types.UserID{"alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := &recorder{}
+			r := &runner{bot: bot, room: &Room{Id: "room", UserId: botId}}
+
+			for _, users := range tt.scenes {
+				r.updateScene(users)
+			}
+
+			slices.Sort(bot.joined)
+			slices.Sort(bot.left)
+
+			if !slices.Equal(bot.joined, tt.wantJoined) {
+				t.Errorf("joined %v, want %v", bot.joined, tt.wantJoined)
+			}
+
+			if !slices.Equal(bot.left, tt.wantLeft) {
+				t.Errorf("left %v, want %v", bot.left, tt.wantLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"core/config"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	TokenTypeInvite = "invite"
+)
+
+// InviteClaims of the room invite tokens, the subject is the account that
+// created the invite
+type InviteClaims struct {
+	Type   string `json:"typ"`
+	RoomId string `json:"room"`
+	jwt.RegisteredClaims
+}
+
+// GenerateInviteToken signs an invite to the room, it's valid until exp
+func GenerateInviteToken(roomId string, inviterId uint, exp time.Time) (string, error) {
+	now := time.Now()
+	claims := InviteClaims{
+		Type:   TokenTypeInvite,
+		RoomId: roomId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.JwtIssuer,
+			Audience:  jwt.ClaimStrings{config.JwtAudience},
+			Subject:   strconv.FormatUint(uint64(inviterId), 10),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+
+	return SignClaims(claims)
+}
+
+// DecodeInviteToken verifies the invite and returns the id of its room
+func DecodeInviteToken(tokenString string) (string, error) {
+	var claims InviteClaims
+
+	err := ParseClaims(tokenString, &claims)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return "", ErrorTokenHasExpired
+	}
+
+	if err != nil || claims.Type != TokenTypeInvite {
+		return "", ErrorInvalidToken
+	}
+
+	return claims.RoomId, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeInviteToken(t *testing.T) {
+	useKeyring(t, mustKeyring(t, "secret", "", "", ""))
+
+	exp := time.Now().Add(time.Minute)
+
+	invite, err := GenerateInviteToken("room#1", 1, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredInvite, err := GenerateInviteToken("room#1", 1, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := GenerateToken(1, "alice", "session", "jti", TokenTypeAccess, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refresh, err := GenerateToken(1, "alice", "session", "jti", TokenTypeRefresh, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantRoomId string
+		wantErr    error
+	}{
+		{name: "invite", token: invite, wantRoomId: "room#1"},
+		{name: "expired invite", token: expiredInvite, wantErr: ErrorTokenHasExpired},
+		{name: "access token", token: *access, wantErr: ErrorInvalidToken},
+		{name: "refresh token", token: *refresh, wantErr: ErrorInvalidToken},
+		{name: "garbage", token: "not.a.token", wantErr: ErrorInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomId, err := DecodeInviteToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+
+			if roomId != tt.wantRoomId {
+				t.Errorf("room %q, want %q", roomId, tt.wantRoomId)
+			}
+		})
+	}
+}
+
+func TestDecodeTokenRejectsInvites(t *testing.T) {
+	useKeyring(t, mustKeyring(t, "secret", "", "", ""))
+
+	invite, err := GenerateInviteToken("room#1", 1, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tokenType := range []string{TokenTypeAccess, TokenTypeRefresh} {
+		t.Run(tokenType, func(t *testing.T) {
+			if _, err := DecodeToken(invite, tokenType); !errors.Is(err, ErrorInvalidToken) {
+				t.Fatalf("got err %v, want %v", err, ErrorInvalidToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"core/config"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// * kid of JWT_SECRET, also used to verify tokens signed before kids
+	DefaultKeyId = "default"
+)
+
+var (
+	ErrorNoSigningKeys     = errors.New("no jwt signing keys configured")
+	ErrorUnknownSigningKey = errors.New("unknown jwt signing key")
+)
+
+type signingKey struct {
+	method    jwt.SigningMethod
+	signKey   interface{} // * []byte for HMAC, ed25519.PrivateKey for EdDSA
+	verifyKey interface{} // * []byte for HMAC, ed25519.PublicKey for EdDSA
+}
+
+// Keyring holds the keys tokens are verified with, by kid. Only the active
+// key signs new tokens, the others are kept until their tokens expire.
+type Keyring struct {
+	activeKid string
+	keys      map[string]signingKey
+}
+
+var keyring *Keyring
+
+// InitKeyring loads the jwt keys from the environment:
+//
+//	JWT_SECRET         HMAC secret with kid "default"
+//	JWT_KEYS           HMAC secrets as "kid:secret,kid:secret"
+//	JWT_ED25519_KEYS   Ed25519 keys as "kid:base64 seed,..."
+//	JWT_ACTIVE_KEY     kid used to sign new tokens
+func InitKeyring() error {
+	ring, err := newKeyring(config.JwtSecret, config.JwtKeys, config.JwtEd25519Keys, config.JwtActiveKey)
+	if err != nil {
+		return err
+	}
+
+	keyring = ring
+
+	return nil
+}
+
+func newKeyring(secret string, hmacKeys string, ed25519Keys string, activeKid string) (*Keyring, error) {
+	ring := &Keyring{
+		keys: make(map[string]signingKey),
+	}
+
+	if secret != "" {
+		ring.keys[DefaultKeyId] = signingKey{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}
+	}
+
+	for _, entry := range splitKeys(hmacKeys) {
+		kid, value, err := parseKeyEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		ring.keys[kid] = signingKey{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(value),
+			verifyKey: []byte(value),
+		}
+	}
+
+	for _, entry := range splitKeys(ed25519Keys) {
+		kid, value, err := parseKeyEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid ed25519 seed for kid %q", kid)
+		}
+
+		privateKey := ed25519.NewKeyFromSeed(seed)
+		ring.keys[kid] = signingKey{
+			method:    jwt.SigningMethodEdDSA,
+			signKey:   privateKey,
+			verifyKey: privateKey.Public(),
+		}
+	}
+
+	if len(ring.keys) == 0 {
+		return nil, ErrorNoSigningKeys
+	}
+
+	if activeKid == "" {
+		activeKid = DefaultKeyId
+	}
+
+	if _, exists := ring.keys[activeKid]; !exists {
+		return nil, fmt.Errorf("%w: active kid %q", ErrorUnknownSigningKey, activeKid)
+	}
+
+	ring.activeKid = activeKid
+
+	return ring, nil
+}
+
+func splitKeys(keys string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(keys, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func parseKeyEntry(entry string) (string, string, error) {
+	kid, value, found := strings.Cut(entry, ":")
+	if !found || kid == "" || value == "" {
+		return "", "", fmt.Errorf("invalid jwt key entry, expected kid:key")
+	}
+
+	return kid, value, nil
+}
+
+func (ring *Keyring) sign(claims jwt.Claims) (string, error) {
+	key := ring.keys[ring.activeKid]
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = ring.activeKid
+
+	return token.SignedString(key.signKey)
+}
+
+func (ring *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = DefaultKeyId
+	}
+
+	key, exists := ring.keys[kid]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrorUnknownSigningKey, kid)
+	}
+
+	// * a key only verifies tokens of its own algorithm
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// SignClaims signs the claims with the active key
+func SignClaims(claims jwt.Claims) (string, error) {
+	if keyring == nil {
+		return "", ErrorNoSigningKeys
+	}
+
+	return keyring.sign(claims)
+}
+
+// ParseClaims verifies the token signature, issuer, audience and expiration
+// and decodes its claims
+func ParseClaims(tokenString string, claims jwt.Claims) error {
+	if keyring == nil {
+		return ErrorNoSigningKeys
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyring.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(config.JwtIssuer),
+		jwt.WithAudience(config.JwtAudience),
+		jwt.WithExpirationRequired(),
+	)
+
+	return err
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"core/config"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// errAny matches any error in the tables
+var errAny = errors.New("any error")
+
+var testSeed = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", ed25519.SeedSize)))
+
+// useKeyring makes ring the keyring of the package until the test ends
+func useKeyring(t *testing.T, ring *Keyring) {
+	t.Helper()
+
+	previous := keyring
+	keyring = ring
+	t.Cleanup(func() { keyring = previous })
+}
+
+func mustKeyring(t *testing.T, secret string, hmacKeys string, ed25519Keys string, activeKid string) *Keyring {
+	t.Helper()
+
+	ring, err := newKeyring(secret, hmacKeys, ed25519Keys, activeKid)
+	if err != nil {
+		t.Fatalf("newKeyring: %v", err)
+	}
+
+	return ring
+}
+
+func testClaims(exp time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    config.JwtIssuer,
+		Audience:  jwt.ClaimStrings{config.JwtAudience},
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(exp),
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		hmacKeys    string
+		ed25519Keys string
+		activeKid   string
+		wantErr     error
+		wantKid     string
+		wantAlg     string
+	}{
+		{name: "no keys", wantErr: ErrorNoSigningKeys},
+		{name: "secret only", secret: "secret", wantKid: DefaultKeyId, wantAlg: "HS256"},
+		{name: "active hmac key", secret: "secret", hmacKeys: "k1:one, k2:two", activeKid: "k2", wantKid: "k2", wantAlg: "HS256"},
+		{name: "active ed25519 key", ed25519Keys: "ed:" + testSeed, activeKid: "ed", wantKid: "ed", wantAlg: "EdDSA"},
+		{name: "unknown active kid", secret: "secret", activeKid: "k3", wantErr: ErrorUnknownSigningKey},
+		{name: "default kid without secret", hmacKeys: "k1:one", wantErr: ErrorUnknownSigningKey},
+		{name: "entry without kid", hmacKeys: "secret", wantErr: errAny},
+		{name: "invalid seed", ed25519Keys: "ed:c2hvcnQ=", activeKid: "ed", wantErr: errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := newKeyring(tt.secret, tt.hmacKeys, tt.ed25519Keys, tt.activeKid)
+			if tt.wantErr != nil {
+				if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("got err %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if ring.activeKid != tt.wantKid {
+				t.Errorf("active kid %q, want %q", ring.activeKid, tt.wantKid)
+			}
+
+			if alg := ring.keys[ring.activeKid].method.Alg(); alg != tt.wantAlg {
+				t.Errorf("active alg %q, want %q", alg, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestParseClaimsKeySelection(t *testing.T) {
+	const hmacKeys = "k1:one,k2:two"
+	ed25519Keys := "ed:" + testSeed
+
+	verifier := mustKeyring(t, "secret", hmacKeys, ed25519Keys, "k1")
+	exp := time.Now().Add(time.Minute)
+
+	signedBy := func(activeKid string) string {
+		ring := mustKeyring(t, "secret", hmacKeys, ed25519Keys, activeKid)
+		token, err := ring.sign(testClaims(exp))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return token
+	}
+
+	withHeader := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, testClaims(exp))
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signed
+	}
+
+	edPublicKey := verifier.keys["ed"].verifyKey.(ed25519.PublicKey)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "active hmac key", token: signedBy("k1")},
+		{name: "rotated hmac key", token: signedBy("k2")},
+		{name: "ed25519 key", token: signedBy("ed")},
+		{name: "default key", token: signedBy(DefaultKeyId)},
+		{name: "no kid uses the default key", token: withHeader(jwt.SigningMethodHS256, "", []byte("secret"))},
+		{name: "no kid signed by another key", token: withHeader(jwt.SigningMethodHS256, "", []byte("one")), wantErr: true},
+		{name: "unknown kid", token: withHeader(jwt.SigningMethodHS256, "k3", []byte("one")), wantErr: true},
+		{name: "kid of another key", token: withHeader(jwt.SigningMethodHS256, "k2", []byte("one")), wantErr: true},
+		{name: "hmac signed with the ed25519 public key", token: withHeader(jwt.SigningMethodHS256, "ed", []byte(edPublicKey)), wantErr: true},
+		{name: "unsigned", token: withHeader(jwt.SigningMethodNone, "k1", jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+	}
+
+	useKeyring(t, verifier)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims jwt.RegisteredClaims
+			err := ParseClaims(tt.token, &claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseClaimsRegisteredClaims(t *testing.T) {
+	ring := mustKeyring(t, "secret", "", "", "")
+	useKeyring(t, ring)
+
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  func(claims *jwt.RegisteredClaims)
+		wantErr error
+	}{
+		{name: "valid", claims: func(claims *jwt.RegisteredClaims) {}},
+		{name: "other issuer", claims: func(claims *jwt.RegisteredClaims) { claims.Issuer = "someone-else" }, wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "no issuer", claims: func(claims *jwt.RegisteredClaims) { claims.Issuer = "" }, wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "other audience", claims: func(claims *jwt.RegisteredClaims) { claims.Audience = jwt.ClaimStrings{"someone-else"} }, wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "no audience", claims: func(claims *jwt.RegisteredClaims) { claims.Audience = nil }, wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "expired", claims: func(claims *jwt.RegisteredClaims) { claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, wantErr: jwt.ErrTokenExpired},
+		{name: "no expiration", claims: func(claims *jwt.RegisteredClaims) { claims.ExpiresAt = nil }, wantErr: jwt.ErrTokenRequiredClaimMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := testClaims(now.Add(time.Minute))
+			tt.claims(&claims)
+
+			token, err := ring.sign(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var parsed jwt.RegisteredClaims
+			err = ParseClaims(token, &parsed)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"core/config"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// NewMailer returns the mailer selected by MAIL_DRIVER, the log mailer is
+// the default so that local setups don't need an SMTP server
+func NewMailer(logger LoggerI) Mailer {
+	if config.MailDriver == MailDriverSMTP {
+		return NewSMTPMailer(config.Smtp.Host, config.Smtp.Port, config.Smtp.Username, config.Smtp.Password, config.MailFrom)
+	}
+
+	return NewLogMailer(config.MailLogFile, logger)
+}
+
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%s", host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	msg := strings.Join([]string{
+		fmt.Sprintf("From: %s", m.from),
+		fmt.Sprintf("To: %s", to),
+		fmt.Sprintf("Subject: %s", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// LogMailer appends the mails to a file, or writes them to the logger when no
+// file is given. Meant for local development and tests.
+type LogMailer struct {
+	path   string
+	logger LoggerI
+	mu     sync.Mutex
+}
+
+func NewLogMailer(path string, logger LoggerI) *LogMailer {
+	return &LogMailer{
+		path:   path,
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) Send(to string, subject string, body string) error {
+	msg := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", to, subject, body)
+
+	if m.path == "" {
+		m.logger.Info(fmt.Sprintf("mail sent\n%s", msg))
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open mail log: %w", err)
+	}
+
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "--- %s\n%s", time.Now().Format(time.RFC3339), msg); err != nil {
+		return fmt.Errorf("failed to write mail log: %w", err)
+	}
+
+	return nil
+}
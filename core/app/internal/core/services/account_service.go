@@ -0,0 +1,274 @@
+package services
+
+import (
+	"core/config"
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	repositories "core/internal/ports"
+	"core/types"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	VerifyEmailTokenExpTime   = 24 * time.Hour
+	ResetPasswordTokenExpTime = time.Hour
+	userTokenBytes            = 32
+
+	accountPurgeInterval = time.Hour
+)
+
+var (
+	ErrorInvalidUserToken     = errors.New("invalid or expired token")
+	ErrorEmailAlreadyVerified = errors.New("email is already verified")
+	ErrorEmailNotVerified     = errors.New("email is not verified")
+	ErrorFailedToSendMail     = errors.New("failed to send mail")
+)
+
+// AccountService handles the account lifecycle: email verification, password
+// reset and deletion
+type AccountService struct {
+	userRepo         *repositories.UserRepoContext
+	userTokenRepo    *repositories.UserTokenRepoContext
+	refreshTokenRepo *repositories.RefreshTokenRepoContext
+	mailer           core.Mailer
+	guard            loginGuard
+	logger           core.LoggerI
+}
+
+func NewAccountService(logger core.LoggerI, mailer core.Mailer, userRepo *repositories.UserRepoContext, userTokenRepo *repositories.UserTokenRepoContext, refreshTokenRepo *repositories.RefreshTokenRepoContext) *AccountService {
+	return &AccountService{
+		userRepo:         userRepo,
+		userTokenRepo:    userTokenRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		mailer:           mailer,
+		guard:            loginGuard{logger: logger},
+		logger:           logger,
+	}
+}
+
+// RequestVerification mails a link to verify the user's email, replacing the
+// links sent before
+func (ctx *AccountService) RequestVerification(user *models.User) error {
+	if user.IsVerified() {
+		return ErrorEmailAlreadyVerified
+	}
+
+	token, err := ctx.newUserToken(user.ID, models.UserTokenVerifyEmail, VerifyEmailTokenExpTime)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nconfirm your email by opening the link below, it expires in %d hours.\n\n%s\n",
+		user.Username, int(VerifyEmailTokenExpTime.Hours()), accountLink("/verify", token),
+	)
+
+	return ctx.send(user.Email, fmt.Sprintf("Verify your %s account", config.AppName), body)
+}
+
+// Verify marks the email of the token's user as verified
+func (ctx *AccountService) Verify(token string) error {
+	userToken, err := ctx.userTokenRepo.Consume(hashUserToken(token), models.UserTokenVerifyEmail)
+	if err != nil {
+		return ErrorInvalidUserToken
+	}
+
+	if err := ctx.userRepo.MarkEmailVerified(userToken.UserID); err != nil {
+		return ErrorSaveFailed
+	}
+
+	return nil
+}
+
+// ForgotPassword mails a reset link when the email is registered. It doesn't
+// report whether it is, so it can't be used to find accounts.
+func (ctx *AccountService) ForgotPassword(email string) {
+	user, err := ctx.userRepo.GetByEmail(email)
+	if err != nil || user.ID == 0 {
+		return
+	}
+
+	token, err := ctx.newUserToken(user.ID, models.UserTokenResetPassword, ResetPasswordTokenExpTime)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nsomeone asked to reset your password, open the link below to choose a new one. It expires in %d minutes.\nIf it wasn't you, you can ignore this email.\n\n%s\n",
+		user.Username, int(ResetPasswordTokenExpTime.Minutes()), accountLink("/reset-password", token),
+	)
+
+	if err := ctx.send(user.Email, fmt.Sprintf("Reset your %s password", config.AppName), body); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+// ResetPassword sets a new password and logs out every session of the user
+func (ctx *AccountService) ResetPassword(token string, password string) error {
+	if len(password) < minPasswordLen {
+		return ErrorInvalidPasswordLen
+	}
+
+	if len(password) > core.BcryptCharacterLimit {
+		return ErrorPasswordLenExceeded
+	}
+
+	userToken, err := ctx.userTokenRepo.Consume(hashUserToken(token), models.UserTokenResetPassword)
+	if err != nil {
+		return ErrorInvalidUserToken
+	}
+
+	passwordHash, err := core.GenPasswordHash(password)
+	if err != nil {
+		return ErrorFailedToHashPassword
+	}
+
+	if err := ctx.userRepo.UpdatePassword(userToken.UserID, string(*passwordHash)); err != nil {
+		return ErrorSaveFailed
+	}
+
+	// * the mail proved the ownership of the account, the email is verified too
+	if err := ctx.userRepo.MarkEmailVerified(userToken.UserID); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	if err := ctx.refreshTokenRepo.RevokeAllByUserId(userToken.UserID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAccount soft deletes the account after checking its password again,
+// its sessions are revoked and its live connections closed. The account is
+// purged once the grace period is over.
+func (ctx *AccountService) DeleteAccount(user *models.User, password string, ip string) error {
+	// * a stolen access token can't be used to guess the password
+	if err := ctx.guard.checkPassword(user, password, ip); err != nil {
+		return err
+	}
+
+	if err := ctx.userRepo.SoftDelete(user.ID); err != nil {
+		return ErrorSaveFailed
+	}
+
+	if err := ctx.refreshTokenRepo.RevokeAllByUserId(user.ID); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	if err := memory_storage.DisconnectAccount(user.ID, "account deleted"); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	// * the rooms outlive their owner
+	rooms, err := memory_storage.GetOwnedRooms(user.ID)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	for roomId := range rooms {
+		_, err := updateRoom(roomId, func(room *types.RoomData) error {
+			return disownRoom(room, user.ID)
+		})
+
+		if err != nil && !errors.Is(err, errRoomUnchanged) && !errors.Is(err, ErrorRoomNotExists) {
+			ctx.logger.Error(err.Error())
+		}
+	}
+
+	ctx.logger.Info(fmt.Sprintf("account %d deleted, purged in %d days", user.ID, config.AccountDeletionGraceDays))
+
+	return nil
+}
+
+// disownRoom removes the owner of the room if it's still the account, the
+// room may have changed hands since it was listed
+func disownRoom(room *types.RoomData, accountId uint) error {
+	if room.OwnerID != accountId {
+		return errRoomUnchanged
+	}
+
+	room.OwnerID = 0
+	return nil
+}
+
+// PurgeDeletedAccounts hard deletes the accounts whose grace period is over
+func (ctx *AccountService) PurgeDeletedAccounts() {
+	gracePeriod := time.Duration(config.AccountDeletionGraceDays) * 24 * time.Hour
+
+	purged, err := ctx.userRepo.PurgeDeletedBefore(time.Now().Add(-gracePeriod))
+	if err != nil {
+		ctx.logger.Error(fmt.Sprintf("failed to purge deleted accounts: %v", err))
+		return
+	}
+
+	if purged > 0 {
+		ctx.logger.Info(fmt.Sprintf("purged %d deleted accounts", purged))
+	}
+}
+
+// StartAccountPurge runs PurgeDeletedAccounts periodically, it blocks
+func (ctx *AccountService) StartAccountPurge() {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	ctx.PurgeDeletedAccounts()
+	for range ticker.C {
+		ctx.PurgeDeletedAccounts()
+	}
+}
+
+// newUserToken stores the hash of a random token and returns the token, the
+// pending tokens of the same purpose stop working
+func (ctx *AccountService) newUserToken(userId uint, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, userTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := ctx.userTokenRepo.DeleteByUserId(userId, purpose); err != nil {
+		return "", ErrorSaveFailed
+	}
+
+	_, err := ctx.userTokenRepo.Save(models.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: hashUserToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+
+	if err != nil {
+		return "", ErrorSaveFailed
+	}
+
+	return token, nil
+}
+
+func (ctx *AccountService) send(to string, subject string, body string) error {
+	if err := ctx.mailer.Send(to, subject, body); err != nil {
+		ctx.logger.Error(err.Error())
+		return ErrorFailedToSendMail
+	}
+
+	return nil
+}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func accountLink(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", config.AppUrl, path, url.QueryEscape(token))
+}
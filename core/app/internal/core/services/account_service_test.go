@@ -0,0 +1,84 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDisownRoom(t *testing.T) {
+	tests := []struct {
+		name      string
+		ownerId   uint
+		wantErr   error
+		wantOwner uint
+	}{
+		{name: "owned by the account", ownerId: 1, wantOwner: 0},
+		{name: "changed hands", ownerId: 2, wantErr: errRoomUnchanged, wantOwner: 2},
+		{name: "no owner", ownerId: 0, wantErr: errRoomUnchanged, wantOwner: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := &types.RoomData{OwnerID: tt.ownerId}
+
+			if err := disownRoom(room, 1); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+
+			if room.OwnerID != tt.wantOwner {
+				t.Errorf("owner %d, want %d", room.OwnerID, tt.wantOwner)
+			}
+		})
+	}
+}
+
+func TestExportedRooms(t *testing.T) {
+	rooms := exportedRooms(map[types.RoomId]types.RoomData{
+		"new#1":  {Name: "new", CreatedAt: 30},
+		"old#1":  {Name: "old", CreatedAt: 10, IsProtected: true},
+		"same#2": {Name: "same", CreatedAt: 20},
+		"same#1": {Name: "same", CreatedAt: 20},
+	})
+
+	want := []ExportedRoom{
+		{RoomId: "old#1", RoomName: "old", IsProtected: true, CreatedAt: 10},
+		{RoomId: "same#1", RoomName: "same", CreatedAt: 20},
+		{RoomId: "same#2", RoomName: "same", CreatedAt: 20},
+		{RoomId: "new#1", RoomName: "new", CreatedAt: 30},
+	}
+
+	if !reflect.DeepEqual(rooms, want) {
+		t.Errorf("got %+v, want %+v", rooms, want)
+	}
+
+	if empty := exportedRooms(nil); empty == nil || len(empty) != 0 {
+		t.Errorf("no rooms must export an empty list, got %#v", empty)
+	}
+}
+
+func TestExportedFriendships(t *testing.T) {
+	const userId uint = 1
+
+	friendships := []models.Friendship{
+		{RequesterID: userId, AddresseeID: 2, Status: models.FriendshipAccepted},
+		{RequesterID: 3, AddresseeID: userId, Status: models.FriendshipPending},
+		{RequesterID: userId, AddresseeID: 4, Status: models.FriendshipBlocked},
+	}
+
+	usernames := map[uint]string{2: "bob", 3: "carol"}
+
+	got := exportedFriendships(userId, friendships, usernames)
+
+	want := []ExportedFriendship{
+		{AccountID: 2, Username: "bob", Status: models.FriendshipAccepted, Outgoing: true, CreatedAt: got[0].CreatedAt},
+		{AccountID: 3, Username: "carol", Status: models.FriendshipPending, Outgoing: false, CreatedAt: got[1].CreatedAt},
+		{AccountID: 4, Username: "", Status: models.FriendshipBlocked, Outgoing: true, CreatedAt: got[2].CreatedAt},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	repositories "core/internal/ports"
+	"core/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	announcementsTick = 30 * time.Second
+
+	// * a single node sends the due announcements on every tick
+	announcementsLock    = "announcements"
+	announcementsLockTTL = announcementsTick - 5*time.Second
+)
+
+var (
+	ErrorAnnouncementNotFound = errors.New("announcement not found")
+	ErrorInvalidInterval      = errors.New("invalid announcement interval")
+)
+
+type AnnouncementResponse struct {
+	ID         uint   `json:"id"`
+	Message    string `json:"message"`
+	RoomId     string `json:"roomId,omitempty"` // * every room when missing
+	SendAt     int64  `json:"sendAt"`           // timestamp
+	Interval   int    `json:"interval"`         // minutes, 0 is sent once
+	CreatedBy  uint   `json:"createdBy"`
+	LastSentAt int64  `json:"lastSentAt,omitempty"` // timestamp
+}
+
+// AnnouncementService sends the system messages of the admins, right away or
+// scheduled, and sets the welcome texts of the rooms
+type AnnouncementService struct {
+	announcementRepo *repositories.AnnouncementRepoContext
+	logger           core.LoggerI
+}
+
+func NewAnnouncementService(logger core.LoggerI, announcementRepo *repositories.AnnouncementRepoContext) *AnnouncementService {
+	return &AnnouncementService{
+		announcementRepo: announcementRepo,
+		logger:           logger,
+	}
+}
+
+// Send posts msg in the room right away, in every room when roomId is empty
+func (ctx *AnnouncementService) Send(adminId uint, roomId types.RoomId, msg string) error {
+	var err error
+	if len(roomId) == 0 {
+		err = Announce(msg)
+	} else {
+		err = SendSystemMessage(roomId, msg)
+	}
+
+	if errors.Is(err, ErrorRoomNotExists) {
+		return err
+	}
+
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("announcement sent by %d to %q: %s", adminId, roomId, msg))
+
+	return nil
+}
+
+// SetWelcomeText changes the text the users joining the room get
+func (ctx *AnnouncementService) SetWelcomeText(adminId uint, roomId types.RoomId, text string) error {
+	if err := SetWelcomeText(roomId, text); err != nil {
+		return err
+	}
+
+	ctx.logger.Info(fmt.Sprintf("welcome text of %s set by %d", roomId, adminId))
+
+	return nil
+}
+
+// Schedule sends msg at sendAt, right away when it's zero, then every interval
+// minutes when it's not 0. An empty roomId is every room.
+func (ctx *AnnouncementService) Schedule(adminId uint, msg string, roomId types.RoomId, sendAt time.Time, interval int) (*AnnouncementResponse, error) {
+	if interval < 0 {
+		return nil, ErrorInvalidInterval
+	}
+
+	if len(roomId) > 0 {
+		if _, exists := memory_storage.GetRoom(roomId); !exists {
+			return nil, ErrorRoomNotExists
+		}
+	}
+
+	if sendAt.IsZero() {
+		sendAt = time.Now()
+	}
+
+	announcement, err := ctx.announcementRepo.Save(models.Announcement{
+		Message:   msg,
+		RoomID:    string(roomId),
+		SendAt:    sendAt,
+		Interval:  interval,
+		CreatedBy: adminId,
+	})
+
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("announcement %d scheduled by %d at %s", announcement.ID, adminId, sendAt.Format(time.RFC3339)))
+
+	return newAnnouncementResponse(announcement), nil
+}
+
+// List returns the announcements that are still to be sent
+func (ctx *AnnouncementService) List() ([]AnnouncementResponse, error) {
+	announcements, err := ctx.announcementRepo.ListPending()
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]AnnouncementResponse, 0, len(announcements))
+	for _, announcement := range announcements {
+		response = append(response, *newAnnouncementResponse(&announcement))
+	}
+
+	return response, nil
+}
+
+func (ctx *AnnouncementService) Cancel(adminId uint, announcementId uint) error {
+	if err := ctx.announcementRepo.Delete(announcementId); err != nil {
+		if errors.Is(err, repositories.ErrorAnnouncementNotFound) {
+			return ErrorAnnouncementNotFound
+		}
+
+		return ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("announcement %d cancelled by %d", announcementId, adminId))
+
+	return nil
+}
+
+// SendDue sends the announcements whose time has come, when no other node
+// is doing it
+func (ctx *AnnouncementService) SendDue() {
+	lock, err := memory_storage.TryLock(announcementsLock, announcementsLockTTL)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return
+	}
+
+	if lock == nil {
+		return
+	}
+
+	now := time.Now()
+
+	announcements, err := ctx.announcementRepo.ListDue(now)
+	if err != nil {
+		ctx.logger.Error(fmt.Sprintf("failed to get due announcements: %v", err))
+		return
+	}
+
+	for _, announcement := range announcements {
+		if err := sendAnnouncement(&announcement); err != nil {
+			ctx.logger.Error(fmt.Sprintf("failed to send announcement %d: %v", announcement.ID, err))
+		}
+
+		if err := ctx.announcementRepo.MarkSent(announcement.ID, now, nextSendAt(&announcement, now, err)); err != nil {
+			ctx.logger.Error(err.Error())
+		}
+	}
+}
+
+// StartScheduler runs SendDue periodically, it blocks
+func (ctx *AnnouncementService) StartScheduler() {
+	ticker := time.NewTicker(announcementsTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx.SendDue()
+	}
+}
+
+func sendAnnouncement(announcement *models.Announcement) error {
+	if announcement.RoomID == "" {
+		return Announce(announcement.Message)
+	}
+
+	return SendSystemMessage(types.RoomId(announcement.RoomID), announcement.Message)
+}
+
+// nextSendAt returns when a repeating announcement is sent again, the runs
+// missed while no node was up are skipped. Announcements of a closed room stop.
+func nextSendAt(announcement *models.Announcement, now time.Time, sendErr error) *time.Time {
+	if announcement.Interval == 0 || errors.Is(sendErr, ErrorRoomNotExists) {
+		return nil
+	}
+
+	interval := time.Duration(announcement.Interval) * time.Minute
+
+	next := announcement.SendAt
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+
+	return &next
+}
+
+func newAnnouncementResponse(announcement *models.Announcement) *AnnouncementResponse {
+	response := &AnnouncementResponse{
+		ID:        announcement.ID,
+		Message:   announcement.Message,
+		RoomId:    announcement.RoomID,
+		SendAt:    announcement.SendAt.Unix(),
+		Interval:  announcement.Interval,
+		CreatedBy: announcement.CreatedBy,
+	}
+
+	if announcement.LastSentAt != nil {
+		response.LastSentAt = announcement.LastSentAt.Unix()
+	}
+
+	return response
+}
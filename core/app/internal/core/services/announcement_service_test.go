@@ -0,0 +1,50 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextSendAt(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		sendAt   time.Time
+		interval int
+		sendErr  error
+		want     *time.Time
+	}{
+		{name: "sent once", sendAt: now, interval: 0, want: nil},
+		{name: "next run", sendAt: now, interval: 10, want: timePtr(now.Add(10 * time.Minute))},
+		{name: "run due later", sendAt: now.Add(-time.Minute), interval: 10, want: timePtr(now.Add(9 * time.Minute))},
+		{name: "missed runs are skipped", sendAt: now.Add(-25 * time.Minute), interval: 10, want: timePtr(now.Add(5 * time.Minute))},
+		{name: "run ending now is skipped", sendAt: now.Add(-20 * time.Minute), interval: 10, want: timePtr(now.Add(10 * time.Minute))},
+		{name: "send error keeps repeating", sendAt: now, interval: 10, sendErr: errors.New("redis is down"), want: timePtr(now.Add(10 * time.Minute))},
+		{name: "closed room stops", sendAt: now, interval: 10, sendErr: ErrorRoomNotExists, want: nil},
+		{name: "wrapped closed room stops", sendAt: now, interval: 10, sendErr: fmt.Errorf("announce: %w", ErrorRoomNotExists), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcement := &models.Announcement{SendAt: tt.sendAt, Interval: tt.interval}
+
+			got := nextSendAt(announcement, now, tt.sendErr)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("got %s, want no next run", got)
+			case tt.want != nil && got == nil:
+				t.Errorf("got no next run, want %s", tt.want)
+			case tt.want != nil && !got.Equal(*tt.want):
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	types "core/types"
+	"errors"
+	"fmt"
+	mathRand "math/rand"
+)
+
+const (
+	maxAvatarAccessories = 3
+)
+
+var (
+	ErrorInvalidAvatarBody      = errors.New("invalid avatar body")
+	ErrorInvalidAvatarColor     = errors.New("invalid avatar color")
+	ErrorInvalidAvatarAccessory = errors.New("invalid avatar accessory")
+	ErrorTooManyAccessories     = fmt.Errorf("avatars can't have more than %d accessories", maxAvatarAccessories)
+)
+
+type AvatarCatalogResponse struct {
+	Bodies         []string `json:"bodies"`
+	Colors         []string `json:"colors"`
+	Accessories    []string `json:"accessories"`
+	MaxAccessories int      `json:"maxAccessories"`
+}
+
+// AvatarCatalog lists the parts an avatar can be made of
+var AvatarCatalog = AvatarCatalogResponse{
+	Bodies:         []string{"ghost", "ghoul", "skull", "pumpkin", "bat"},
+	Colors:         []string{"#f5f5f5", "#1f2937", "#7c3aed", "#db2777", "#f97316", "#facc15", "#22c55e", "#06b6d4"},
+	Accessories:    []string{"hat", "bow", "glasses", "scarf", "crown", "headphones", "cape"},
+	MaxAccessories: maxAvatarAccessories,
+}
+
+func validateAvatar(avatar types.Avatar) error {
+	if !inSlice(AvatarCatalog.Bodies, avatar.Body) {
+		return ErrorInvalidAvatarBody
+	}
+
+	if !inSlice(AvatarCatalog.Colors, avatar.PrimaryColor) || !inSlice(AvatarCatalog.Colors, avatar.SecondaryColor) {
+		return ErrorInvalidAvatarColor
+	}
+
+	if len(avatar.Accessories) > maxAvatarAccessories {
+		return ErrorTooManyAccessories
+	}
+
+	seen := make(map[string]struct{})
+	for _, accessory := range avatar.Accessories {
+		if _, repeated := seen[accessory]; repeated || !inSlice(AvatarCatalog.Accessories, accessory) {
+			return ErrorInvalidAvatarAccessory
+		}
+
+		seen[accessory] = struct{}{}
+	}
+
+	return nil
+}
+
+func avatarFromRand(rnd *mathRand.Rand) types.Avatar {
+	return types.Avatar{
+		Body:           AvatarCatalog.Bodies[rnd.Intn(len(AvatarCatalog.Bodies))],
+		PrimaryColor:   AvatarCatalog.Colors[rnd.Intn(len(AvatarCatalog.Colors))],
+		SecondaryColor: AvatarCatalog.Colors[rnd.Intn(len(AvatarCatalog.Colors))],
+		Accessories:    []string{},
+	}
+}
+
+// RandomAvatar is the default avatar given to guests
+func RandomAvatar() types.Avatar {
+	return avatarFromRand(mathRand.New(mathRand.NewSource(mathRand.Int63())))
+}
+
+// defaultAvatar is used for accounts that didn't customize their avatar yet,
+// it's derived from the user id so it's the same on every room.
+func defaultAvatar(userId uint) types.Avatar {
+	return avatarFromRand(mathRand.New(mathRand.NewSource(int64(userId))))
+}
+
+func (ctx *UserService) GetAvatarCatalog() AvatarCatalogResponse {
+	return AvatarCatalog
+}
+
+func (ctx *UserService) GetAvatar(userId uint) types.Avatar {
+	avatar, err := ctx.avatarRepo.GetByUserId(userId)
+	if err != nil {
+		return defaultAvatar(userId)
+	}
+
+	accessories := avatar.Accessories
+	if accessories == nil {
+		accessories = []string{}
+	}
+
+	return types.Avatar{
+		Body:           avatar.Body,
+		PrimaryColor:   avatar.PrimaryColor,
+		SecondaryColor: avatar.SecondaryColor,
+		Accessories:    accessories,
+	}
+}
+
+func (ctx *UserService) UpdateAvatar(userId uint, avatar types.Avatar) (*types.Avatar, error) {
+	if avatar.Accessories == nil {
+		avatar.Accessories = []string{}
+	}
+
+	if err := validateAvatar(avatar); err != nil {
+		return nil, err
+	}
+
+	_, err := ctx.avatarRepo.Save(models.Avatar{
+		UserID:         userId,
+		Body:           avatar.Body,
+		PrimaryColor:   avatar.PrimaryColor,
+		SecondaryColor: avatar.SecondaryColor,
+		Accessories:    avatar.Accessories,
+	})
+
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	return &avatar, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/types"
+	"errors"
+	"fmt"
+	mathRand "math/rand"
+	"strings"
+)
+
+const (
+	RoomTopicEvent = "roomTopic"
+
+	DefaultRollSides = 100
+	maxRollSides     = 1000
+	maxTopicLen      = 100
+)
+
+var (
+	ErrorUnknownCommand   = errors.New("unknown command, try /help")
+	ErrorCommandForbidden = errors.New("you can't use this command in this room")
+	ErrorCommandUsage     = errors.New("usage")
+	ErrorInvalidRoll      = errors.New("a die has between 2 and 1000 sides")
+	ErrorCannotKickSelf   = errors.New("you can't kick yourself")
+	ErrorCannotKickUser   = errors.New("you can't kick this user")
+	ErrorTopicTooLong     = errors.New("topic is too long")
+)
+
+// FindRoomUser returns the user of the room with the username, ignoring case
+func FindRoomUser(roomId types.RoomId, username string) (*types.User, error) {
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return nil, ErrorRoomNotExists
+	}
+
+	for _, user := range room.Users {
+		if strings.EqualFold(user.UserName, username) {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrorRecipientNotInRoom
+}
+
+// Roll throws a die and tells the room the result as an action of the user
+func Roll(roomId types.RoomId, messageClient *types.MessageClient, userId types.UserID, sides int) error {
+	if sides < 2 || sides > maxRollSides {
+		return ErrorInvalidRoll
+	}
+
+	return BroadcastAction(types.Msg{
+		From:   userId,
+		RoomId: roomId,
+		Msg:    fmt.Sprintf("rolls %d (1-%d)", mathRand.Intn(sides)+1, sides),
+	}, messageClient, userId)
+}
+
+// KickUser removes the user from the room, it stays connected and gets a
+// "roomKicked" event. Only the users with a lower room role can be kicked.
+func KickUser(roomId types.RoomId, kicker *types.Client, userId types.UserID) error {
+	if userId == kicker.ID {
+		return ErrorCannotKickSelf
+	}
+
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return ErrorRoomNotExists
+	}
+
+	target, err := memory_storage.GetClient(userId)
+	if err != nil || target.RoomId != roomId {
+		return ErrorRecipientNotInRoom
+	}
+
+	if RoomRoleOf(room, target) >= RoomRoleOf(room, kicker) {
+		return ErrorCannotKickUser
+	}
+
+	leaveRoomId(userId)
+	RemoveUser(userId, roomId)
+
+	// * the node of the user drops its subscription and tells it who kicked it
+	return memory_storage.PublishControl(types.ControlMessage{
+		Type:   types.ControlKickUser,
+		RoomId: roomId,
+		UserID: userId,
+		Reason: kicker.Username,
+	})
+}
+
+// SetTopic changes the topic of the room and tells its users, an empty topic
+// clears it
+func SetTopic(roomId types.RoomId, setter *types.Client, topic string) error {
+	topic = strings.TrimSpace(topic)
+	if len(topic) > maxTopicLen {
+		return ErrorTopicTooLong
+	}
+
+	_, err := updateRoom(roomId, func(room *types.RoomData) error {
+		room.Topic = topic
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	memory_storage.BroadcastRoom(roomId, RoomTopicEvent, types.RoomTopic{
+		RoomId: roomId,
+		Topic:  topic,
+		By:     setter.Username,
+	})
+
+	return nil
+}
+
+// sendTopic tells the user that just joined the topic of the room
+func sendTopic(messageClient *types.MessageClient, roomId types.RoomId, room *types.RoomData) {
+	if room.Topic == "" {
+		return
+	}
+
+	err := SendPayload(messageClient, types.WsPayload{
+		Event: RoomTopicEvent,
+		Data: types.RoomTopic{
+			RoomId: roomId,
+			Topic:  room.Topic,
+		},
+	})
+
+	if err != nil {
+		fmt.Printf("failed to send the topic of %s: %v\n", roomId, err)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	types "core/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	emoteRateLimit  int64         = 5
+	emoteRateWindow time.Duration = 10 * time.Second
+
+	maxRoomSeats = GridSize * GridSize / 4
+)
+
+var (
+	ErrorUnknownEmote     = errors.New("unknown emote")
+	ErrorEmoteRateLimited = errors.New("too many emotes, slow down")
+	ErrorSeatRequired     = errors.New("you can only sit on a seat")
+	ErrorUserNotInRoom    = errors.New("user is not in the room")
+	ErrorInvalidSeat      = fmt.Errorf("seats must be \"row,col\" tiles of the %dx%d grid", GridSize, GridSize)
+	ErrorTooManySeats     = fmt.Errorf("a room can have at most %d seats", maxRoomSeats)
+)
+
+type Emote struct {
+	Duration time.Duration // * 0 lasts until the user moves
+	Seated   bool          // * requires a seat tile when the room layout defines them
+}
+
+// EmoteCatalog lists the emotes clients are allowed to send
+var EmoteCatalog = map[string]Emote{
+	"wave":  {Duration: 3 * time.Second},
+	"laugh": {Duration: 3 * time.Second},
+	"clap":  {Duration: 3 * time.Second},
+	"cry":   {Duration: 5 * time.Second},
+	"jump":  {Duration: 2 * time.Second},
+	"dance": {Duration: 10 * time.Second},
+	"sit":   {Seated: true},
+}
+
+// roomLayout validates the seats of a new room, the room has no layout when
+// there are none
+func roomLayout(seats []string) (*types.RoomLayout, error) {
+	if len(seats) == 0 {
+		return nil, nil
+	}
+
+	if len(seats) > maxRoomSeats {
+		return nil, ErrorTooManySeats
+	}
+
+	layout := &types.RoomLayout{Seats: []string{}}
+	for _, seat := range seats {
+		var row, col int
+		if n, err := fmt.Sscanf(seat, "%d,%d", &row, &col); err != nil || n != 2 {
+			return nil, ErrorInvalidSeat
+		}
+
+		if row < 0 || row >= GridSize || col < 0 || col >= GridSize {
+			return nil, ErrorInvalidSeat
+		}
+
+		// * stored like the positions so that isSeat can compare them
+		normalized := fmt.Sprintf("%d,%d", row, col)
+		if !inSlice(layout.Seats, normalized) {
+			layout.Seats = append(layout.Seats, normalized)
+		}
+	}
+
+	return layout, nil
+}
+
+func isSeat(layout *types.RoomLayout, position types.Position) bool {
+	if layout == nil || len(layout.Seats) == 0 {
+		return true
+	}
+
+	return inSlice(layout.Seats, fmt.Sprintf("%d,%d", position.Row, position.Col))
+}
+
+// UpdateUserEmote sets the emote on the user's avatar and broadcasts it to
+// the room. Timed emotes are cleared once their duration is over.
+func UpdateUserEmote(roomId types.RoomId, userId types.UserID, emoteName string) error {
+	emote, exists := EmoteCatalog[emoteName]
+	if !exists {
+		return ErrorUnknownEmote
+	}
+
+	allowed, err := memory_storage.AllowRate(fmt.Sprintf("emote:%s", userId), emoteRateLimit, emoteRateWindow)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return ErrorEmoteRateLimited
+	}
+
+	var user types.User
+	_, err = updateRoom(roomId, func(room *types.RoomData) error {
+		userIdx, exists := room.UserIdxMap[userId]
+		if !exists {
+			return ErrorUserNotInRoom
+		}
+
+		if emote.Seated && !isSeat(room.Layout, room.Users[userIdx].Position) {
+			return ErrorSeatRequired
+		}
+
+		room.Users[userIdx].Emote = emoteName
+		room.Users[userIdx].EmoteUntil = 0
+		if emote.Duration > 0 {
+			room.Users[userIdx].EmoteUntil = time.Now().Add(emote.Duration).UnixMilli()
+		}
+
+		user = room.Users[userIdx]
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	memory_storage.BroadcastRoom(roomId, "updateUser", types.UpdateUserPosition{
+		User: user,
+	})
+
+	if emote.Duration > 0 {
+		emoteUntil := user.EmoteUntil
+		time.AfterFunc(emote.Duration, func() {
+			clearUserEmote(roomId, userId, emoteUntil)
+		})
+	}
+
+	return nil
+}
+
+// clearUserEmote ends a timed emote unless it was already replaced by
+// another one or cleared by a movement
+func clearUserEmote(roomId types.RoomId, userId types.UserID, emoteUntil int64) {
+	var user types.User
+	_, err := updateRoom(roomId, func(room *types.RoomData) error {
+		userIdx, exists := room.UserIdxMap[userId]
+		if !exists {
+			return ErrorUserNotInRoom
+		}
+
+		if room.Users[userIdx].Emote == "" || room.Users[userIdx].EmoteUntil != emoteUntil {
+			return errRoomUnchanged
+		}
+
+		room.Users[userIdx].Emote = ""
+		room.Users[userIdx].EmoteUntil = 0
+
+		user = room.Users[userIdx]
+		return nil
+	})
+
+	if err != nil {
+		return
+	}
+
+	memory_storage.BroadcastRoom(roomId, "updateUser", types.UpdateUserPosition{
+		User: user,
+	})
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	repositories "core/internal/ports"
+	"core/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	FriendsPresenceEvent = "friendsPresence"
+	FriendRequestEvent   = "friendRequest"
+)
+
+var (
+	ErrorCannotFriendSelf     = errors.New("you can't add yourself as a friend")
+	ErrorAlreadyFriends       = errors.New("you are already friends")
+	ErrorFriendRequestExists  = errors.New("friend request already sent")
+	ErrorFriendRequestBlocked = errors.New("can't send a friend request to this user")
+	ErrorFriendRequestMissing = errors.New("friend request not found")
+	ErrorNotFriends           = errors.New("you are not friends")
+	ErrorNotBlocked           = errors.New("user is not blocked")
+	ErrorFriendNotInRoom      = errors.New("friend is not in a room")
+	ErrorSignInRequired       = errors.New("sign in to use friends")
+)
+
+type Friend struct {
+	types.Presence
+	Since int64 `json:"since"` // timestamp
+}
+
+type FriendRequest struct {
+	AccountID uint   `json:"accountId"`
+	Username  string `json:"username"`
+	SentAt    int64  `json:"sentAt"` // timestamp
+}
+
+type FriendsResponse struct {
+	Friends  []Friend        `json:"friends"`
+	Incoming []FriendRequest `json:"incoming"`
+	Outgoing []FriendRequest `json:"outgoing"`
+	Blocked  []FriendRequest `json:"blocked"`
+}
+
+type FriendService struct {
+	friendshipRepo *repositories.FriendshipRepoContext
+	userRepo       *repositories.UserRepoContext
+	logger         core.LoggerI
+}
+
+func NewFriendService(logger core.LoggerI, friendshipRepo *repositories.FriendshipRepoContext, userRepo *repositories.UserRepoContext) *FriendService {
+	return &FriendService{
+		friendshipRepo: friendshipRepo,
+		userRepo:       userRepo,
+		logger:         logger,
+	}
+}
+
+// List returns the friends with their presence, the pending requests and the
+// blocked users
+func (ctx *FriendService) List(userId uint) (*FriendsResponse, error) {
+	friendships, err := ctx.friendshipRepo.ListByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	otherIds := make([]uint, 0, len(friendships))
+	for _, friendship := range friendships {
+		otherIds = append(otherIds, friendship.Other(userId))
+	}
+
+	users, err := ctx.userRepo.GetByIds(otherIds)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	response := &FriendsResponse{
+		Friends:  []Friend{},
+		Incoming: []FriendRequest{},
+		Outgoing: []FriendRequest{},
+		Blocked:  []FriendRequest{},
+	}
+
+	for _, friendship := range friendships {
+		otherId := friendship.Other(userId)
+
+		// * deleted accounts are hidden until they are purged
+		username, exists := usernames[otherId]
+		if !exists {
+			continue
+		}
+
+		request := FriendRequest{
+			AccountID: otherId,
+			Username:  username,
+			SentAt:    friendship.CreatedAt.Unix(),
+		}
+
+		switch friendshipList(&friendship, userId) {
+		case friendsList:
+			response.Friends = append(response.Friends, Friend{
+				Presence: ctx.presence(otherId, username),
+				Since:    friendship.UpdatedAt.Unix(),
+			})
+		case incomingList:
+			response.Incoming = append(response.Incoming, request)
+		case outgoingList:
+			response.Outgoing = append(response.Outgoing, request)
+		case blockedList:
+			response.Blocked = append(response.Blocked, request)
+		}
+	}
+
+	return response, nil
+}
+
+type friendsListKind int
+
+const (
+	hiddenList friendsListKind = iota // * blocked by the other user
+	friendsList
+	incomingList
+	outgoingList
+	blockedList
+)
+
+// friendshipList returns the list of the response the friendship goes in, as
+// seen by userId
+func friendshipList(friendship *models.Friendship, userId uint) friendsListKind {
+	switch {
+	case friendship.Status == models.FriendshipAccepted:
+		return friendsList
+	case friendship.Status == models.FriendshipPending && friendship.AddresseeID == userId:
+		return incomingList
+	case friendship.Status == models.FriendshipPending:
+		return outgoingList
+	case friendship.Status == models.FriendshipBlocked && friendship.RequesterID == userId:
+		return blockedList
+	default:
+		return hiddenList
+	}
+}
+
+// SendRequest asks username to be friends. A pending request from them is
+// accepted instead.
+func (ctx *FriendService) SendRequest(userId uint, username string) error {
+	addressee, err := ctx.userRepo.GetByUsername(username)
+	if err != nil {
+		return ErrorUserNotFound
+	}
+
+	if addressee.ID == userId {
+		return ErrorCannotFriendSelf
+	}
+
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, addressee.ID)
+	if err == nil {
+		if err := checkFriendRequest(friendship, userId); err != nil {
+			return err
+		}
+
+		// * they asked first
+		return ctx.Accept(userId, addressee.ID)
+	}
+
+	_, err = ctx.friendshipRepo.Save(models.Friendship{
+		RequesterID: userId,
+		AddresseeID: addressee.ID,
+		Status:      models.FriendshipPending,
+	})
+
+	if err != nil {
+		return ErrorSaveFailed
+	}
+
+	ctx.notifyRequest(userId, addressee.ID)
+
+	return nil
+}
+
+// checkFriendRequest tells why userId can't send a request to the other user
+// of an existing friendship, it's nil when the other user's request is pending
+func checkFriendRequest(friendship *models.Friendship, userId uint) error {
+	switch {
+	case friendship.Status == models.FriendshipBlocked:
+		return ErrorFriendRequestBlocked
+	case friendship.Status == models.FriendshipAccepted:
+		return ErrorAlreadyFriends
+	case friendship.RequesterID == userId:
+		return ErrorFriendRequestExists
+	default:
+		return nil
+	}
+}
+
+// Accept accepts the friend request requesterId sent to the user
+func (ctx *FriendService) Accept(userId uint, requesterId uint) error {
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, requesterId)
+	if err != nil || friendshipList(friendship, userId) != incomingList {
+		return ErrorFriendRequestMissing
+	}
+
+	if err := ctx.friendshipRepo.UpdateStatus(friendship.ID, models.FriendshipAccepted); err != nil {
+		return ErrorSaveFailed
+	}
+
+	// * both now see each other online
+	ctx.NotifyPresence(userId)
+	ctx.NotifyPresence(requesterId)
+
+	return nil
+}
+
+// Remove unfriends otherId, or declines or cancels a pending request
+func (ctx *FriendService) Remove(userId uint, otherId uint) error {
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, otherId)
+	if err != nil || friendship.Status == models.FriendshipBlocked {
+		return ErrorNotFriends
+	}
+
+	if err := ctx.friendshipRepo.Delete(friendship.ID); err != nil {
+		return ErrorSaveFailed
+	}
+
+	return nil
+}
+
+// Block replaces any friendship with otherId with a block, the blocked user
+// can't send friend requests anymore
+func (ctx *FriendService) Block(userId uint, otherId uint) error {
+	if userId == otherId {
+		return ErrorCannotFriendSelf
+	}
+
+	if _, err := ctx.userRepo.GetById(float64(otherId)); err != nil {
+		return ErrorUserNotFound
+	}
+
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, otherId)
+	if err == nil {
+		// * the pair is already blocked, by either user
+		if friendship.Status == models.FriendshipBlocked {
+			return nil
+		}
+
+		if err := ctx.friendshipRepo.Delete(friendship.ID); err != nil {
+			return ErrorSaveFailed
+		}
+	}
+
+	_, err = ctx.friendshipRepo.Save(models.Friendship{
+		RequesterID: userId,
+		AddresseeID: otherId,
+		Status:      models.FriendshipBlocked,
+	})
+
+	if err != nil {
+		return ErrorSaveFailed
+	}
+
+	ctx.publishBlock(userId, otherId, true)
+
+	return nil
+}
+
+func (ctx *FriendService) Unblock(userId uint, otherId uint) error {
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, otherId)
+	if err != nil || friendshipList(friendship, userId) != blockedList {
+		return ErrorNotBlocked
+	}
+
+	if err := ctx.friendshipRepo.Delete(friendship.ID); err != nil {
+		return ErrorSaveFailed
+	}
+
+	ctx.publishBlock(userId, otherId, false)
+
+	return nil
+}
+
+// BlockedIds returns the accounts the user blocked or was blocked by, their
+// messages aren't delivered to each other
+func (ctx *FriendService) BlockedIds(userId uint) ([]uint, error) {
+	return ctx.friendshipRepo.BlockedIds(userId)
+}
+
+// AreFriends reports whether the users have an accepted friendship
+func (ctx *FriendService) AreFriends(userId uint, otherId uint) bool {
+	friendship, err := ctx.friendshipRepo.GetBetween(userId, otherId)
+	return err == nil && friendship.Status == models.FriendshipAccepted
+}
+
+// FriendPresence returns where a friend of the user is
+func (ctx *FriendService) FriendPresence(userId uint, friendId uint) (*types.Presence, error) {
+	if !ctx.AreFriends(userId, friendId) {
+		return nil, ErrorNotFriends
+	}
+
+	return memory_storage.GetPresence(friendId)
+}
+
+// FriendsPresence returns the presence of every friend of the user
+func (ctx *FriendService) FriendsPresence(userId uint) (*types.FriendsPresence, error) {
+	friends, err := ctx.List(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &types.FriendsPresence{
+		Friends: make([]types.Presence, 0, len(friends.Friends)),
+	}
+
+	for _, friend := range friends.Friends {
+		presence.Friends = append(presence.Friends, friend.Presence)
+	}
+
+	return presence, nil
+}
+
+// NotifyPresence pushes the presence of the account to its online friends,
+// it's called when the account connects, disconnects or changes room
+func (ctx *FriendService) NotifyPresence(accountId uint) {
+	if accountId == 0 {
+		return
+	}
+
+	friendIds, err := ctx.friendshipRepo.FriendIds(accountId)
+	if err != nil {
+		ctx.logger.Error(fmt.Sprintf("failed to get friends of %d: %v", accountId, err))
+		return
+	}
+
+	if len(friendIds) == 0 {
+		return
+	}
+
+	username := ""
+	if user, err := ctx.userRepo.GetById(float64(accountId)); err == nil {
+		username = user.Username
+	}
+
+	err = memory_storage.DeliverAccounts(friendIds, FriendsPresenceEvent, types.FriendsPresence{
+		Friends: []types.Presence{ctx.presence(accountId, username)},
+	})
+
+	if err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+// notifyRequest tells the addressee about a new friend request when online
+func (ctx *FriendService) notifyRequest(requesterId uint, addresseeId uint) {
+	username := ""
+	if user, err := ctx.userRepo.GetById(float64(requesterId)); err == nil {
+		username = user.Username
+	}
+
+	err := memory_storage.DeliverAccounts([]uint{addresseeId}, FriendRequestEvent, FriendRequest{
+		AccountID: requesterId,
+		Username:  username,
+		SentAt:    time.Now().Unix(),
+	})
+
+	if err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+// publishBlock updates the message filters of the live connections of both
+// accounts
+func (ctx *FriendService) publishBlock(userId uint, otherId uint, blocked bool) {
+	if err := memory_storage.PublishBlock(userId, otherId, blocked); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+func (ctx *FriendService) presence(accountId uint, username string) types.Presence {
+	presence, err := memory_storage.GetPresence(accountId)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		presence = &types.Presence{AccountID: accountId}
+	}
+
+	// * the username of the account, not of the connection
+	presence.Username = username
+
+	return *presence
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"testing"
+)
+
+const (
+	testUserId  uint = 1
+	testOtherId uint = 2
+)
+
+func testFriendship(requesterId uint, status string) *models.Friendship {
+	addresseeId := testOtherId
+	if requesterId == testOtherId {
+		addresseeId = testUserId
+	}
+
+	return &models.Friendship{RequesterID: requesterId, AddresseeID: addresseeId, Status: status}
+}
+
+func TestFriendshipList(t *testing.T) {
+	tests := []struct {
+		name       string
+		friendship *models.Friendship
+		want       friendsListKind
+	}{
+		{name: "accepted request", friendship: testFriendship(testUserId, models.FriendshipAccepted), want: friendsList},
+		{name: "accepted request of the other user", friendship: testFriendship(testOtherId, models.FriendshipAccepted), want: friendsList},
+		{name: "request received", friendship: testFriendship(testOtherId, models.FriendshipPending), want: incomingList},
+		{name: "request sent", friendship: testFriendship(testUserId, models.FriendshipPending), want: outgoingList},
+		{name: "blocked the other user", friendship: testFriendship(testUserId, models.FriendshipBlocked), want: blockedList},
+		{name: "blocked by the other user", friendship: testFriendship(testOtherId, models.FriendshipBlocked), want: hiddenList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := friendshipList(tt.friendship, testUserId); got != tt.want {
+				t.Errorf("friendshipList = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFriendRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		friendship *models.Friendship
+		wantErr    error
+	}{
+		{name: "request already sent", friendship: testFriendship(testUserId, models.FriendshipPending), wantErr: ErrorFriendRequestExists},
+		{name: "request of the other user is accepted", friendship: testFriendship(testOtherId, models.FriendshipPending)},
+		{name: "already friends", friendship: testFriendship(testOtherId, models.FriendshipAccepted), wantErr: ErrorAlreadyFriends},
+		{name: "blocked the other user", friendship: testFriendship(testUserId, models.FriendshipBlocked), wantErr: ErrorFriendRequestBlocked},
+		{name: "blocked by the other user", friendship: testFriendship(testOtherId, models.FriendshipBlocked), wantErr: ErrorFriendRequestBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkFriendRequest(tt.friendship, testUserId); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got err %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFriendshipOther(t *testing.T) {
+	friendship := testFriendship(testUserId, models.FriendshipAccepted)
+
+	if other := friendship.Other(testUserId); other != testOtherId {
+		t.Errorf("Other(requester) = %d, want %d", other, testOtherId)
+	}
+
+	if other := friendship.Other(testOtherId); other != testUserId {
+		t.Errorf("Other(addressee) = %d, want %d", other, testUserId)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// * failures allowed before the first lockout, each failure over them
+	// * doubles the lockout up to its max
+	accountFreeLoginAttempts = 5
+	ipFreeLoginAttempts      = 20
+
+	loginFailuresWindow = time.Hour
+	baseLoginLockout    = 30 * time.Second
+	maxAccountLockout   = 15 * time.Minute
+	maxIpLockout        = time.Hour
+)
+
+var (
+	ErrorTooManyLoginAttempts = errors.New("too many login attempts, try again later")
+)
+
+var (
+	// * compared against when the email isn't registered so both failures
+	// * take about the same time
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// loginGuard locks the accounts and the ips out after too many failed
+// password checks, the logins and the actions that ask for the password again
+// go through it
+type loginGuard struct {
+	logger core.LoggerI
+}
+
+type loginCounter struct {
+	key          string
+	freeAttempts int64
+	maxLockout   time.Duration
+}
+
+func loginCounters(email string, ip string) []loginCounter {
+	return []loginCounter{
+		{
+			key:          fmt.Sprintf("account:%s", strings.ToLower(strings.TrimSpace(email))),
+			freeAttempts: accountFreeLoginAttempts,
+			maxLockout:   maxAccountLockout,
+		},
+		{
+			key:          fmt.Sprintf("ip:%s", ip),
+			freeAttempts: ipFreeLoginAttempts,
+			maxLockout:   maxIpLockout,
+		},
+	}
+}
+
+// loginLockout returns the lockout after failures failed logins
+func (counter loginCounter) loginLockout(failures int64) time.Duration {
+	exceeded := failures - counter.freeAttempts
+	if exceeded <= 0 {
+		return 0
+	}
+
+	lockout := baseLoginLockout
+	for i := int64(1); i < exceeded && lockout < counter.maxLockout; i++ {
+		lockout *= 2
+	}
+
+	return min(lockout, counter.maxLockout)
+}
+
+// checkLoginLocked fails when the account or the ip are locked out. The
+// counters fail open, a storage error doesn't block logins.
+func (ctx loginGuard) checkLoginLocked(email string, ip string) error {
+	for _, counter := range loginCounters(email, ip) {
+		lockedFor, err := memory_storage.LoginLockedFor(counter.key)
+		if err != nil {
+			ctx.logger.Error(err.Error())
+			continue
+		}
+
+		if lockedFor > 0 {
+			return ErrorTooManyLoginAttempts
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts the failure for the account and the ip, locking
+// them out once they run out of free attempts
+func (ctx loginGuard) recordLoginFailure(email string, ip string) {
+	for _, counter := range loginCounters(email, ip) {
+		failures, err := memory_storage.RecordLoginFailure(counter.key, loginFailuresWindow)
+		if err != nil {
+			ctx.logger.Error(err.Error())
+			continue
+		}
+
+		lockout := counter.loginLockout(failures)
+		if lockout == 0 {
+			continue
+		}
+
+		if err := memory_storage.LockLogin(counter.key, lockout); err != nil {
+			ctx.logger.Error(err.Error())
+			continue
+		}
+
+		ctx.logger.Warn(fmt.Sprintf("login locked out %s failures=%d for=%s", counter.key, failures, lockout))
+	}
+}
+
+// resetLoginFailures forgets the failures of the account, the ip keeps its
+// counter so a valid account can't be used to reset it
+func (ctx loginGuard) resetLoginFailures(email string) {
+	counter := loginCounters(email, "")[0]
+	if err := memory_storage.ResetLoginFailures(counter.key); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+// checkPassword compares the password of a signed in user, the failures count
+// like the ones of the login
+func (ctx loginGuard) checkPassword(user *models.User, password string, ip string) error {
+	if err := ctx.checkLoginLocked(user.Email, ip); err != nil {
+		return err
+	}
+
+	if !core.CompareHashAndPassword(user.Password, password) {
+		ctx.recordLoginFailure(user.Email, ip)
+		return ErrorInvalidPassword
+	}
+
+	ctx.resetLoginFailures(user.Email)
+
+	return nil
+}
+
+func compareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		hash, err := core.GenPasswordHash("dummy-password")
+		if err == nil {
+			dummyPasswordHash = string(*hash)
+		}
+	})
+
+	core.CompareHashAndPassword(dummyPasswordHash, password)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	counters := loginCounters("Alice@Wonderland.tld ", "127.0.0.1")
+	account, ip := counters[0], counters[1]
+
+	if account.key != "account:alice@wonderland.tld" {
+		t.Errorf("account key %q isn't normalized", account.key)
+	}
+
+	tests := []struct {
+		name     string
+		counter  loginCounter
+		failures int64
+		want     time.Duration
+	}{
+		{name: "account first failure", counter: account, failures: 1, want: 0},
+		{name: "account last free attempt", counter: account, failures: accountFreeLoginAttempts, want: 0},
+		{name: "account first lockout", counter: account, failures: accountFreeLoginAttempts + 1, want: baseLoginLockout},
+		{name: "account lockout doubles", counter: account, failures: accountFreeLoginAttempts + 2, want: 2 * baseLoginLockout},
+		{name: "account fifth lockout", counter: account, failures: accountFreeLoginAttempts + 5, want: 16 * baseLoginLockout},
+		{name: "account max lockout", counter: account, failures: accountFreeLoginAttempts + 6, want: maxAccountLockout},
+		{name: "account stays at max", counter: account, failures: 1000, want: maxAccountLockout},
+		{name: "ip last free attempt", counter: ip, failures: ipFreeLoginAttempts, want: 0},
+		{name: "ip first lockout", counter: ip, failures: ipFreeLoginAttempts + 1, want: baseLoginLockout},
+		{name: "ip goes over the account max", counter: ip, failures: ipFreeLoginAttempts + 7, want: 64 * baseLoginLockout},
+		{name: "ip max lockout", counter: ip, failures: ipFreeLoginAttempts + 8, want: maxIpLockout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.counter.loginLockout(tt.failures); got != tt.want {
+				t.Errorf("loginLockout(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
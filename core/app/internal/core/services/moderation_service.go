@@ -0,0 +1,678 @@
+package services
+
+import (
+	"core/config"
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	repositories "core/internal/ports"
+	"core/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	ReportActionNone = "none"
+	ReportActionBan  = "ban"
+
+	ReportsPageSize     = 20
+	maxReportDetailsLen = 500
+
+	reportRateLimit  = 5
+	reportRateWindow = 10 * time.Minute
+)
+
+var (
+	ErrorInvalidReportReason  = errors.New("invalid report reason")
+	ErrorCannotReportSelf     = errors.New("you can't report yourself")
+	ErrorReportedUserNotFound = errors.New("reported user not found in the room")
+	ErrorReporterNotInRoom    = errors.New("you can only report users of your room")
+	ErrorTooManyReports       = errors.New("too many reports, try again later")
+	ErrorReportNotFound       = errors.New("report not found")
+	ErrorReportClosed         = errors.New("report is already closed")
+	ErrorInvalidResolution    = errors.New("invalid report resolution")
+	ErrorCannotBanGuest       = errors.New("guests have no account to ban")
+	ErrorCannotBanSelf        = errors.New("you can't ban or kick yourself")
+	ErrorAccountBanned        = errors.New("account is banned")
+	ErrorCannotModerateStaff  = errors.New("moderators and admins can't be banned or kicked")
+	ErrorInvalidRole          = errors.New("invalid role")
+	ErrorCannotChangeOwnRole  = errors.New("you can't change your own role")
+	ErrorInvalidIP            = errors.New("invalid ip address")
+	ErrorIPBanNotFound        = errors.New("ip is not banned")
+	ErrorIPBanned             = errors.New("your ip address is banned")
+)
+
+// ReportReasons are the accepted reasons of a report
+var ReportReasons = []string{"harassment", "spam", "hate", "impersonation", "other"}
+
+type ReportResponse struct {
+	ID                uint                 `json:"id"`
+	ReporterID        uint                 `json:"reporterId"`
+	ReportedAccountID uint                 `json:"reportedAccountId,omitempty"` // * 0 for guests
+	ReportedUserID    string               `json:"reportedUserId"`
+	ReportedUsername  string               `json:"reportedUsername"`
+	RoomID            string               `json:"roomId"`
+	RoomName          string               `json:"roomName"`
+	Reason            string               `json:"reason"`
+	Details           string               `json:"details"`
+	Evidence          []types.HistoryEntry `json:"evidence"`
+	Status            string               `json:"status"`
+	ReviewerID        uint                 `json:"reviewerId,omitempty"`
+	Note              string               `json:"note,omitempty"`
+	CreatedAt         int64                `json:"createdAt"`            // timestamp
+	ResolvedAt        int64                `json:"resolvedAt,omitempty"` // timestamp
+}
+
+type ReportsPage struct {
+	Reports []ReportResponse `json:"reports"`
+	Total   int64            `json:"total"`
+	Page    int              `json:"page"`
+}
+
+type LiveRoomUser struct {
+	UserID    types.UserID `json:"userId"`
+	Username  string       `json:"username"`
+	AccountID uint         `json:"accountId,omitempty"` // * 0 for guests
+}
+
+type LiveRoom struct {
+	RoomId      types.RoomId   `json:"roomId"`
+	Name        string         `json:"name"`
+	OwnerID     uint           `json:"ownerId,omitempty"`
+	IsProtected bool           `json:"isProtected"`
+	CreatedAt   int64          `json:"createdAt,omitempty"` // timestamp
+	Users       []LiveRoomUser `json:"users"`
+}
+
+type IPBanResponse struct {
+	IP        string `json:"ip"`
+	Reason    string `json:"reason"`
+	BannedBy  uint   `json:"bannedBy"`
+	CreatedAt int64  `json:"createdAt"`           // timestamp
+	ExpiresAt int64  `json:"expiresAt,omitempty"` // timestamp, permanent when missing
+}
+
+// ReportResolution closes a report, Action is applied to the reported account
+type ReportResolution struct {
+	Status string
+	Action string
+	Note   string
+}
+
+// ModerationService handles the abuse reports, the roles, and the account and
+// IP bans
+type ModerationService struct {
+	reportRepo       *repositories.ReportRepoContext
+	userRepo         *repositories.UserRepoContext
+	refreshTokenRepo *repositories.RefreshTokenRepoContext
+	ipBanRepo        *repositories.IPBanRepoContext
+	logger           core.LoggerI
+}
+
+func NewModerationService(logger core.LoggerI, reportRepo *repositories.ReportRepoContext, userRepo *repositories.UserRepoContext, refreshTokenRepo *repositories.RefreshTokenRepoContext, ipBanRepo *repositories.IPBanRepoContext) *ModerationService {
+	return &ModerationService{
+		reportRepo:       reportRepo,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		ipBanRepo:        ipBanRepo,
+		logger:           logger,
+	}
+}
+
+// Report stores a report about a user of the room, the last messages of the
+// room the reporter could see are attached as evidence
+func (ctx *ModerationService) Report(reporterId uint, roomId types.RoomId, reqData types.ReportUser) (*ReportResponse, error) {
+	if !inSlice(ReportReasons, reqData.Reason) {
+		return nil, ErrorInvalidReportReason
+	}
+
+	allowed, err := memory_storage.AllowRate(fmt.Sprintf("report:%d", reporterId), reportRateLimit, reportRateWindow)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return nil, ErrorSaveFailed
+	}
+
+	if !allowed {
+		return nil, ErrorTooManyReports
+	}
+
+	history, err := memory_storage.GetRoomHistory(roomId, config.RoomHistoryLimit)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		history = []types.HistoryEntry{}
+	}
+
+	if !ctx.wasInRoom(reporterId, roomId, history) {
+		return nil, ErrorReporterNotInRoom
+	}
+
+	reported, err := findReported(roomId, reqData.UserID, history)
+	if err != nil {
+		return nil, err
+	}
+
+	if reported.AccountID != 0 && reported.AccountID == reporterId {
+		return nil, ErrorCannotReportSelf
+	}
+
+	evidence, err := json.Marshal(reportEvidence(reporterId, history))
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	roomName := ""
+	if room, exists := memory_storage.GetRoom(roomId); exists {
+		roomName = room.Name
+	}
+
+	details := reqData.Details
+	if len(details) > maxReportDetailsLen {
+		details = details[:maxReportDetailsLen]
+	}
+
+	report, err := ctx.reportRepo.Save(models.Report{
+		ReporterID:        reporterId,
+		ReportedAccountID: reported.AccountID,
+		ReportedUserID:    string(reported.UserID),
+		ReportedUsername:  reported.Username,
+		RoomID:            string(roomId),
+		RoomName:          roomName,
+		Reason:            reqData.Reason,
+		Details:           details,
+		Evidence:          string(evidence),
+		Status:            models.ReportOpen,
+	})
+
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("report %d: account %d reported %s (%s) in %s", report.ID, reporterId, reported.Username, report.Reason, roomId))
+
+	return newReportResponse(report), nil
+}
+
+// ListReports returns a page of the reports with the status, every report
+// when it's empty
+func (ctx *ModerationService) ListReports(status string, page int) (*ReportsPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	reports, total, err := ctx.reportRepo.List(status, ReportsPageSize, (page-1)*ReportsPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReportsPage{
+		Reports: make([]ReportResponse, 0, len(reports)),
+		Total:   total,
+		Page:    page,
+	}
+
+	for _, report := range reports {
+		response.Reports = append(response.Reports, *newReportResponse(&report))
+	}
+
+	return response, nil
+}
+
+func (ctx *ModerationService) GetReport(reportId uint) (*ReportResponse, error) {
+	report, err := ctx.reportRepo.GetById(reportId)
+	if err != nil {
+		return nil, ErrorReportNotFound
+	}
+
+	return newReportResponse(report), nil
+}
+
+// ReviewReport assigns an open report to the reviewer
+func (ctx *ModerationService) ReviewReport(reportId uint, reviewerId uint) (*ReportResponse, error) {
+	report, err := ctx.reportRepo.GetById(reportId)
+	if err != nil {
+		return nil, ErrorReportNotFound
+	}
+
+	if report.IsClosed() {
+		return nil, ErrorReportClosed
+	}
+
+	report.Status = models.ReportReviewing
+	report.ReviewerID = reviewerId
+
+	if err := ctx.reportRepo.Update(report); err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	return newReportResponse(report), nil
+}
+
+// ResolveReport closes the report, banning the reported account when asked
+func (ctx *ModerationService) ResolveReport(reportId uint, reviewerId uint, resolution ReportResolution) (*ReportResponse, error) {
+	if err := checkResolution(&resolution); err != nil {
+		return nil, err
+	}
+
+	report, err := ctx.reportRepo.GetById(reportId)
+	if err != nil {
+		return nil, ErrorReportNotFound
+	}
+
+	if report.IsClosed() {
+		return nil, ErrorReportClosed
+	}
+
+	if resolution.Action == ReportActionBan {
+		if report.ReportedAccountID == 0 {
+			return nil, ErrorCannotBanGuest
+		}
+
+		banReason := fmt.Sprintf("report %d: %s", report.ID, report.Reason)
+		if err := ctx.Ban(reviewerId, report.ReportedAccountID, banReason); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	report.Status = resolution.Status
+	report.ReviewerID = reviewerId
+	report.Note = resolution.Note
+	report.ResolvedAt = &now
+
+	if err := ctx.reportRepo.Update(report); err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("report %d %s by %d, action: %s", report.ID, report.Status, reviewerId, resolution.Action))
+
+	return newReportResponse(report), nil
+}
+
+// checkResolution validates the resolution of a report, no action means
+// ReportActionNone
+func checkResolution(resolution *ReportResolution) error {
+	if resolution.Status != models.ReportResolved && resolution.Status != models.ReportDismissed {
+		return ErrorInvalidResolution
+	}
+
+	if resolution.Action == "" {
+		resolution.Action = ReportActionNone
+	}
+
+	if resolution.Action != ReportActionNone && resolution.Action != ReportActionBan {
+		return ErrorInvalidResolution
+	}
+
+	return nil
+}
+
+// Ban bans the account on every node: its sessions are revoked and its live
+// connections closed
+func (ctx *ModerationService) Ban(adminId uint, accountId uint, reason string) error {
+	if err := ctx.checkModerable(adminId, accountId); err != nil {
+		return err
+	}
+
+	if err := ctx.userRepo.SetBanned(accountId, true, reason); err != nil {
+		return ErrorSaveFailed
+	}
+
+	ctx.endSessions(accountId, ErrorAccountBanned.Error())
+
+	ctx.logger.Warn(fmt.Sprintf("account %d banned by %d: %s", accountId, adminId, reason))
+
+	return nil
+}
+
+// Kick logs the account out of every session and closes its connections, it
+// can sign in again
+func (ctx *ModerationService) Kick(adminId uint, accountId uint) error {
+	if err := ctx.checkModerable(adminId, accountId); err != nil {
+		return err
+	}
+
+	ctx.endSessions(accountId, "kicked by a moderator")
+
+	ctx.logger.Info(fmt.Sprintf("account %d kicked by %d", accountId, adminId))
+
+	return nil
+}
+
+func (ctx *ModerationService) Unban(adminId uint, accountId uint) error {
+	if err := ctx.userRepo.SetBanned(accountId, false, ""); err != nil {
+		if errors.Is(err, repositories.ErrorUserIdNotFound) {
+			return ErrorUserNotFound
+		}
+
+		return ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("account %d unbanned by %d", accountId, adminId))
+
+	return nil
+}
+
+// SetRole changes the role of the account, admins can't change their own
+func (ctx *ModerationService) SetRole(adminId uint, accountId uint, role string) error {
+	if !models.IsValidRole(role) {
+		return ErrorInvalidRole
+	}
+
+	if adminId == accountId {
+		return ErrorCannotChangeOwnRole
+	}
+
+	if err := ctx.userRepo.SetRole(accountId, role); err != nil {
+		if errors.Is(err, repositories.ErrorUserIdNotFound) {
+			return ErrorUserNotFound
+		}
+
+		return ErrorSaveFailed
+	}
+
+	ctx.logger.Info(fmt.Sprintf("account %d is now %s, set by %d", accountId, role, adminId))
+
+	return nil
+}
+
+// BootstrapAdmins makes admins of the accounts listed in ADMIN_EMAILS, so that
+// a new deployment has someone to give the roles
+func (ctx *ModerationService) BootstrapAdmins() {
+	promoted, err := ctx.userRepo.SetRoleByEmails(config.AdminEmails, models.RoleAdmin)
+	if err != nil {
+		ctx.logger.Error(fmt.Sprintf("failed to promote the admins: %v", err))
+		return
+	}
+
+	if promoted > 0 {
+		ctx.logger.Info(fmt.Sprintf("promoted %d accounts to admin", promoted))
+	}
+}
+
+// ListRooms returns the live rooms with their occupants
+func (ctx *ModerationService) ListRooms() ([]LiveRoom, error) {
+	rooms, err := memory_storage.GetRooms()
+	if err != nil {
+		return nil, err
+	}
+
+	liveRooms := make([]LiveRoom, 0, len(rooms))
+	for roomId, room := range rooms {
+		liveRoom := LiveRoom{
+			RoomId:      roomId,
+			Name:        room.Name,
+			OwnerID:     room.OwnerID,
+			IsProtected: room.IsProtected,
+			CreatedAt:   room.CreatedAt,
+			Users:       make([]LiveRoomUser, 0, len(room.Users)),
+		}
+
+		for _, user := range room.Users {
+			liveRoom.Users = append(liveRoom.Users, LiveRoomUser{
+				UserID:    user.UserID,
+				Username:  user.UserName,
+				AccountID: user.AccountID,
+			})
+		}
+
+		liveRooms = append(liveRooms, liveRoom)
+	}
+
+	return liveRooms, nil
+}
+
+// CloseRoom removes every user from the room and deletes it
+func (ctx *ModerationService) CloseRoom(adminId uint, roomId types.RoomId, reason string) error {
+	if err := CloseRoom(roomId, reason); err != nil {
+		return err
+	}
+
+	ctx.logger.Info(fmt.Sprintf("room %s closed by %d: %s", roomId, adminId, reason))
+
+	return nil
+}
+
+// BanIP rejects the requests and connections from the IP, the ban is
+// permanent when duration is 0. The open connections from the IP are closed.
+func (ctx *ModerationService) BanIP(adminId uint, ip string, reason string, duration time.Duration) (*IPBanResponse, error) {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return nil, ErrorInvalidIP
+	}
+
+	ip = parsedIP.String()
+
+	ban := models.IPBan{
+		IP:       ip,
+		Reason:   reason,
+		BannedBy: adminId,
+	}
+
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	saved, err := ctx.ipBanRepo.Save(ban)
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	if err := memory_storage.BanIP(ip, reason, duration); err != nil {
+		ctx.logger.Error(err.Error())
+		return nil, ErrorSaveFailed
+	}
+
+	if err := memory_storage.DisconnectIP(ip, ErrorIPBanned.Error()); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	ctx.logger.Warn(fmt.Sprintf("ip %s banned by %d: %s", ip, adminId, reason))
+
+	return newIPBanResponse(saved), nil
+}
+
+func (ctx *ModerationService) UnbanIP(adminId uint, ip string) error {
+	if parsedIP := net.ParseIP(ip); parsedIP != nil {
+		ip = parsedIP.String()
+	}
+
+	if err := ctx.ipBanRepo.Delete(ip); err != nil {
+		if errors.Is(err, repositories.ErrorIPBanNotFound) {
+			return ErrorIPBanNotFound
+		}
+
+		return ErrorSaveFailed
+	}
+
+	if err := memory_storage.UnbanIP(ip); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	ctx.logger.Info(fmt.Sprintf("ip %s unbanned by %d", ip, adminId))
+
+	return nil
+}
+
+func (ctx *ModerationService) ListIPBans() ([]IPBanResponse, error) {
+	bans, err := ctx.ipBanRepo.ListActive()
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]IPBanResponse, 0, len(bans))
+	for _, ban := range bans {
+		response = append(response, *newIPBanResponse(&ban))
+	}
+
+	return response, nil
+}
+
+// SyncIPBans copies the active IP bans of the database to Redis, where the
+// requests are checked
+func (ctx *ModerationService) SyncIPBans() error {
+	bans, err := ctx.ipBanRepo.ListActive()
+	if err != nil {
+		return err
+	}
+
+	for _, ban := range bans {
+		ttl := time.Duration(0)
+		if ban.ExpiresAt != nil {
+			ttl = time.Until(*ban.ExpiresAt)
+		}
+
+		if err := memory_storage.BanIP(ban.IP, ban.Reason, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsIPBanned reports whether the IP is banned, it lets the IP through when
+// the bans can't be checked
+func (ctx *ModerationService) IsIPBanned(ip string) bool {
+	banned, err := memory_storage.IsIPBanned(ip)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return false
+	}
+
+	return banned
+}
+
+// checkModerable refuses to act on staff accounts and on the admin itself
+func (ctx *ModerationService) checkModerable(adminId uint, accountId uint) error {
+	if adminId == accountId {
+		return ErrorCannotBanSelf
+	}
+
+	user, err := ctx.userRepo.GetById(float64(accountId))
+	if err != nil {
+		return ErrorUserNotFound
+	}
+
+	if user.HasRole(models.RoleModerator) {
+		return ErrorCannotModerateStaff
+	}
+
+	return nil
+}
+
+// endSessions revokes the sessions of the account and closes its live
+// connections on every node
+func (ctx *ModerationService) endSessions(accountId uint, reason string) {
+	if err := ctx.refreshTokenRepo.RevokeAllByUserId(accountId); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	if err := memory_storage.DisconnectAccount(accountId, reason); err != nil {
+		ctx.logger.Error(err.Error())
+	}
+}
+
+// wasInRoom reports whether the account is in the room or wrote in it lately
+func (ctx *ModerationService) wasInRoom(accountId uint, roomId types.RoomId, history []types.HistoryEntry) bool {
+	clients, err := memory_storage.GetAccountClients(accountId)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+	}
+
+	for _, client := range clients {
+		if client.RoomId == roomId {
+			return true
+		}
+	}
+
+	for _, entry := range history {
+		if entry.AccountID == accountId || entry.ToAccountID == accountId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findReported looks the user up in the room, then in its history when it
+// already left
+func findReported(roomId types.RoomId, userId types.UserID, history []types.HistoryEntry) (*types.HistoryEntry, error) {
+	if client, err := memory_storage.GetClient(userId); err == nil && client.RoomId == roomId {
+		return &types.HistoryEntry{
+			UserID:    client.ID,
+			AccountID: client.AccountID,
+			Username:  client.Username,
+		}, nil
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].UserID == userId {
+			return &history[i], nil
+		}
+	}
+
+	return nil, ErrorReportedUserNotFound
+}
+
+// reportEvidence keeps the last messages of the history, the whispers the
+// reporter wasn't part of stay private
+func reportEvidence(reporterId uint, history []types.HistoryEntry) []types.HistoryEntry {
+	evidence := []types.HistoryEntry{}
+	for _, entry := range history {
+		if entry.IsWhisper() && entry.AccountID != reporterId && entry.ToAccountID != reporterId {
+			continue
+		}
+
+		evidence = append(evidence, entry)
+	}
+
+	return evidence[max(0, len(evidence)-config.ReportEvidenceMessages):]
+}
+
+func newIPBanResponse(ban *models.IPBan) *IPBanResponse {
+	response := &IPBanResponse{
+		IP:        ban.IP,
+		Reason:    ban.Reason,
+		BannedBy:  ban.BannedBy,
+		CreatedAt: ban.CreatedAt.Unix(),
+	}
+
+	if ban.ExpiresAt != nil {
+		response.ExpiresAt = ban.ExpiresAt.Unix()
+	}
+
+	return response
+}
+
+func newReportResponse(report *models.Report) *ReportResponse {
+	evidence := []types.HistoryEntry{}
+	if err := json.Unmarshal([]byte(report.Evidence), &evidence); err != nil {
+		evidence = []types.HistoryEntry{}
+	}
+
+	response := &ReportResponse{
+		ID:                report.ID,
+		ReporterID:        report.ReporterID,
+		ReportedAccountID: report.ReportedAccountID,
+		ReportedUserID:    report.ReportedUserID,
+		ReportedUsername:  report.ReportedUsername,
+		RoomID:            report.RoomID,
+		RoomName:          report.RoomName,
+		Reason:            report.Reason,
+		Details:           report.Details,
+		Evidence:          evidence,
+		Status:            report.Status,
+		ReviewerID:        report.ReviewerID,
+		Note:              report.Note,
+		CreatedAt:         report.CreatedAt.Unix(),
+	}
+
+	if report.ResolvedAt != nil {
+		response.ResolvedAt = report.ResolvedAt.Unix()
+	}
+
+	return response
+}
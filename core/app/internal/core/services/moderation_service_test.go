@@ -0,0 +1,134 @@
+package services
+
+import (
+	"core/config"
+	"core/internal/adapters/database/models"
+	"core/types"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckResolution(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution ReportResolution
+		wantErr    error
+		wantAction string
+	}{
+		{name: "resolved", resolution: ReportResolution{Status: models.ReportResolved}, wantAction: ReportActionNone},
+		{name: "dismissed", resolution: ReportResolution{Status: models.ReportDismissed, Action: ReportActionNone}, wantAction: ReportActionNone},
+		{name: "resolved with a ban", resolution: ReportResolution{Status: models.ReportResolved, Action: ReportActionBan}, wantAction: ReportActionBan},
+		{name: "reviewing isn't a resolution", resolution: ReportResolution{Status: models.ReportReviewing}, wantErr: ErrorInvalidResolution},
+		{name: "open isn't a resolution", resolution: ReportResolution{Status: models.ReportOpen}, wantErr: ErrorInvalidResolution},
+		{name: "no status", resolution: ReportResolution{}, wantErr: ErrorInvalidResolution},
+		{name: "unknown action", resolution: ReportResolution{Status: models.ReportResolved, Action: "delete"}, wantErr: ErrorInvalidResolution},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolution := tt.resolution
+			err := checkResolution(&resolution)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got err %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && resolution.Action != tt.wantAction {
+				t.Errorf("action %q, want %q", resolution.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestReportEvidence(t *testing.T) {
+	previous := config.ReportEvidenceMessages
+	config.ReportEvidenceMessages = 3
+	t.Cleanup(func() { config.ReportEvidenceMessages = previous })
+
+	const reporterId uint = 1
+
+	message := func(msg string) types.HistoryEntry {
+		return types.HistoryEntry{UserID: "other", AccountID: 2, Msg: msg}
+	}
+
+	whisper := func(msg string, from uint, to uint) types.HistoryEntry {
+		return types.HistoryEntry{UserID: "other", AccountID: from, Msg: msg, To: "someone", ToAccountID: to}
+	}
+
+	tests := []struct {
+		name    string
+		history []types.HistoryEntry
+		want    []string
+	}{
+		{name: "no history", history: nil, want: []string{}},
+		{name: "fewer messages than kept", history: []types.HistoryEntry{message("a"), message("b")}, want: []string{"a", "b"}},
+		{name: "last messages are kept", history: []types.HistoryEntry{message("a"), message("b"), message("c"), message("d")}, want: []string{"b", "c", "d"}},
+		{name: "whispers to the reporter", history: []types.HistoryEntry{whisper("a", 2, reporterId)}, want: []string{"a"}},
+		{name: "whispers of the reporter", history: []types.HistoryEntry{whisper("a", reporterId, 2)}, want: []string{"a"}},
+		{name: "whispers of others stay private", history: []types.HistoryEntry{message("a"), whisper("b", 2, 3), message("c")}, want: []string{"a", "c"}},
+		{name: "private whispers don't take the kept slots", history: []types.HistoryEntry{message("a"), message("b"), message("c"), whisper("d", 2, 3)}, want: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, entry := range reportEvidence(reporterId, tt.history) {
+				got = append(got, entry.Msg)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReportResponse(t *testing.T) {
+	resolvedAt := time.Now()
+	evidence := []types.HistoryEntry{{UserID: "other", Msg: "hi"}}
+
+	encoded, err := json.Marshal(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		report         models.Report
+		wantEvidence   []types.HistoryEntry
+		wantResolvedAt int64
+	}{
+		{name: "open report", report: models.Report{Status: models.ReportOpen, Evidence: string(encoded)}, wantEvidence: evidence},
+		{name: "resolved report", report: models.Report{Status: models.ReportResolved, Evidence: string(encoded), ResolvedAt: &resolvedAt}, wantEvidence: evidence, wantResolvedAt: resolvedAt.Unix()},
+		{name: "invalid evidence", report: models.Report{Evidence: "not json"}, wantEvidence: []types.HistoryEntry{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := newReportResponse(&tt.report)
+
+			if !reflect.DeepEqual(response.Evidence, tt.wantEvidence) {
+				t.Errorf("evidence %v, want %v", response.Evidence, tt.wantEvidence)
+			}
+
+			if response.ResolvedAt != tt.wantResolvedAt {
+				t.Errorf("resolvedAt %d, want %d", response.ResolvedAt, tt.wantResolvedAt)
+			}
+		})
+	}
+}
+
+func TestReportIsClosed(t *testing.T) {
+	for _, status := range []string{models.ReportOpen, models.ReportReviewing, models.ReportResolved, models.ReportDismissed} {
+		t.Run(status, func(t *testing.T) {
+			report := models.Report{Status: status}
+			want := status == models.ReportResolved || status == models.ReportDismissed
+
+			if got := report.IsClosed(); got != want {
+				t.Errorf("IsClosed = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/types"
+	"errors"
+	"strings"
+)
+
+const (
+	maxRoomDescriptionLen = 200
+	maxRoomTags           = 3
+)
+
+// RoomTags lists the tags rooms can be given
+var RoomTags = []string{"art", "chill", "gaming", "movies", "music", "random", "roleplay", "study", "tech"}
+
+var (
+	ErrorDescriptionTooLong = errors.New("room description is too long")
+	ErrorUnknownRoomTag     = errors.New("unknown room tag")
+	ErrorTooManyRoomTags    = errors.New("a room can have up to 3 tags")
+)
+
+// normalizeRoomTags lowercases the tags and removes the duplicates, every
+// tag must be in RoomTags
+func normalizeRoomTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !inSlice(RoomTags, tag) {
+			return nil, ErrorUnknownRoomTag
+		}
+
+		if !inSlice(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized, nil
+}
+
+// RoomDirectory returns the public rooms shown in the lobby, only the ones
+// with every tag when some are given
+func RoomDirectory(tags []string) ([]types.PopularRoomList, error) {
+	tags, err := normalizeRoomTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return memory_storage.GetPopularRooms(func(room *types.RoomData) bool {
+		if !room.IsListed() {
+			return false
+		}
+
+		for _, tag := range tags {
+			if !inSlice(room.Tags, tag) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// FindRoom returns the directory entry of the room referenced by its id or its
+// slug, the private rooms aren't shown
+func FindRoom(ref types.RoomId) (*types.PopularRoomList, error) {
+	roomId := ResolveRoomId(ref)
+
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists || room.Visibility == types.RoomPrivate {
+		return nil, ErrorRoomNotExists
+	}
+
+	listing, exists := memory_storage.GetRoomListing(roomId)
+	if !exists {
+		return nil, ErrorRoomNotExists
+	}
+
+	return listing, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	"core/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultInviteExpTime = 24 * time.Hour
+	MaxInviteExpTime     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrorInvalidInvite     = errors.New("invalid or expired invite")
+	ErrorInviteRequired    = errors.New("this room is private, you need an invite")
+	ErrorInviteForbidden   = errors.New("only the room owner can create invites")
+	ErrorInvalidInviteExp  = errors.New("invites expire within 7 days")
+	ErrorInvalidVisibility = errors.New("invalid room visibility")
+	ErrorPrivateRoomGuest  = errors.New("sign in to create a private room")
+)
+
+type RoomInvite struct {
+	RoomId    types.RoomId `json:"roomId"`
+	Token     string       `json:"token"`
+	ExpiresAt int64        `json:"expiresAt"` // timestamp
+}
+
+// CreateInvite signs an invite to the room that expires after expiresIn, the
+// default expiration when it's 0. Only the owner and the staff can invite.
+func CreateInvite(roomId types.RoomId, inviter *types.Client, expiresIn time.Duration) (*RoomInvite, error) {
+	if expiresIn == 0 {
+		expiresIn = DefaultInviteExpTime
+	}
+
+	if expiresIn < 0 || expiresIn > MaxInviteExpTime {
+		return nil, ErrorInvalidInviteExp
+	}
+
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return nil, ErrorRoomNotExists
+	}
+
+	if RoomRoleOf(room, inviter) < RoomOwner {
+		return nil, ErrorInviteForbidden
+	}
+
+	expiresAt := time.Now().Add(expiresIn)
+
+	token, err := core.GenerateInviteToken(string(roomId), inviter.AccountID, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign invite: %w", err)
+	}
+
+	return &RoomInvite{
+		RoomId:    roomId,
+		Token:     token,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// checkRoomAccess lets the client in with an invite or with the password, an
+// invite takes the place of the password
+func checkRoomAccess(roomId types.RoomId, room *types.RoomData, client *types.Client, reqData types.JoinRoom) error {
+	if reqData.Invite != "" {
+		inviteRoomId, err := core.DecodeInviteToken(reqData.Invite)
+		if err != nil || types.RoomId(inviteRoomId) != roomId {
+			return ErrorInvalidInvite
+		}
+
+		return nil
+	}
+
+	if room.Visibility == types.RoomPrivate && RoomRoleOf(room, client) < RoomOwner {
+		return ErrorInviteRequired
+	}
+
+	if room.IsProtected && (reqData.Password == nil || *room.Password != *reqData.Password) {
+		return ErrorInvalidPassword
+	}
+
+	return nil
+}
+
+// roomVisibility validates the visibility of a new room, guests can't create
+// private rooms since they can't invite
+func roomVisibility(visibility string, session *types.Session) (string, error) {
+	switch visibility {
+	case "", types.RoomPublic:
+		return types.RoomPublic, nil
+	case types.RoomUnlisted:
+		return visibility, nil
+	case types.RoomPrivate:
+		if session.IsGuest() {
+			return "", ErrorPrivateRoomGuest
+		}
+
+		return visibility, nil
+	default:
+		return "", ErrorInvalidVisibility
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/types"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	QueuePositionEvent = "queuePosition"
+	QueueReadyEvent    = "queueReady"
+
+	maxRoomQueue      = 50
+	queueAcceptWindow = 20 * time.Second
+	queueSweepTick    = 5 * time.Second // * how often the expired reservations are looked for
+)
+
+var (
+	ErrorQueueFull = errors.New("too many users are waiting for this room")
+)
+
+// JoinQueue waits for a slot of the full room, the room is joined right away
+// when it has one. The owner and its friends go first when the room allows it.
+func JoinQueue(reqData types.JoinRoom, messageClient *types.MessageClient, userId types.UserID, ownerFriend bool) error {
+	user, err := memory_storage.GetClient(userId)
+	if err != nil {
+		return err
+	}
+
+	reqData.RoomId = ResolveRoomId(reqData.RoomId)
+
+	room, exists := memory_storage.GetRoom(reqData.RoomId)
+	if !exists {
+		return ErrorRoomNotExists
+	}
+
+	if err := checkRoomAccess(reqData.RoomId, room, user, reqData); err != nil {
+		return err
+	}
+
+	if !IsRoomFull(*room) {
+		return JoinRoom(reqData, messageClient, userId)
+	}
+
+	if len(user.QueueRoomId) > 0 {
+		LeaveQueue(userId)
+	}
+
+	queue, err := memory_storage.GetRoomQueue(reqData.RoomId)
+	if err != nil {
+		return err
+	}
+
+	if len(queue) >= maxRoomQueue {
+		return ErrorQueueFull
+	}
+
+	if err := memory_storage.EnqueueRoom(reqData.RoomId, userId, queuePriority(room, user, ownerFriend)); err != nil {
+		return err
+	}
+
+	queueRoomId := string(reqData.RoomId)
+	if err := memory_storage.UpdateUser(userId, &types.UpdateUser{QueueRoomId: &queueRoomId}); err != nil {
+		fmt.Printf("failed to update client queue: %v\n", err)
+	}
+
+	notifyQueuePositions(reqData.RoomId)
+
+	// * a slot may have been freed since the room was found full
+	admitNext(reqData.RoomId)
+
+	return nil
+}
+
+// LeaveQueue removes the user from the queue it waits in, if any
+func LeaveQueue(userId types.UserID) {
+	user, err := memory_storage.GetClient(userId)
+	if err != nil || len(user.QueueRoomId) == 0 {
+		return
+	}
+
+	if err := memory_storage.RemoveFromRoomQueue(user.QueueRoomId, userId); err != nil {
+		fmt.Printf("failed to leave queue: %v\n", err)
+		return
+	}
+
+	emptyRoomId := ""
+	if err := memory_storage.UpdateUser(userId, &types.UpdateUser{QueueRoomId: &emptyRoomId}); err != nil {
+		fmt.Printf("failed to update client queue: %v\n", err)
+	}
+
+	notifyQueuePositions(user.QueueRoomId)
+}
+
+// queuePriority reports whether the user skips the queue of the room
+func queuePriority(room *types.RoomData, user *types.Client, ownerFriend bool) bool {
+	return room.QueueSkip && (ownerFriend || RoomRoleOf(room, user) >= RoomOwner)
+}
+
+// hasReservation reports whether a slot of the room is held for the user
+func hasReservation(room *types.RoomData, userId types.UserID) bool {
+	until, exists := room.Reservations[userId]
+	return exists && until > time.Now().Unix()
+}
+
+func activeReservations(room *types.RoomData) int {
+	active := 0
+	for userId := range room.Reservations {
+		if hasReservation(room, userId) {
+			active++
+		}
+	}
+
+	return active
+}
+
+// admitNext holds the free slots of the room for the first users of its
+// queue, they have queueAcceptWindow to join before the slot goes to the next
+// user
+func admitNext(roomId types.RoomId) {
+	admitted := false
+
+	err := withRoomLock(roomId, func() error {
+		room, exists := memory_storage.GetRoom(roomId)
+		if !exists {
+			return nil
+		}
+
+		for !IsRoomFull(*room) {
+			userId, err := memory_storage.DequeueRoom(roomId)
+			if err != nil {
+				return err
+			}
+
+			if userId == "" {
+				break
+			}
+
+			emptyRoomId := ""
+			if err := memory_storage.UpdateUser(userId, &types.UpdateUser{QueueRoomId: &emptyRoomId}); err != nil {
+				// * the user is gone
+				continue
+			}
+
+			if room.Reservations == nil {
+				room.Reservations = make(map[types.UserID]int64)
+			}
+
+			expiresAt := time.Now().Add(queueAcceptWindow)
+			room.Reservations[userId] = expiresAt.Unix()
+			admitted = true
+
+			// * any node gives the slot to the next user once it expires
+			if err := memory_storage.ScheduleReservation(roomId, userId, expiresAt); err != nil {
+				fmt.Printf("%v\n", err)
+			}
+
+			err = memory_storage.DeliverUser(userId, QueueReadyEvent, types.QueueReady{
+				RoomId:    roomId,
+				ExpiresIn: int(queueAcceptWindow.Seconds()),
+			})
+
+			if err != nil {
+				fmt.Printf("failed to tell %s its slot is ready: %v\n", userId, err)
+			}
+		}
+
+		if admitted {
+			memory_storage.UpdateRoom(roomId, room)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		fmt.Printf("failed to admit from queue: %v\n", err)
+	}
+
+	if admitted {
+		notifyQueuePositions(roomId)
+	}
+}
+
+// expireReservation gives the slot of a user that didn't join in time to the
+// next one
+func expireReservation(roomId types.RoomId, userId types.UserID) {
+	expired := false
+
+	err := withRoomLock(roomId, func() error {
+		room, exists := memory_storage.GetRoom(roomId)
+		if !exists {
+			return nil
+		}
+
+		if _, reserved := room.Reservations[userId]; !reserved {
+			return nil
+		}
+
+		delete(room.Reservations, userId)
+		memory_storage.UpdateRoom(roomId, room)
+		expired = true
+
+		return nil
+	})
+
+	if err != nil {
+		fmt.Printf("failed to expire reservation: %v\n", err)
+		return
+	}
+
+	if expired {
+		admitNext(roomId)
+	}
+}
+
+// StartQueueSweeper expires the reservations that weren't used in time, it
+// blocks. Every node runs it, each reservation is expired by one of them.
+func StartQueueSweeper() {
+	ticker := time.NewTicker(queueSweepTick)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		reservations, err := memory_storage.ClaimExpiredReservations(now)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+
+		for _, reservation := range reservations {
+			expireReservation(reservation.RoomId, reservation.UserId)
+		}
+	}
+}
+
+// notifyQueuePositions tells every user of the queue its position
+func notifyQueuePositions(roomId types.RoomId) {
+	queue, err := memory_storage.GetRoomQueue(roomId)
+	if err != nil {
+		fmt.Printf("failed to get room queue: %v\n", err)
+		return
+	}
+
+	for idx, userId := range queue {
+		err := memory_storage.DeliverUser(userId, QueuePositionEvent, types.QueuePosition{
+			RoomId:   roomId,
+			Position: idx + 1,
+		})
+
+		if err != nil {
+			fmt.Printf("failed to send queue position to %s: %v\n", userId, err)
+		}
+	}
+}
+
+// dropQueue tells the users waiting for a room that is gone and deletes its
+// queue
+func dropQueue(roomId types.RoomId, reason string) {
+	queue, err := memory_storage.GetRoomQueue(roomId)
+	if err != nil {
+		fmt.Printf("failed to get room queue: %v\n", err)
+		return
+	}
+
+	emptyRoomId := ""
+	for _, userId := range queue {
+		if err := memory_storage.UpdateUser(userId, &types.UpdateUser{QueueRoomId: &emptyRoomId}); err != nil {
+			continue
+		}
+
+		err := memory_storage.DeliverUser(userId, "roomClosed", types.RoomClosed{
+			RoomId: roomId,
+			Reason: reason,
+		})
+
+		if err != nil {
+			fmt.Printf("failed to tell %s the room closed: %v\n", userId, err)
+		}
+	}
+
+	if err := memory_storage.DeleteRoomQueue(roomId); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
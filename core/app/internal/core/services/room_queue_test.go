@@ -0,0 +1,107 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testRoom(users int, reservations map[types.UserID]int64) types.RoomData {
+	room := types.RoomData{Reservations: reservations}
+	for idx := 0; idx < users; idx++ {
+		room.Users = append(room.Users, types.User{UserID: types.UserID(fmt.Sprint(idx))})
+	}
+
+	return room
+}
+
+func TestHasReservation(t *testing.T) {
+	now := time.Now()
+	room := testRoom(0, map[types.UserID]int64{
+		"live":    now.Add(queueAcceptWindow).Unix(),
+		"expired": now.Add(-time.Second).Unix(),
+	})
+
+	tests := []struct {
+		name   string
+		userId types.UserID
+		want   bool
+	}{
+		{name: "live reservation", userId: "live", want: true},
+		{name: "expired reservation", userId: "expired", want: false},
+		{name: "no reservation", userId: "other", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasReservation(&room, tt.userId); got != tt.want {
+				t.Errorf("hasReservation(%q) = %v, want %v", tt.userId, got, tt.want)
+			}
+		})
+	}
+
+	if active := activeReservations(&room); active != 1 {
+		t.Errorf("activeReservations = %d, want 1", active)
+	}
+}
+
+func TestIsRoomFull(t *testing.T) {
+	live := time.Now().Add(queueAcceptWindow).Unix()
+	expired := time.Now().Add(-time.Second).Unix()
+
+	tests := []struct {
+		name         string
+		users        int
+		reservations map[types.UserID]int64
+		want         bool
+	}{
+		{name: "empty", users: 0, want: false},
+		{name: "one slot left", users: RoomLimit - 1, want: false},
+		{name: "full", users: RoomLimit, want: true},
+		{name: "last slot reserved", users: RoomLimit - 1, reservations: map[types.UserID]int64{"a": live}, want: true},
+		{name: "last slot reservation expired", users: RoomLimit - 1, reservations: map[types.UserID]int64{"a": expired}, want: false},
+		{name: "slots left after the reservations", users: RoomLimit - 3, reservations: map[types.UserID]int64{"a": live, "b": live}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRoomFull(testRoom(tt.users, tt.reservations)); got != tt.want {
+				t.Errorf("IsRoomFull = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	owner := &types.Client{AccountID: 1}
+	member := &types.Client{AccountID: 2}
+	moderator := &types.Client{AccountID: 3, Role: models.RoleModerator}
+	guest := &types.Client{}
+
+	tests := []struct {
+		name        string
+		queueSkip   bool
+		user        *types.Client
+		ownerFriend bool
+		want        bool
+	}{
+		{name: "owner", queueSkip: true, user: owner, want: true},
+		{name: "friend of the owner", queueSkip: true, user: member, ownerFriend: true, want: true},
+		{name: "staff", queueSkip: true, user: moderator, want: true},
+		{name: "member", queueSkip: true, user: member, want: false},
+		{name: "guest", queueSkip: true, user: guest, want: false},
+		{name: "owner without queue skip", user: owner, want: false},
+		{name: "friend without queue skip", user: member, ownerFriend: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := &types.RoomData{OwnerID: owner.AccountID, QueueSkip: tt.queueSkip}
+			if got := queuePriority(room, tt.user, tt.ownerFriend); got != tt.want {
+				t.Errorf("queuePriority = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
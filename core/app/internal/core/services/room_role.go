@@ -0,0 +1,39 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
+	"core/types"
+)
+
+// RoomRole is what a user can do in a room, a role has the permissions of the
+// lower ones
+type RoomRole int
+
+const (
+	RoomMember RoomRole = iota
+	RoomOwner           // * the account that created the room
+	RoomStaff           // * moderators and admins of the site, and the bots
+)
+
+// RoomRoleOf returns the role of the client in the room
+func RoomRoleOf(room *types.RoomData, client *types.Client) RoomRole {
+	switch {
+	case client.IsBot, models.RoleAtLeast(client.Role, models.RoleModerator):
+		return RoomStaff
+	case client.AccountID != 0 && client.AccountID == room.OwnerID:
+		return RoomOwner
+	default:
+		return RoomMember
+	}
+}
+
+// UserRoomRole returns the role of the client in the room
+func UserRoomRole(roomId types.RoomId, client *types.Client) (RoomRole, error) {
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return RoomMember, ErrorRoomNotExists
+	}
+
+	return RoomRoleOf(room, client), nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"core/internal/adapters/database/models"
+	"core/types"
+	"testing"
+)
+
+func TestRoomRoleOf(t *testing.T) {
+	room := &types.RoomData{OwnerID: 1}
+
+	tests := []struct {
+		name   string
+		room   *types.RoomData
+		client *types.Client
+		want   RoomRole
+	}{
+		{name: "owner", room: room, client: &types.Client{AccountID: 1, Role: models.RoleUser}, want: RoomOwner},
+		{name: "member", room: room, client: &types.Client{AccountID: 2, Role: models.RoleUser}, want: RoomMember},
+		{name: "guest", room: room, client: &types.Client{}, want: RoomMember},
+		{name: "guest of a room without owner", room: &types.RoomData{}, client: &types.Client{}, want: RoomMember},
+		{name: "moderator", room: room, client: &types.Client{AccountID: 3, Role: models.RoleModerator}, want: RoomStaff},
+		{name: "admin owning the room", room: room, client: &types.Client{AccountID: 1, Role: models.RoleAdmin}, want: RoomStaff},
+		{name: "bot", room: room, client: &types.Client{IsBot: true}, want: RoomStaff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoomRoleOf(tt.room, tt.client); got != tt.want {
+				t.Errorf("RoomRoleOf = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,25 +3,47 @@ package services
 import (
 	"core/internal/adapters/memory_storage"
 	"core/internal/core"
+	"core/internal/core/wire"
 	util "core/internal/utils"
 	types "core/types"
-	"encoding/json"
 	"errors"
 	"fmt"
 	mathRand "math/rand"
-	"sync"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 const (
 	GridSize  = 10
 	RoomLimit = 10
+
+	maxLenMsg = 60
+
+	maxRoomIdAttempts = 5
+
+	emptyRoomReason = "everyone left the room"
+
+	roomLockFormat = "room:%s"
+	roomLockTTL    = 5 * time.Second
+	roomLockWait   = 3 * time.Second
 )
 
 var (
-	ErrorRoomIsFull      = errors.New("room is full")
-	ErrorInvalidPassword = errors.New("invalid password")
-	ErrorRoomNotExists   = errors.New("room does not exist")
+	ErrorRoomIsFull         = errors.New("room is full")
+	ErrorInvalidPassword    = errors.New("invalid password")
+	ErrorRoomNotExists      = errors.New("room does not exist")
+	ErrorRoomIdUnavailable  = errors.New("couldn't create the room, try again")
+	ErrorWhisperSelf        = errors.New("you can't whisper to yourself")
+	ErrorRecipientNotInRoom = errors.New("user is not in your room")
+	ErrorCannotMuteSelf     = errors.New("you can't mute yourself")
+	ErrorCannotCloseWelcome = errors.New("the welcome room can't be closed")
+	ErrorPersistentRoom     = errors.New("only the accounts with a verified email can create persistent rooms")
+
+	// * returned by the updates of updateRoom, they are not sent to the users
+	errRoomUnchanged   = errors.New("room unchanged")
+	errMoveInterrupted = errors.New("move interrupted")
 )
 
 type JoinRoomResponse struct {
@@ -48,65 +70,189 @@ func inSlice(target []string, value string) bool {
 	return false
 }
 
-func newRoomId(roomName string) (*types.RoomId, error) {
+func newRoomId() types.RoomId {
+	return types.RoomId(uuid.NewString())
+}
+
+func newRoomSlug(roomName string) (string, error) {
 	randomId, err := util.GetRandomId()
 	if err != nil {
-		return nil, fmt.Errorf("error generating random id: %v", err)
+		return "", fmt.Errorf("error generating random id: %v", err)
 	}
 
-	id := fmt.Sprintf(types.RoomIdFormat, roomName, randomId)
-	roomId := types.RoomId(id)
-
-	return &roomId, nil
+	return fmt.Sprintf("%s-%s", util.Slugify(roomName), randomId), nil
 }
 
-// Check if the room is full
-func IsRoomFull(room types.RoomData) bool {
-	return len(room.Users) >= RoomLimit
+// createRoom saves the room under a new id and slug, both are retried when
+// they're taken
+func createRoom(roomData *types.RoomData) (types.RoomId, error) {
+	for attempt := 0; attempt < maxRoomIdAttempts; attempt++ {
+		roomId := newRoomId()
+
+		for idx := range roomData.Users {
+			roomData.Users[idx].RoomID = string(roomId)
+		}
+
+		slug, err := newRoomSlug(roomData.Name)
+		if err != nil {
+			return "", err
+		}
+
+		reserved, err := memory_storage.ReserveRoomSlug(slug, roomId)
+		if err != nil {
+			return "", err
+		}
+
+		if !reserved {
+			continue
+		}
+
+		roomData.Slug = slug
+
+		created, err := memory_storage.CreateRoom(roomId, *roomData)
+		if err == nil && created {
+			return roomId, nil
+		}
+
+		if err := memory_storage.DeleteRoomSlug(slug); err != nil {
+			fmt.Printf("couldn't release room slug: %v\n", err)
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", ErrorRoomIdUnavailable
 }
 
-func RemoveUser(userId types.UserID, roomId types.RoomId) {
-	room, exists := memory_storage.GetRoom(roomId)
-	if !exists {
-		return
+// ResolveRoomId returns the id of the room referenced by its id or its slug,
+// the old "name#N" ids are ids too
+func ResolveRoomId(ref types.RoomId) types.RoomId {
+	if _, exists := memory_storage.GetRoom(ref); exists {
+		return ref
 	}
 
-	userIdx, exists := room.UserIdxMap[userId]
-	if !exists {
-		fmt.Printf("User not found\n")
-		return
+	if roomId, exists := memory_storage.GetRoomIdBySlug(string(ref)); exists {
+		return roomId
 	}
 
-	// Remove position from UsersPositions
-	pos := room.Users[userIdx].Position
-	room.UsersPositions = deleteFromSlice(room.UsersPositions, fmt.Sprintf("%d,%d", pos.Row, pos.Col))
+	return ref
+}
 
-	// Replace the user with the last user for O(1) operation
-	lastIdx := len(room.Users) - 1
-	if lastIdx != int(userIdx) { // Only update if we're not removing the last user
-		room.Users[userIdx] = room.Users[lastIdx]
-		room.UserIdxMap[room.Users[userIdx].UserID] = userIdx
+// withRoomLock runs fn holding the lock of the room, every write of the room
+// goes through it so that the nodes don't overwrite each other
+func withRoomLock(roomId types.RoomId, fn func() error) error {
+	lock, err := memory_storage.AcquireLock(fmt.Sprintf(roomLockFormat, roomId), roomLockTTL, roomLockWait)
+	if err != nil {
+		return err
 	}
 
-	room.Users = room.Users[:lastIdx] // Remove last user
+	defer func() {
+		if err := lock.Release(); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}()
+
+	return fn()
+}
+
+// updateRoom reads the room holding its lock and writes it back once update
+// changed it, the room is returned as written. Nothing is written when update
+// fails, errRoomUnchanged included.
+func updateRoom(roomId types.RoomId, update func(room *types.RoomData) error) (*types.RoomData, error) {
+	var room *types.RoomData
+	err := withRoomLock(roomId, func() error {
+		var exists bool
+		room, exists = memory_storage.GetRoom(roomId)
+		if !exists {
+			return ErrorRoomNotExists
+		}
+
+		if err := update(room); err != nil {
+			return err
+		}
+
+		memory_storage.UpdateRoom(roomId, room)
+		return nil
+	})
+
+	return room, err
+}
+
+// Check if the room is full, the slots held for the queue count as taken
+func IsRoomFull(room types.RoomData) bool {
+	return len(room.Users)+activeReservations(&room) >= RoomLimit
+}
+
+func RemoveUser(userId types.UserID, roomId types.RoomId) {
+	// * stop receiving the room events on this node
+	memory_storage.UnsubscribeRoom(userId, roomId)
+
+	var room *types.RoomData
+	deleted := false
+	err := withRoomLock(roomId, func() error {
+		var exists bool
+		room, exists = memory_storage.GetRoom(roomId)
+		if !exists {
+			return ErrorRoomNotExists
+		}
+
+		userIdx, exists := room.UserIdxMap[userId]
+		if !exists {
+			fmt.Printf("User not found\n")
+			return ErrorUserNotInRoom
+		}
+
+		// Remove position from UsersPositions
+		pos := room.Users[userIdx].Position
+		room.UsersPositions = deleteFromSlice(room.UsersPositions, fmt.Sprintf("%d,%d", pos.Row, pos.Col))
+
+		// Replace the user with the last user for O(1) operation
+		lastIdx := len(room.Users) - 1
+		if lastIdx != int(userIdx) { // Only update if we're not removing the last user
+			room.Users[userIdx] = room.Users[lastIdx]
+			room.UserIdxMap[room.Users[userIdx].UserID] = userIdx
+		}
+
+		room.Users = room.Users[:lastIdx] // Remove last user
 
-	// Remove the user from the index map
-	delete(room.UserIdxMap, userId)
+		// Remove the user from the index map
+		delete(room.UserIdxMap, userId)
 
-	fmt.Printf("Users in the room: %s total: %d\n", roomId, len(room.Users))
+		fmt.Printf("Users in the room: %s total: %d\n", roomId, len(room.Users))
 
-	// Check if the room is empty
-	if len(room.Users) == 0 {
-		memory_storage.DeleteRoom(roomId)
+		// * an empty room is gone, the welcome and the persistent rooms excepted
+		if len(room.Users) == 0 && roomId != memory_storage.WelcomeRoomId() && !room.Persistent {
+			deleted = true
+			return deleteRoom(roomId, room, emptyRoomReason)
+		}
+
+		memory_storage.UpdateRoom(roomId, room)
+		return nil
+	})
+
+	if err != nil {
+		if !errors.Is(err, ErrorRoomNotExists) && !errors.Is(err, ErrorUserNotInRoom) {
+			fmt.Printf("failed to remove user from room: %v\n", err)
+		}
+
+		return
+	}
+
+	if deleted {
+		return
 	}
 
-	memory_storage.UpdateRoom(roomId, room)
 	updateSceneData := types.UpdateScene{
 		RoomId: string(roomId),
 		Users:  room.Users,
 	}
 
 	memory_storage.BroadcastRoom(roomId, "updateScene", updateSceneData)
+
+	// * the freed slot goes to the queue
+	admitNext(roomId)
 }
 
 type NewRoomResponse struct {
@@ -115,30 +261,34 @@ type NewRoomResponse struct {
 }
 
 func UpdateUserTyping(roomId types.RoomId, userId types.UserID, isTyping bool) {
-	room, exists := memory_storage.GetRoom(roomId)
-
-	if !exists {
-		fmt.Printf("room not found")
-		return
-	}
+	room, err := updateRoom(roomId, func(room *types.RoomData) error {
+		userIdx, exists := room.UserIdxMap[userId]
+		if !exists {
+			return ErrorUserNotInRoom
+		}
 
-	userIdx, exists := room.UserIdxMap[userId]
-	if !exists {
-		fmt.Printf("user not found")
-		return
-	}
+		if room.Users[userIdx].IsTyping == isTyping {
+			return errRoomUnchanged
+		}
 
-	if room.Users[userIdx].IsTyping != isTyping {
 		room.Users[userIdx].IsTyping = isTyping
-		memory_storage.UpdateRoom(roomId, room)
+		return nil
+	})
 
-		updateSceneData := types.UpdateScene{
-			RoomId: string(roomId),
-			Users:  room.Users,
+	if err != nil {
+		if !errors.Is(err, errRoomUnchanged) {
+			fmt.Printf("failed to update typing: %v\n", err)
 		}
 
-		memory_storage.BroadcastRoom(roomId, "updateScene", updateSceneData)
+		return
+	}
+
+	updateSceneData := types.UpdateScene{
+		RoomId: string(roomId),
+		Users:  room.Users,
 	}
+
+	memory_storage.BroadcastRoom(roomId, "updateScene", updateSceneData)
 }
 
 // ! movements are not perfect
@@ -225,17 +375,44 @@ func UpdateUserPosition(roomId types.RoomId, userId types.UserID, dest string) {
 	const speedUserMov int = 180
 
 	for _, newPosition := range path[1:] {
-		room.UsersPositions = deleteFromSlice(room.UsersPositions, posKey)
-
-		room.Users[userIdx].Position = newPosition
-		room.Users[userIdx].Direction = facingDirection
 		newPosKey := fmt.Sprintf("%d,%d", newPosition.Row, newPosition.Col)
 
-		room.UsersPositions = append(room.UsersPositions, newPosKey)
-		memory_storage.UpdateRoom(roomId, room)
+		// * each step is written on the room as it is now, the path stops when
+		// * the user left, moved elsewhere or the tile was taken meanwhile
+		var user types.User
+		_, err := updateRoom(roomId, func(room *types.RoomData) error {
+			userIdx, exists := room.UserIdxMap[userId]
+			if !exists {
+				return ErrorUserNotInRoom
+			}
+
+			if room.Users[userIdx].Position != currentPos || inSlice(room.UsersPositions, newPosKey) {
+				return errMoveInterrupted
+			}
+
+			room.UsersPositions = deleteFromSlice(room.UsersPositions, posKey)
+			room.UsersPositions = append(room.UsersPositions, newPosKey)
+
+			// * moving cancels the active emote
+			room.Users[userIdx].Emote = ""
+			room.Users[userIdx].EmoteUntil = 0
+			room.Users[userIdx].Position = newPosition
+			room.Users[userIdx].Direction = facingDirection
+
+			user = room.Users[userIdx]
+			return nil
+		})
+
+		if err != nil {
+			if !errors.Is(err, errMoveInterrupted) && !errors.Is(err, ErrorUserNotInRoom) {
+				fmt.Printf("failed to move user: %v\n", err)
+			}
+
+			return
+		}
 
 		updateSceneData := types.UpdateUserPosition{
-			User: room.Users[userIdx],
+			User: user,
 		}
 
 		memory_storage.BroadcastRoom(roomId, "updateUser", updateSceneData)
@@ -243,10 +420,9 @@ func UpdateUserPosition(roomId types.RoomId, userId types.UserID, dest string) {
 		// Simulate movement delay
 		time.Sleep(time.Duration(speedUserMov) * time.Millisecond)
 
+		currentPos = newPosition
 		posKey = newPosKey
 	}
-
-	fmt.Printf("Invalid positions: %v\n", invalidPositions)
 }
 
 // Get a random position in the room
@@ -267,51 +443,103 @@ func getRandomEmptyPosition(occupiedPositions []string, max int) (string, types.
 	}
 }
 
-func JoinRoom(reqData types.JoinRoom, messageClient *types.MessageClient, userId types.UserID) error {
-	// ! TODO: remove a user from a room if connected
-	user, _ := memory_storage.GetClient(types.UserID(userId))
-	if len(user.RoomId) > 0 {
-		RemoveUser(user.ID, user.RoomId)
-	}
+// addRoomUser checks that the user can join the room and adds it at a random
+// free position
+func addRoomUser(reqData types.JoinRoom, user *types.Client) (*types.RoomData, error) {
+	userId := user.ID
 
-	// Check if the room already exists
-	roomData, exists := memory_storage.GetRoom(reqData.RoomId)
-	if !exists {
-		return ErrorRoomNotExists
-	}
+	var roomData *types.RoomData
+	err := withRoomLock(reqData.RoomId, func() error {
+		// Check if the room already exists
+		var exists bool
+		roomData, exists = memory_storage.GetRoom(reqData.RoomId)
+		if !exists {
+			return ErrorRoomNotExists
+		}
+
+		// * the users admitted from the queue already went through the checks
+		if hasReservation(roomData, userId) {
+			delete(roomData.Reservations, userId)
+
+			if err := memory_storage.DeleteReservation(reqData.RoomId, userId); err != nil {
+				fmt.Printf("%v\n", err)
+			}
+		} else {
+			if IsRoomFull(*roomData) {
+				return ErrorRoomIsFull
+			}
+
+			if err := checkRoomAccess(reqData.RoomId, roomData, user, reqData); err != nil {
+				return err
+			}
+		}
+
+		// Create new user
+		newUser := types.User{
+			UserName:  reqData.UserName,
+			UserID:    userId,
+			RoomID:    string(reqData.RoomId),
+			Direction: types.DefaultDirection,
+			IsTyping:  false,
+			Avatar:    user.Avatar,
+			AccountID: user.AccountID,
+			IsBot:     user.IsBot,
+		}
+
+		fmt.Printf("Updating room: %s\n", reqData.RoomId)
+		newPositionStr, newPosition := getRandomEmptyPosition(roomData.UsersPositions, 9)
+		newUser.Position = newPosition
+
+		roomData.Users = append(roomData.Users, newUser)
+		roomData.UsersPositions = append(roomData.UsersPositions, newPositionStr)
+		roomData.UserIdxMap[userId] = types.UserIdx(len(roomData.Users) - 1)
+
+		memory_storage.UpdateRoom(reqData.RoomId, roomData)
+		return nil
+	})
+
+	return roomData, err
+}
 
-	if IsRoomFull(*roomData) {
-		return ErrorRoomIsFull
+// leaveRoomId forgets the room of the user once it was removed from it, the
+// messages and the presence follow the room id of the client
+func leaveRoomId(userId types.UserID) {
+	emptyRoomId := ""
+	if err := memory_storage.UpdateUser(userId, &types.UpdateUser{RoomId: &emptyRoomId}); err != nil {
+		fmt.Printf("couldn't update user's room id: %v\n", err)
 	}
+}
 
-	if roomData.IsProtected && *roomData.Password != *reqData.Password {
-		return ErrorInvalidPassword
+func JoinRoom(reqData types.JoinRoom, messageClient *types.MessageClient, userId types.UserID) error {
+	// ! TODO: remove a user from a room if connected
+	user, err := memory_storage.GetClient(types.UserID(userId))
+	if err != nil {
+		return err
 	}
 
-	// Set initial position
-	newPosition := types.Position{Row: 0, Col: 0}
+	reqData.RoomId = ResolveRoomId(reqData.RoomId)
 
-	// Create new user
-	newUser := types.User{
-		UserName:  reqData.UserName,
-		UserID:    userId,
-		RoomID:    string(reqData.RoomId),
-		Position:  newPosition,
-		Direction: types.DefaultDirection,
-		IsTyping:  false,
+	// * the user leaves its room once it's in the new one, a failed join keeps
+	// * it where it was. Rejoining the room puts it at a new position.
+	previousRoomId := user.RoomId
+	if previousRoomId == reqData.RoomId {
+		RemoveUser(user.ID, previousRoomId)
+		previousRoomId = ""
 	}
 
-	fmt.Printf("Updating room: %s\n", reqData.RoomId)
-	newPositionStr, newPosition := getRandomEmptyPosition(roomData.UsersPositions, 9)
-	newUser.Position = newPosition
-
-	roomData.Users = append(roomData.Users, newUser)
-	roomData.UsersPositions = append(roomData.UsersPositions, newPositionStr)
-	roomData.UserIdxMap[userId] = types.UserIdx(len(roomData.Users) - 1)
+	roomData, err := addRoomUser(reqData, user)
+	if err != nil {
+		if user.RoomId == reqData.RoomId {
+			leaveRoomId(userId)
+		}
 
-	fmt.Printf("roomData: %v\n", roomData)
+		return err
+	}
 
-	memory_storage.UpdateRoom(reqData.RoomId, roomData)
+	// * a spectator joining the room stops watching it
+	if user.WatchRoomId == reqData.RoomId {
+		UnwatchRoom(userId)
+	}
 
 	data := &types.UpdateUser{
 		RoomId:   (*string)(&reqData.RoomId),
@@ -322,8 +550,16 @@ func JoinRoom(reqData types.JoinRoom, messageClient *types.MessageClient, userId
 		fmt.Printf("failed to update client room: %v", err)
 	}
 
-	// ! Subscribe to the Redis channel for RoomId
-	go memory_storage.UserSubscribe(messageClient, reqData.RoomId)
+	if len(previousRoomId) > 0 {
+		RemoveUser(userId, previousRoomId)
+	}
+
+	// * Subscribe to the room events, the subscription lives until RemoveUser
+	if err := memory_storage.SubscribeRoom(messageClient, reqData.RoomId); err != nil {
+		RemoveUser(userId, reqData.RoomId)
+		leaveRoomId(userId)
+		return err
+	}
 
 	updateSceneData := types.UpdateScene{
 		RoomId: string(reqData.RoomId),
@@ -350,25 +586,42 @@ func JoinRoom(reqData types.JoinRoom, messageClient *types.MessageClient, userId
 		Data:  joinSuccessData,
 	})
 
+	sendWelcome(messageClient, reqData.RoomId, roomData, reqData.UserName)
+	sendTopic(messageClient, reqData.RoomId, roomData)
+
 	return nil
 }
 
-func BroadcastMessage(reqData types.Msg, messageClient *types.MessageClient, userId types.UserID) {
-	user, err := memory_storage.GetClient(reqData.From)
-	if err != nil {
-		fmt.Printf("client is not connected")
+func BroadcastMessage(reqData types.Msg, messageClient *types.MessageClient, userId types.UserID) error {
+	return broadcastMessage(reqData, messageClient, userId, false)
+}
+
+// BroadcastAction sends a /me message, the clients show it as something the
+// sender does
+func BroadcastAction(reqData types.Msg, messageClient *types.MessageClient, userId types.UserID) error {
+	return broadcastMessage(reqData, messageClient, userId, true)
+}
+
+// broadcastMessage sends the message to the room the sender is in, the room id
+// of the request is never trusted
+func broadcastMessage(reqData types.Msg, messageClient *types.MessageClient, userId types.UserID, isAction bool) error {
+	user, err := memory_storage.GetClient(userId)
+	if err != nil || len(user.RoomId) == 0 {
+		return ErrorUserNotInRoom
 	}
 
 	type MessageData struct {
-		Msg  string `json:"msg"`
-		From string `json:"from"`
+		Msg        string       `json:"msg"`
+		From       string       `json:"from"`
+		FromUserId types.UserID `json:"fromUserId"`
+		Action     bool         `json:"action,omitempty"`
 	}
 
-	var maxLenMsg int = 60
-
 	payload := MessageData{
-		Msg:  reqData.Msg,
-		From: user.Username,
+		Msg:        reqData.Msg,
+		From:       user.Username,
+		FromUserId: user.ID,
+		Action:     isAction,
 	}
 
 	fmt.Println("sending message:", reqData.Msg)
@@ -383,12 +636,141 @@ func BroadcastMessage(reqData types.Msg, messageClient *types.MessageClient, use
 	// cleanMsg := filter.CleanText(payload.Msg)
 	// payload.Msg = cleanMsg
 
-	memory_storage.BroadcastRoom(reqData.RoomId, "broadcastMessage", payload)
+	// * members that blocked or muted the sender don't get it
+	memory_storage.BroadcastRoomFrom(user.RoomId, messageSender(messageClient, userId), "broadcastMessage", payload)
+
+	// * kept as evidence for the reports, actions as they were typed
+	entry := types.HistoryEntry{
+		UserID:    user.ID,
+		AccountID: user.AccountID,
+		Username:  user.Username,
+		Msg:       payload.Msg,
+	}
+
+	if isAction {
+		entry.Msg = "/me " + payload.Msg
+	}
+
+	recordHistory(user.RoomId, entry)
+
+	return nil
+}
+
+// Whisper sends a message that only the recipient and the sender see, both
+// must be in the same room
+func Whisper(reqData types.DirectMsg, messageClient *types.MessageClient, userId types.UserID) error {
+	if reqData.ToUserId == userId {
+		return ErrorWhisperSelf
+	}
+
+	user, err := memory_storage.GetClient(userId)
+	if err != nil || len(user.RoomId) == 0 {
+		return ErrorUserNotInRoom
+	}
+
+	recipient, err := memory_storage.GetClient(reqData.ToUserId)
+	if err != nil || recipient.RoomId != user.RoomId {
+		return ErrorRecipientNotInRoom
+	}
+
+	type WhisperData struct {
+		Msg        string       `json:"msg"`
+		From       string       `json:"from"`
+		FromUserId types.UserID `json:"fromUserId"`
+		To         types.UserID `json:"to"`
+	}
+
+	payload := WhisperData{
+		Msg:        reqData.Msg,
+		From:       user.Username,
+		FromUserId: userId,
+		To:         recipient.ID,
+	}
+
+	if len(reqData.Msg) > maxLenMsg {
+		payload.Msg = reqData.Msg[:maxLenMsg]
+	}
+
+	// * the hub drops it when the recipient blocked or muted the sender
+	memory_storage.WhisperRoom(user.RoomId, messageSender(messageClient, userId), recipient.ID, "whisper", payload)
+
+	recordHistory(user.RoomId, types.HistoryEntry{
+		UserID:      user.ID,
+		AccountID:   user.AccountID,
+		Username:    user.Username,
+		Msg:         payload.Msg,
+		To:          recipient.ID,
+		ToAccountID: recipient.AccountID,
+	})
+
+	return nil
 }
 
-func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId types.UserID) {
+func recordHistory(roomId types.RoomId, entry types.HistoryEntry) {
+	entry.SentAt = time.Now().Unix()
+
+	if err := memory_storage.PushRoomHistory(roomId, entry); err != nil {
+		fmt.Printf("failed to record room history: %v\n", err)
+	}
+}
+
+// MuteUser hides the messages of another user from the connection until it
+// closes, it works for guests too
+func MuteUser(messageClient *types.MessageClient, userId types.UserID, target types.UserID, muted bool) error {
+	if target == userId {
+		return ErrorCannotMuteSelf
+	}
+
+	messageClient.Filter.Mute(target, muted)
+
+	return nil
+}
+
+func messageSender(messageClient *types.MessageClient, userId types.UserID) types.Sender {
+	return types.Sender{
+		UserID:    userId,
+		AccountID: messageClient.Client.AccountID,
+	}
+}
+
+func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId types.UserID) error {
+	// * a persistent room outlives its users, the guests and the unverified
+	// * accounts only create rooms deleted once empty
+	if reqData.Persistent && (messageClient.Session.IsGuest() || !messageClient.Session.Verified) {
+		return ErrorPersistentRoom
+	}
+
+	description := strings.TrimSpace(reqData.Description)
+	if len(description) > maxRoomDescriptionLen {
+		return ErrorDescriptionTooLong
+	}
+
+	tags, err := normalizeRoomTags(reqData.Tags)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) > maxRoomTags {
+		return ErrorTooManyRoomTags
+	}
+
+	visibility, err := roomVisibility(reqData.Visibility, messageClient.Session)
+	if err != nil {
+		return err
+	}
+
+	layout, err := roomLayout(reqData.Seats)
+	if err != nil {
+		return err
+	}
+
 	// ! remove a user from a room if connected
-	user, _ := memory_storage.GetClient(types.UserID(userId))
+	user, err := memory_storage.GetClient(types.UserID(userId))
+	if err != nil {
+		fmt.Printf("client is not connected: %v\n", err)
+		return err
+	}
+
 	if len(user.RoomId) > 0 {
 		RemoveUser(user.ID, user.RoomId)
 	}
@@ -400,14 +782,16 @@ func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId t
 	newUser := types.User{
 		UserName:  reqData.UserName,
 		UserID:    userId,
-		RoomID:    reqData.RoomName,
 		Position:  newPosition,
 		Direction: types.DefaultDirection,
 		IsTyping:  false,
+		Avatar:    user.Avatar,
+		AccountID: user.AccountID,
+		IsBot:     user.IsBot,
 	}
 
 	var isProtected bool = false
-	if len(*reqData.Password) > 0 {
+	if reqData.Password != nil && len(*reqData.Password) > 0 {
 		isProtected = true
 	}
 
@@ -417,9 +801,17 @@ func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId t
 		Name:           reqData.RoomName,
 		Password:       reqData.Password,
 		IsProtected:    isProtected,
+		Layout:         layout,
 		Users:          []types.User{},
 		UsersPositions: []string{},
 		UserIdxMap:     make(map[types.UserID]types.UserIdx),
+		OwnerID:        messageClient.Session.AccountID,
+		CreatedAt:      time.Now().Unix(),
+		Description:    description,
+		Tags:           tags,
+		Visibility:     visibility,
+		QueueSkip:      reqData.QueueSkip,
+		Persistent:     reqData.Persistent,
 	}
 
 	// Add new user data to the room
@@ -427,39 +819,35 @@ func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId t
 	roomData.UsersPositions = append(roomData.UsersPositions, fmt.Sprintf("%d,%d", newPosition.Row, newPosition.Col))
 	roomData.UserIdxMap[userId] = 0
 
-	roomId, err := newRoomId(reqData.RoomName)
+	roomId, err := createRoom(&roomData)
 	if err != nil {
-		fmt.Printf("failed to generate randomId")
-		return
+		fmt.Printf("failed to create room: %v\n", err)
+		return err
 	}
 
-	_, exists := memory_storage.GetRoom(*roomId)
-	if exists {
-		fmt.Printf("Room already exists")
-		return
-	}
-
-	memory_storage.CreateRoom(reqData.RoomName, *roomId, roomData)
-
 	data := &types.UpdateUser{
-		RoomId:   (*string)(roomId),
+		RoomId:   (*string)(&roomId),
 		UserName: &reqData.UserName,
 	}
 
 	if err := memory_storage.UpdateUser(userId, data); err != nil {
 		fmt.Printf("failed to update client room: %v", err)
-		return
+		return err
 	}
 
-	// ! Subscribe to the Redis channel for RoomId
-	go memory_storage.UserSubscribe(messageClient, *roomId)
+	// * Subscribe to the room events, the subscription lives until RemoveUser
+	if err := memory_storage.SubscribeRoom(messageClient, roomId); err != nil {
+		fmt.Printf("failed to subscribe to room: %v\n", err)
+		RemoveUser(userId, roomId)
+		return err
+	}
 
 	updateSceneData := types.UpdateScene{
-		RoomId: string(*roomId),
+		RoomId: string(roomId),
 		Users:  roomData.Users,
 	}
 
-	memory_storage.BroadcastRoom(types.RoomId(*roomId), "updateScene", updateSceneData)
+	memory_storage.BroadcastRoom(roomId, "updateScene", updateSceneData)
 
 	type SetUser struct {
 		UserId string `json:"userId"`
@@ -478,14 +866,17 @@ func NewRoom(reqData types.NewRoom, messageClient *types.MessageClient, userId t
 		Event: "setUserId",
 		Data:  setUserData,
 	})
+
+	return nil
 }
 
-func LeaveRoom(reqData types.UserLeave, userId types.UserID, activeConnections *sync.Map) {
+func LeaveRoom(reqData types.UserLeave, userId types.UserID) {
 	fmt.Printf("From \"leaveRoom\". User is leaving: %v", reqData.UserId)
 
 	user, err := memory_storage.GetClient(types.UserID(reqData.UserId))
 	if err != nil {
 		fmt.Printf("client is not connected")
+		return
 	}
 
 	emptyRoomId := ""
@@ -499,17 +890,62 @@ func LeaveRoom(reqData types.UserLeave, userId types.UserID, activeConnections *
 
 	// ! removes the user from room
 	RemoveUser(user.ID, user.RoomId)
+}
+
+// CloseRoom removes every user from the room and deletes it, the users stay
+// connected and get a "roomClosed" event
+func CloseRoom(roomId types.RoomId, reason string) error {
+	if roomId == memory_storage.WelcomeRoomId() {
+		return ErrorCannotCloseWelcome
+	}
+
+	return withRoomLock(roomId, func() error {
+		room, exists := memory_storage.GetRoom(roomId)
+		if !exists {
+			return ErrorRoomNotExists
+		}
+
+		return deleteRoom(roomId, room, reason)
+	})
+}
 
-	activeConnections.Delete(userId)
+// deleteRoom deletes the room with its slug, queue and spectators. Its users
+// stay connected and the members on every node get a "roomClosed" event. It's
+// called holding the lock of the room.
+func deleteRoom(roomId types.RoomId, room *types.RoomData, reason string) error {
+	emptyRoomId := ""
+	for _, user := range room.Users {
+		if err := memory_storage.UpdateUser(user.UserID, &types.UpdateUser{RoomId: &emptyRoomId}); err != nil {
+			fmt.Printf("couldn't update user's room id: %v\n", err)
+		}
+	}
+
+	if err := memory_storage.DeleteRoom(roomId); err != nil {
+		return err
+	}
+
+	dropQueue(roomId, reason)
+	dropWatchers(roomId)
+
+	if err := memory_storage.DeleteRoomSlug(room.Slug); err != nil {
+		fmt.Printf("couldn't delete room slug: %v\n", err)
+	}
+
+	// * every node drops its subscription to the room and tells its members
+	return memory_storage.PublishControl(types.ControlMessage{
+		Type:   types.ControlCloseRoom,
+		RoomId: roomId,
+		Reason: reason,
+	})
 }
 
 func SendPayload(mc *types.MessageClient, payload types.WsPayload) error {
-	JSONPayload, err := json.Marshal(payload)
+	encodedPayload, err := wire.FromSubprotocol(mc.Subprotocol).Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("something went wrong on sendPayload marshal: %v", err)
 	}
 
-	mc.Send <- JSONPayload
+	mc.Send <- encodedPayload
 
 	return nil
 }
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/types"
+	"errors"
+	"fmt"
+)
+
+const (
+	SpectatorLimit = 20
+
+	RoomOccupancyEvent = "roomOccupancy"
+)
+
+var (
+	ErrorRoomWatchersFull = errors.New("too many users are watching this room")
+	ErrorWatchOwnRoom     = errors.New("you are already in this room")
+)
+
+// WatchRoom subscribes the connection to the scene and the chat of the room
+// without joining it, a user watches one room at a time. The staff skips the
+// access rules and the spectators cap.
+func WatchRoom(reqData types.WatchRoom, messageClient *types.MessageClient, userId types.UserID) error {
+	user, err := memory_storage.GetClient(userId)
+	if err != nil {
+		return err
+	}
+
+	roomId := ResolveRoomId(reqData.RoomId)
+
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return ErrorRoomNotExists
+	}
+
+	if user.RoomId == roomId {
+		return ErrorWatchOwnRoom
+	}
+
+	staff := RoomRoleOf(room, user) == RoomStaff
+	if !staff {
+		joinData := types.JoinRoom{
+			RoomId:   roomId,
+			Password: reqData.Password,
+			Invite:   reqData.Invite,
+		}
+
+		if err := checkRoomAccess(roomId, room, user, joinData); err != nil {
+			return err
+		}
+	}
+
+	if len(user.WatchRoomId) > 0 {
+		UnwatchRoom(userId)
+	}
+
+	watchRoomId := string(roomId)
+	if err := memory_storage.UpdateUser(userId, &types.UpdateUser{WatchRoomId: &watchRoomId}); err != nil {
+		return err
+	}
+
+	spectators, err := memory_storage.AddRoomWatcher(roomId, userId)
+	if err != nil {
+		UnwatchRoom(userId)
+		return err
+	}
+
+	if !staff && spectators > SpectatorLimit && !roomWatchersFreed(roomId) {
+		UnwatchRoom(userId)
+		return ErrorRoomWatchersFull
+	}
+
+	if err := memory_storage.SubscribeRoom(messageClient, roomId); err != nil {
+		UnwatchRoom(userId)
+		return err
+	}
+
+	SendPayload(messageClient, types.WsPayload{
+		Event: "updateScene",
+		Data: types.UpdateScene{
+			RoomId: string(roomId),
+			Users:  room.Users,
+		},
+	})
+
+	sendTopic(messageClient, roomId, room)
+	broadcastOccupancy(roomId)
+
+	return nil
+}
+
+// roomWatchersFreed counts the spectators again without the ones that are gone
+// and reports whether the room is under its cap, the set only drops them when
+// it's read
+func roomWatchersFreed(roomId types.RoomId) bool {
+	watchers, err := memory_storage.GetRoomWatchers(roomId)
+	if err != nil {
+		fmt.Printf("failed to get room watchers: %v\n", err)
+		return false
+	}
+
+	return len(watchers) <= SpectatorLimit
+}
+
+// UnwatchRoom stops the spectating of the user, it's a no-op when it watches
+// nothing
+func UnwatchRoom(userId types.UserID) {
+	user, err := memory_storage.GetClient(userId)
+	if err != nil || len(user.WatchRoomId) == 0 {
+		return
+	}
+
+	roomId := user.WatchRoomId
+
+	emptyRoomId := ""
+	if err := memory_storage.UpdateUser(userId, &types.UpdateUser{WatchRoomId: &emptyRoomId}); err != nil {
+		fmt.Printf("failed to update client watched room: %v\n", err)
+	}
+
+	if err := memory_storage.RemoveRoomWatcher(roomId, userId); err != nil {
+		fmt.Printf("failed to remove room watcher: %v\n", err)
+	}
+
+	// * the user may have joined the room it watched, its subscription stays
+	if user.RoomId != roomId {
+		memory_storage.UnsubscribeRoom(userId, roomId)
+	}
+
+	broadcastOccupancy(roomId)
+}
+
+// RoomOccupancy returns how many users and spectators the room has
+func RoomOccupancy(roomId types.RoomId) (*types.RoomOccupancy, error) {
+	room, exists := memory_storage.GetRoom(roomId)
+	if !exists {
+		return nil, ErrorRoomNotExists
+	}
+
+	watchers, err := memory_storage.GetRoomWatchers(roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.RoomOccupancy{
+		RoomId:         roomId,
+		Users:          len(room.Users),
+		UserLimit:      RoomLimit,
+		Spectators:     len(watchers),
+		SpectatorLimit: SpectatorLimit,
+	}, nil
+}
+
+func broadcastOccupancy(roomId types.RoomId) {
+	occupancy, err := RoomOccupancy(roomId)
+	if err != nil {
+		fmt.Printf("failed to get room occupancy: %v\n", err)
+		return
+	}
+
+	memory_storage.BroadcastRoom(roomId, RoomOccupancyEvent, occupancy)
+}
+
+// dropWatchers clears the watched room of the spectators of a closed room, the
+// closeRoom control drops their subscriptions
+func dropWatchers(roomId types.RoomId) {
+	watchers, err := memory_storage.GetRoomWatchers(roomId)
+	if err != nil {
+		fmt.Printf("failed to get room watchers: %v\n", err)
+		return
+	}
+
+	emptyRoomId := ""
+	for _, userId := range watchers {
+		if err := memory_storage.UpdateUser(userId, &types.UpdateUser{WatchRoomId: &emptyRoomId}); err != nil {
+			fmt.Printf("failed to update client watched room: %v\n", err)
+		}
+	}
+
+	if err := memory_storage.DeleteRoomWatchers(roomId); err != nil {
+		fmt.Printf("failed to delete room watchers: %v\n", err)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"core/internal/adapters/memory_storage"
+	"core/internal/core"
+	types "core/types"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	WsTicketExpTime = 30 * time.Second
+)
+
+var (
+	ErrorInvalidTicket        = errors.New("invalid or expired ticket")
+	ErrorUnauthorized         = errors.New("unauthorized")
+	ErrorAlreadyAuthenticated = errors.New("already signed in")
+	ErrorUsernameReserved     = errors.New("username is reserved, sign in to use it")
+)
+
+type WsCredentials struct {
+	Ticket       string
+	AccessToken  string
+	RefreshToken string
+}
+
+type WsTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expiresIn"` // seconds
+}
+
+// IssueWsTicket creates a short lived ticket for clients that can't send the
+// auth cookies on the websocket upgrade
+func (ctx *UserService) IssueWsTicket(userId uint, sessionId string) (*WsTicketResponse, error) {
+	ticket, err := memory_storage.CreateTicket(userId, sessionId, WsTicketExpTime)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return nil, ErrorSaveFailed
+	}
+
+	return &WsTicketResponse{
+		Ticket:    ticket,
+		ExpiresIn: int(WsTicketExpTime.Seconds()),
+	}, nil
+}
+
+// AuthenticateWs resolves the session of a websocket upgrade. Requests
+// without credentials are guests, invalid credentials are an error.
+func (ctx *UserService) AuthenticateWs(credentials WsCredentials) (*types.Session, error) {
+	if credentials.Ticket != "" {
+		accountId, sessionId, err := memory_storage.RedeemTicket(credentials.Ticket)
+		if err != nil {
+			return nil, ErrorInvalidTicket
+		}
+
+		// * the session may have been logged out since the ticket was issued
+		refreshToken, err := ctx.refreshTokenRepo.GetActiveBySession(sessionId)
+		if err != nil {
+			return nil, ErrorUnauthorized
+		}
+
+		user, err := ctx.userRepo.GetById(float64(accountId))
+		if err != nil {
+			return nil, ErrorUnauthorized
+		}
+
+		if user.IsBanned() {
+			return nil, ErrorAccountBanned
+		}
+
+		return &types.Session{
+			AccountID: user.ID,
+			SessionID: sessionId,
+			Username:  user.Username,
+			Role:      user.Role,
+			Verified:  user.IsVerified(),
+			ExpiresAt: refreshToken.ExpiresAt,
+		}, nil
+	}
+
+	// * the refresh token outlives the access token so it's preferred to keep
+	// * the connection open for longer
+	tokenString, tokenType := credentials.RefreshToken, core.TokenTypeRefresh
+	if tokenString == "" {
+		tokenString, tokenType = credentials.AccessToken, core.TokenTypeAccess
+	}
+
+	if tokenString == "" {
+		return &types.Session{}, nil
+	}
+
+	payload, err := core.DecodeToken(tokenString, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ctx.refreshTokenRepo.IsSessionActive(payload.SessionID) {
+		return nil, ErrorUnauthorized
+	}
+
+	user, err := ctx.userRepo.GetById(float64(payload.Sub))
+	if err != nil {
+		return nil, ErrorUnauthorized
+	}
+
+	if user.IsBanned() {
+		return nil, ErrorAccountBanned
+	}
+
+	return &types.Session{
+		AccountID: user.ID,
+		SessionID: payload.SessionID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Verified:  user.IsVerified(),
+		ExpiresAt: payload.Exp,
+	}, nil
+}
+
+// GuestName validates the name a guest picked. The account usernames are
+// reserved so that guests can't impersonate them.
+func (ctx *UserService) GuestName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if len(name) < minUsernameLen {
+		return "", ErrorInvalidUsernameLen
+	}
+
+	if len(name) > maxUsernameLen {
+		return "", ErrorUsernameLenExceeded
+	}
+
+	if ctx.isUsernameTaken(name) {
+		return "", ErrorUsernameReserved
+	}
+
+	return name, nil
+}
+
+// UpgradeGuest gives a guest connection the identity of the account it just
+// signed in with. The user stays in its room, which is told about the new
+// name and avatar with a "userUpdated" event.
+func UpgradeGuest(userId types.UserID, session *types.Session, avatar types.Avatar) error {
+	accountId := session.AccountID
+
+	err := memory_storage.UpdateUser(userId, &types.UpdateUser{
+		UserName:  &session.Username,
+		Avatar:    &avatar,
+		AccountID: &accountId,
+		Role:      &session.Role,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update client: %w", err)
+	}
+
+	client, err := memory_storage.GetClient(userId)
+	if err != nil || len(client.RoomId) == 0 {
+		return nil
+	}
+
+	var user types.User
+	_, err = updateRoom(client.RoomId, func(room *types.RoomData) error {
+		userIdx, exists := room.UserIdxMap[userId]
+		if !exists {
+			return ErrorUserNotInRoom
+		}
+
+		room.Users[userIdx].UserName = session.Username
+		room.Users[userIdx].AccountID = accountId
+		room.Users[userIdx].Avatar = avatar
+
+		user = room.Users[userIdx]
+		return nil
+	})
+
+	// * the user may have left the room meanwhile
+	if err != nil {
+		return nil
+	}
+
+	memory_storage.BroadcastRoom(client.RoomId, "userUpdated", types.UpdateUserPosition{
+		User: user,
+	})
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"core/config"
+	"core/internal/adapters/memory_storage"
+	"core/types"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SystemMessageEvent = "systemMessage"
+
+	maxWelcomeTextLen = 200
+)
+
+var (
+	ErrorWelcomeTextTooLong = errors.New("welcome text is too long")
+)
+
+func newSystemMessage(msg string, scope string) types.SystemMessage {
+	return types.SystemMessage{
+		Msg:    msg,
+		From:   config.ChatbotName,
+		Scope:  scope,
+		SentAt: time.Now().Unix(),
+	}
+}
+
+// SendSystemMessage posts msg in the room on behalf of the chatbot
+func SendSystemMessage(roomId types.RoomId, msg string) error {
+	if _, exists := memory_storage.GetRoom(roomId); !exists {
+		return ErrorRoomNotExists
+	}
+
+	memory_storage.BroadcastRoom(roomId, SystemMessageEvent, newSystemMessage(msg, types.SystemScopeRoom))
+
+	return nil
+}
+
+// SendUserSystemMessage sends msg on behalf of the chatbot to the connection
+// only
+func SendUserSystemMessage(messageClient *types.MessageClient, msg string) error {
+	return SendPayload(messageClient, types.WsPayload{
+		Event: SystemMessageEvent,
+		Data:  newSystemMessage(msg, types.SystemScopeUser),
+	})
+}
+
+// Announce posts msg in every room on behalf of the chatbot
+func Announce(msg string) error {
+	rooms, err := memory_storage.GetRooms()
+	if err != nil {
+		return err
+	}
+
+	payload := newSystemMessage(msg, types.SystemScopeGlobal)
+	for roomId := range rooms {
+		memory_storage.BroadcastRoom(roomId, SystemMessageEvent, payload)
+	}
+
+	return nil
+}
+
+// SetWelcomeText changes the text sent to the users joining the room, an
+// empty text restores the default one
+func SetWelcomeText(roomId types.RoomId, text string) error {
+	text = strings.TrimSpace(text)
+	if len(text) > maxWelcomeTextLen {
+		return ErrorWelcomeTextTooLong
+	}
+
+	_, err := updateRoom(roomId, func(room *types.RoomData) error {
+		room.WelcomeText = text
+		return nil
+	})
+
+	return err
+}
+
+// sendWelcome greets the user that just joined the room, only the user gets it
+func sendWelcome(messageClient *types.MessageClient, roomId types.RoomId, room *types.RoomData, userName string) {
+	text := room.WelcomeText
+	if text == "" {
+		text = config.RoomWelcomeText
+	}
+
+	if text == "" {
+		return
+	}
+
+	text = strings.NewReplacer("{room}", room.Name, "{user}", userName).Replace(text)
+
+	err := SendPayload(messageClient, types.WsPayload{
+		Event: SystemMessageEvent,
+		Data:  newSystemMessage(text, types.SystemScopeRoom),
+	})
+
+	if err != nil {
+		fmt.Printf("failed to welcome %s to %s: %v\n", userName, roomId, err)
+	}
+}
@@ -2,12 +2,15 @@ package services
 
 import (
 	"core/internal/adapters/database/models"
+	"core/internal/adapters/memory_storage"
 	"core/internal/core"
 	repositories "core/internal/ports"
 	"core/types"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,13 +21,14 @@ const (
 
 var (
 	ErrorInvalidCredentials = errors.New("invalid Email and/or Password")
-	ErrorEmailNotRegistered = errors.New("email is not registered")
 
 	ErrorEmailAlreadyRegistered    = errors.New("email already exists")
 	ErrorUsernameAlreadyRegistered = errors.New("username already exists")
 	ErrorFailedToHashPassword      = errors.New("failed to hash password")
 	ErrorSaveFailed                = errors.New("failed to save")
 	ErrorUserNotFound              = errors.New("user not found")
+	ErrorTokenReused               = errors.New("refresh token was already used, session revoked")
+	ErrorExportFailed              = errors.New("failed to export account")
 
 	ErrorPasswordLenExceeded = fmt.Errorf("password must be no longer than %d characters", core.BcryptCharacterLimit)
 	ErrorUsernameLenExceeded = fmt.Errorf("username must be no longer than %d characters", maxUsernameLen)
@@ -33,40 +37,56 @@ var (
 )
 
 type UserService struct {
-	userRepo *repositories.UserRepoContext
-	logger   core.LoggerI
+	userRepo         *repositories.UserRepoContext
+	avatarRepo       *repositories.AvatarRepoContext
+	refreshTokenRepo *repositories.RefreshTokenRepoContext
+	friendshipRepo   *repositories.FriendshipRepoContext
+	guard            loginGuard
+	logger           core.LoggerI
 }
 
-func NewUserService(logger core.LoggerI, userRepo *repositories.UserRepoContext) *UserService {
+func NewUserService(logger core.LoggerI, userRepo *repositories.UserRepoContext, avatarRepo *repositories.AvatarRepoContext, refreshTokenRepo *repositories.RefreshTokenRepoContext, friendshipRepo *repositories.FriendshipRepoContext) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		logger:   logger,
+		userRepo:         userRepo,
+		avatarRepo:       avatarRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		friendshipRepo:   friendshipRepo,
+		guard:            loginGuard{logger: logger},
+		logger:           logger,
 	}
 }
 
-type RefreshTokenResponse struct {
-	AccessToken string `json:"accessToken"`
-}
-
 // services should return an error and a response any
 // controllers send the http.Status and ApiError
-func (ctx *UserService) Login(email string, password string) (*core.AuthTokensResponse, error) {
-	user, err := ctx.userRepo.GetByEmail(email)
-	if err != nil {
-		return nil, ErrorEmailNotRegistered
+func (ctx *UserService) Login(email string, password string, ip string) (*core.AuthTokensResponse, error) {
+	if err := ctx.guard.checkLoginLocked(email, ip); err != nil {
+		return nil, err
 	}
 
-	if user.ID == 0 {
+	// * unknown emails and wrong passwords fail the same way so the login
+	// * can't be used to find registered emails
+	user, err := ctx.userRepo.GetByEmail(email)
+	if err != nil || user.ID == 0 {
+		compareDummyPassword(password)
+		ctx.guard.recordLoginFailure(email, ip)
 		return nil, ErrorInvalidCredentials
 	}
 
 	authorized := core.CompareHashAndPassword(user.Password, password)
 	if !authorized {
+		ctx.guard.recordLoginFailure(email, ip)
 		return nil, ErrorInvalidCredentials
 	}
 
+	ctx.guard.resetLoginFailures(email)
+
+	// * checked after the password so that it doesn't tell who is banned
+	if user.IsBanned() {
+		return nil, ErrorAccountBanned
+	}
+
 	// * Generate jwt access and refresh pair tokens
-	authTokens, err := core.GetTokensPair(user.ID, user.Username)
+	authTokens, err := ctx.issueTokens(user.ID, user.Username, core.NewSessionId())
 	if err != nil {
 		return nil, err
 	}
@@ -75,38 +95,38 @@ func (ctx *UserService) Login(email string, password string) (*core.AuthTokensRe
 	return authTokens, nil
 }
 
-func (ctx *UserService) Signup(email string, username string, password string) error {
+func (ctx *UserService) Signup(email string, username string, password string) (*models.User, error) {
 	if len(password) > core.BcryptCharacterLimit {
-		return ErrorPasswordLenExceeded
+		return nil, ErrorPasswordLenExceeded
 	}
 
-	if len(username) < 2 {
-		return ErrorInvalidUsernameLen
+	if len(username) < minUsernameLen {
+		return nil, ErrorInvalidUsernameLen
 	}
 
 	if len(username) > maxUsernameLen {
-		return ErrorUsernameLenExceeded
+		return nil, ErrorUsernameLenExceeded
 	}
 
 	if len(password) < minPasswordLen {
-		return ErrorInvalidPasswordLen
+		return nil, ErrorInvalidPasswordLen
 	}
 
 	// * 2. Check if Email or Username is already stored
 	exists := ctx.userRepo.ExistsEmail(email)
 	if exists {
-		return ErrorEmailAlreadyRegistered
+		return nil, ErrorEmailAlreadyRegistered
 	}
 
-	exists = ctx.userRepo.ExistsUsername(username)
+	exists = ctx.isUsernameTaken(username)
 	if exists {
-		return ErrorUsernameAlreadyRegistered
+		return nil, ErrorUsernameAlreadyRegistered
 	}
 
 	// * 3. Hash password
 	passwordHash, err := core.GenPasswordHash(password)
 	if err != nil {
-		return ErrorFailedToHashPassword
+		return nil, ErrorFailedToHashPassword
 	}
 
 	// * 4. Save user
@@ -116,37 +136,141 @@ func (ctx *UserService) Signup(email string, username string, password string) e
 		Password: string(*passwordHash),
 	}
 
-	if _, err := ctx.userRepo.Save(user); err != nil {
-		return ErrorSaveFailed
+	savedUser, err := ctx.userRepo.Save(user)
+	if err != nil {
+		return nil, ErrorSaveFailed
 	}
 
 	// * 5. Send a response
-	return nil
+	return savedUser, nil
 }
 
-func (ctx *UserService) RefreshToken(user models.User) (*RefreshTokenResponse, error) {
-	refreshTokenString, err := core.GenerateToken(user.ID, user.Username, "refresh")
+// issueTokens generates a tokens pair for the session and stores its
+// refresh token so it can be rotated and revoked
+func (ctx *UserService) issueTokens(userId uint, username string, sessionId string) (*core.AuthTokensResponse, error) {
+	authTokens, err := core.GetTokensPair(userId, username, sessionId)
 	if err != nil {
 		return nil, err
 	}
 
-	return &RefreshTokenResponse{AccessToken: *refreshTokenString}, nil
+	_, err = ctx.refreshTokenRepo.Save(models.RefreshToken{
+		JTI:       authTokens.RefreshTokenID,
+		UserID:    userId,
+		SessionID: sessionId,
+		ExpiresAt: authTokens.RefreshTokenExpiresAt,
+	})
+
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	return authTokens, nil
+}
+
+// RefreshToken rotates the refresh token, issuing a new tokens pair for the
+// same session. Reusing a rotated token revokes the whole session.
+func (ctx *UserService) RefreshToken(refreshToken string) (*core.AuthTokensResponse, error) {
+	payload, err := core.DecodeToken(refreshToken, core.TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	storedToken, err := ctx.refreshTokenRepo.GetByJTI(payload.ID)
+	if err != nil {
+		return nil, core.ErrorInvalidToken
+	}
+
+	user, err := ctx.userRepo.GetById(float64(storedToken.UserID))
+	if err != nil {
+		return nil, ErrorUserNotFound
+	}
+
+	authTokens, err := core.GetTokensPair(user.ID, user.Username, storedToken.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := ctx.refreshTokenRepo.Rotate(storedToken.JTI, authTokens.RefreshTokenID)
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	if !rotated {
+		// * the token was already rotated or revoked, someone else may hold it
+		ctx.logger.Warn(fmt.Sprintf("refresh token reuse detected user=%d session=%s", user.ID, storedToken.SessionID))
+
+		if err := ctx.refreshTokenRepo.RevokeSession(storedToken.SessionID); err != nil {
+			ctx.logger.Error(err.Error())
+		}
+
+		if err := memory_storage.DisconnectSession(storedToken.SessionID, "session revoked"); err != nil {
+			ctx.logger.Error(err.Error())
+		}
+
+		return nil, ErrorTokenReused
+	}
+
+	_, err = ctx.refreshTokenRepo.Save(models.RefreshToken{
+		JTI:       authTokens.RefreshTokenID,
+		UserID:    user.ID,
+		SessionID: storedToken.SessionID,
+		ExpiresAt: authTokens.RefreshTokenExpiresAt,
+	})
+
+	if err != nil {
+		return nil, ErrorSaveFailed
+	}
+
+	return authTokens, nil
+}
+
+// Logout revokes the session of the refresh token
+func (ctx *UserService) Logout(refreshToken string) error {
+	payload, err := core.DecodeToken(refreshToken, core.TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.refreshTokenRepo.RevokeSession(payload.SessionID); err != nil {
+		return err
+	}
+
+	// * the websockets opened with the session go too
+	return memory_storage.DisconnectSession(payload.SessionID, "logged out")
+}
+
+// LogoutEverywhere revokes every session of the user
+func (ctx *UserService) LogoutEverywhere(userId uint) error {
+	if err := ctx.refreshTokenRepo.RevokeAllByUserId(userId); err != nil {
+		return err
+	}
+
+	return memory_storage.DisconnectAccount(userId, "logged out everywhere")
+}
+
+// IsSessionActive reports whether the session wasn't logged out or revoked
+func (ctx *UserService) IsSessionActive(sessionId string) bool {
+	return ctx.refreshTokenRepo.IsSessionActive(sessionId)
 }
 
-func (ctx *UserService) Update(userId uint, updateUser types.UpdateUser) (*core.AuthTokensResponse, error) {
+func (ctx *UserService) Update(userId uint, sessionId string, updateUser types.UpdateUser) (*core.AuthTokensResponse, error) {
 	if updateUser.Password != nil && len(*updateUser.Password) < minPasswordLen {
 		return nil, ErrorInvalidPasswordLen
 	}
 
-	if len(*updateUser.UserName) < 2 {
+	if updateUser.Password != nil && len(*updateUser.Password) > core.BcryptCharacterLimit {
+		return nil, ErrorPasswordLenExceeded
+	}
+
+	if updateUser.UserName != nil && len(*updateUser.UserName) < minUsernameLen {
 		return nil, ErrorInvalidUsernameLen
 	}
 
-	if len(*updateUser.UserName) > maxUsernameLen {
+	if updateUser.UserName != nil && len(*updateUser.UserName) > maxUsernameLen {
 		return nil, ErrorUsernameLenExceeded
 	}
 
-	if updateUser.UserName != nil && ctx.userRepo.ExistsUsername(*updateUser.UserName) {
+	if updateUser.UserName != nil && ctx.isUsernameTaken(*updateUser.UserName) {
 		return nil, repositories.ErrorUsernameExists
 	}
 
@@ -155,7 +279,19 @@ func (ctx *UserService) Update(userId uint, updateUser types.UpdateUser) (*core.
 		return nil, ErrorSaveFailed
 	}
 
-	authTokens, err := core.GetTokensPair(userId, user.Username)
+	// * the tokens carry the username so the current session is replaced,
+	// * a password change logs out every session
+	if updateUser.Password != nil {
+		err = ctx.refreshTokenRepo.RevokeAllByUserId(userId)
+	} else {
+		err = ctx.refreshTokenRepo.RevokeSession(sessionId)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	authTokens, err := ctx.issueTokens(userId, user.Username, core.NewSessionId())
 	if err != nil {
 		return nil, err
 	}
@@ -164,10 +300,12 @@ func (ctx *UserService) Update(userId uint, updateUser types.UpdateUser) (*core.
 }
 
 type GetUserProfile struct {
-	UserID    uint   `json:"userId"`
-	Email     string `json:"email"`
-	Username  string `json:"username"`
-	CreatedAt int64  `json:"createdAt"` // timestamp
+	UserID        uint   `json:"userId"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	Username      string `json:"username"`
+	Role          string `json:"role"`
+	CreatedAt     int64  `json:"createdAt"` // timestamp
 }
 
 func (ctx *UserService) GetProfile(userId uint) (*GetUserProfile, error) {
@@ -177,9 +315,128 @@ func (ctx *UserService) GetProfile(userId uint) (*GetUserProfile, error) {
 	}
 
 	return &GetUserProfile{
-		UserID:    user.ID,
-		Email:     user.Email,
-		Username:  user.Username,
-		CreatedAt: user.CreatedAt.Unix(),
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.IsVerified(),
+		Username:      user.Username,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt.Unix(),
 	}, nil
 }
+
+type ExportedRoom struct {
+	RoomId      types.RoomId `json:"roomId"`
+	RoomName    string       `json:"roomName"`
+	IsProtected bool         `json:"isProtected"`
+	CreatedAt   int64        `json:"createdAt"` // timestamp
+}
+
+type ExportedFriendship struct {
+	AccountID uint   `json:"accountId"`
+	Username  string `json:"username"`
+	Status    string `json:"status"`
+	Outgoing  bool   `json:"outgoing"`  // * the user sent the request or the block
+	CreatedAt int64  `json:"createdAt"` // timestamp
+}
+
+// AccountExport is everything stored about an account
+type AccountExport struct {
+	ExportedAt int64                `json:"exportedAt"` // timestamp
+	Profile    GetUserProfile       `json:"profile"`
+	Avatar     types.Avatar         `json:"avatar"`
+	Rooms      []ExportedRoom       `json:"rooms"`
+	Friends    []ExportedFriendship `json:"friends"`
+}
+
+func (ctx *UserService) ExportAccount(userId uint) (*AccountExport, error) {
+	profile, err := ctx.GetProfile(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedRooms, err := memory_storage.GetOwnedRooms(userId)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return nil, ErrorExportFailed
+	}
+
+	friends, err := ctx.exportFriendships(userId)
+	if err != nil {
+		ctx.logger.Error(err.Error())
+		return nil, ErrorExportFailed
+	}
+
+	return &AccountExport{
+		ExportedAt: time.Now().Unix(),
+		Profile:    *profile,
+		Avatar:     ctx.GetAvatar(userId),
+		Rooms:      exportedRooms(ownedRooms),
+		Friends:    friends,
+	}, nil
+}
+
+func (ctx *UserService) exportFriendships(userId uint) ([]ExportedFriendship, error) {
+	friendships, err := ctx.friendshipRepo.ListByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	otherIds := make([]uint, 0, len(friendships))
+	for _, friendship := range friendships {
+		otherIds = append(otherIds, friendship.Other(userId))
+	}
+
+	users, err := ctx.userRepo.GetByIds(otherIds)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	return exportedFriendships(userId, friendships, usernames), nil
+}
+
+// exportedRooms lists the owned rooms from the oldest one
+func exportedRooms(ownedRooms map[types.RoomId]types.RoomData) []ExportedRoom {
+	rooms := []ExportedRoom{}
+	for roomId, roomData := range ownedRooms {
+		rooms = append(rooms, ExportedRoom{
+			RoomId:      roomId,
+			RoomName:    roomData.Name,
+			IsProtected: roomData.IsProtected,
+			CreatedAt:   roomData.CreatedAt,
+		})
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].CreatedAt != rooms[j].CreatedAt {
+			return rooms[i].CreatedAt < rooms[j].CreatedAt
+		}
+
+		return rooms[i].RoomId < rooms[j].RoomId
+	})
+
+	return rooms
+}
+
+// exportedFriendships lists the friendships of the user as seen by it, the
+// accounts missing from usernames were deleted
+func exportedFriendships(userId uint, friendships []models.Friendship, usernames map[uint]string) []ExportedFriendship {
+	friends := make([]ExportedFriendship, 0, len(friendships))
+	for _, friendship := range friendships {
+		otherId := friendship.Other(userId)
+
+		friends = append(friends, ExportedFriendship{
+			AccountID: otherId,
+			Username:  usernames[otherId],
+			Status:    friendship.Status,
+			Outgoing:  friendship.RequesterID == userId,
+			CreatedAt: friendship.CreatedAt.Unix(),
+		})
+	}
+
+	return friends
+}
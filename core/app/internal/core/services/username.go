@@ -0,0 +1,40 @@
+package services
+
+import (
+	"core/config"
+	"strings"
+	"sync"
+)
+
+var (
+	reservedMu        sync.RWMutex
+	reservedUsernames = map[string]struct{}{} // * lowercase names of the bots
+)
+
+// ReserveUsername keeps a name used by the server, like a bot's, from the
+// accounts and the guests
+func ReserveUsername(name string) {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+
+	reservedUsernames[strings.ToLower(strings.TrimSpace(name))] = struct{}{}
+}
+
+func isReservedUsername(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == strings.ToLower(config.ChatbotName) {
+		return true
+	}
+
+	reservedMu.RLock()
+	defer reservedMu.RUnlock()
+
+	_, reserved := reservedUsernames[name]
+	return reserved
+}
+
+// isUsernameTaken reports whether an account or the server uses the name,
+// ignoring the case so that look-alike names are taken too
+func (ctx *UserService) isUsernameTaken(name string) bool {
+	return isReservedUsername(name) || ctx.userRepo.IsUsernameTaken(name)
+}
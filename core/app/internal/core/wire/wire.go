@@ -0,0 +1,147 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	SubprotocolJSON    = "json"
+	SubprotocolMsgPack = "msgpack"
+)
+
+// Codec encodes and decodes the WsPayload envelope for a negotiated
+// WebSocket subprotocol.
+type Codec interface {
+	Subprotocol() string
+	MessageType() int // websocket frame type used to write the payloads
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// DecodeEnvelope reads the envelope of a frame, leaving its Data encoded
+	// so that it's decoded only once into the event's request type
+	DecodeEnvelope(frame []byte) (*Envelope, error)
+}
+
+// Envelope is an incoming WsPayload whose Data is still encoded
+type Envelope struct {
+	Event string
+	Data  []byte
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	MsgPack Codec = newMsgPackCodec()
+
+	// Internal is the encoding used for the Redis pub/sub payloads
+	Internal = MsgPack
+)
+
+// Subprotocols lists the supported subprotocols by order of preference
+func Subprotocols() []string {
+	return []string{SubprotocolMsgPack, SubprotocolJSON}
+}
+
+// FromSubprotocol returns the codec for the subprotocol negotiated on the
+// upgrade, defaulting to JSON when the client didn't ask for any.
+func FromSubprotocol(subprotocol string) Codec {
+	if subprotocol == SubprotocolMsgPack {
+		return MsgPack
+	}
+
+	return JSON
+}
+
+// Transcode re-encodes a payload produced by one codec with another one
+func Transcode(data []byte, from Codec, to Codec) ([]byte, error) {
+	if from == to {
+		return data, nil
+	}
+
+	var v interface{}
+	if err := from.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed decoding %s payload: %w", from.Subprotocol(), err)
+	}
+
+	return to.Marshal(v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return SubprotocolJSON }
+
+func (jsonCodec) MessageType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) DecodeEnvelope(frame []byte) (*Envelope, error) {
+	var envelope struct {
+		Event string
+		Data  json.RawMessage
+	}
+
+	if err := json.Unmarshal(frame, &envelope); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Event: envelope.Event,
+		Data:  envelope.Data,
+	}, nil
+}
+
+type msgPackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgPackCodec() msgPackCodec {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true    // * use the str8/bin types of the current spec
+	handle.RawToString = true // * decode the old spec raw type as string
+	handle.Raw = true         // * allows decoding into codec.Raw
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	return msgPackCodec{handle: handle}
+}
+
+func (msgPackCodec) Subprotocol() string { return SubprotocolMsgPack }
+
+func (msgPackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func (c msgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, c.handle).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (c msgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
+
+func (c msgPackCodec) DecodeEnvelope(frame []byte) (*Envelope, error) {
+	var envelope struct {
+		Event string
+		Data  codec.Raw
+	}
+
+	if err := c.Unmarshal(frame, &envelope); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Event: envelope.Event,
+		Data:  envelope.Data,
+	}, nil
+}
@@ -0,0 +1,236 @@
+package wire_test
+
+import (
+	"core/internal/core/wire"
+	"core/types"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// updateScenePayload builds an updateScene payload for a full room
+func updateScenePayload() types.WsPayload {
+	const roomLimit = 10
+
+	users := make([]types.User, 0, roomLimit)
+	for i := 0; i < roomLimit; i++ {
+		users = append(users, types.User{
+			UserName:  fmt.Sprintf("ghoulie %d", i),
+			UserID:    types.UserID(fmt.Sprintf("%06d", 334288+i)),
+			RoomID:    "keep the block hot#0",
+			Position:  types.Position{Row: i % 9, Col: (i * 3) % 9},
+			Direction: types.DefaultDirection,
+			IsTyping:  i%2 == 0,
+		})
+	}
+
+	return types.WsPayload{
+		Event: "updateScene",
+		Data: types.UpdateScene{
+			RoomId: "keep the block hot#0",
+			Users:  users,
+		},
+	}
+}
+
+var codecs = []wire.Codec{wire.JSON, wire.MsgPack}
+
+// decodeScene decodes an updateScene frame like the clients of the codec do
+func decodeScene(t *testing.T, codec wire.Codec, frame []byte) types.UpdateScene {
+	t.Helper()
+
+	envelope, err := codec.DecodeEnvelope(frame)
+	if err != nil {
+		t.Fatalf("%s: DecodeEnvelope: %v", codec.Subprotocol(), err)
+	}
+
+	if envelope.Event != "updateScene" {
+		t.Fatalf("%s: event %q, want updateScene", codec.Subprotocol(), envelope.Event)
+	}
+
+	var scene types.UpdateScene
+	if err := codec.Unmarshal(envelope.Data, &scene); err != nil {
+		t.Fatalf("%s: Unmarshal: %v", codec.Subprotocol(), err)
+	}
+
+	return scene
+}
+
+func TestRoundTrip(t *testing.T) {
+	payload := updateScenePayload()
+	want := payload.Data.(types.UpdateScene)
+
+	for _, codec := range codecs {
+		t.Run(codec.Subprotocol(), func(t *testing.T) {
+			frame, err := codec.Marshal(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := decodeScene(t, codec, frame); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	payload := updateScenePayload()
+	want := payload.Data.(types.UpdateScene)
+
+	for _, from := range codecs {
+		for _, to := range codecs {
+			t.Run(from.Subprotocol()+" to "+to.Subprotocol(), func(t *testing.T) {
+				frame, err := from.Marshal(payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				transcoded, err := wire.Transcode(frame, from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := decodeScene(t, to, transcoded); !reflect.DeepEqual(got, want) {
+					t.Errorf("got %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestFromSubprotocol(t *testing.T) {
+	tests := []struct {
+		subprotocol     string
+		wantCodec       wire.Codec
+		wantMessageType int
+	}{
+		{subprotocol: wire.SubprotocolMsgPack, wantCodec: wire.MsgPack, wantMessageType: websocket.BinaryMessage},
+		{subprotocol: wire.SubprotocolJSON, wantCodec: wire.JSON, wantMessageType: websocket.TextMessage},
+		{subprotocol: "", wantCodec: wire.JSON, wantMessageType: websocket.TextMessage},
+		{subprotocol: "xml", wantCodec: wire.JSON, wantMessageType: websocket.TextMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subprotocol, func(t *testing.T) {
+			codec := wire.FromSubprotocol(tt.subprotocol)
+			if codec != tt.wantCodec {
+				t.Errorf("got %s, want %s", codec.Subprotocol(), tt.wantCodec.Subprotocol())
+			}
+
+			if codec.MessageType() != tt.wantMessageType {
+				t.Errorf("message type %d, want %d", codec.MessageType(), tt.wantMessageType)
+			}
+		})
+	}
+}
+
+func TestInvalidFrames(t *testing.T) {
+	msgPackFrame, err := wire.MsgPack.Marshal(updateScenePayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		codec     wire.Codec
+		frame     []byte
+		malformed bool // * not even a value of the codec, Transcode fails too
+	}{
+		{name: "empty json", codec: wire.JSON, frame: []byte{}, malformed: true},
+		{name: "not json", codec: wire.JSON, frame: []byte("updateScene"), malformed: true},
+		{name: "truncated json", codec: wire.JSON, frame: []byte(`{"Event":"updateScene","Data":{`), malformed: true},
+		{name: "json array", codec: wire.JSON, frame: []byte(`["updateScene"]`)},
+		{name: "json event of the wrong type", codec: wire.JSON, frame: []byte(`{"Event":1}`)},
+		{name: "empty msgpack", codec: wire.MsgPack, frame: []byte{}, malformed: true},
+		{name: "truncated msgpack", codec: wire.MsgPack, frame: msgPackFrame[:len(msgPackFrame)/2], malformed: true},
+		{name: "msgpack event of the wrong type", codec: wire.MsgPack, frame: []byte{0x81, 0xa5, 'E', 'v', 'e', 'n', 't', 0x81, 0xa1, 'x', 0x01}},
+		{name: "json sent as msgpack", codec: wire.MsgPack, frame: []byte(`{"Event":"updateScene"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.DecodeEnvelope(tt.frame); err == nil {
+				t.Error("DecodeEnvelope didn't fail")
+			}
+
+			_, err := wire.Transcode(tt.frame, tt.codec, otherCodec(tt.codec))
+			if tt.malformed && err == nil {
+				t.Error("Transcode didn't fail")
+			}
+
+			if !tt.malformed && err != nil {
+				t.Errorf("Transcode failed on a valid value: %v", err)
+			}
+		})
+	}
+}
+
+func otherCodec(codec wire.Codec) wire.Codec {
+	if codec == wire.JSON {
+		return wire.MsgPack
+	}
+
+	return wire.JSON
+}
+
+func benchmarkMarshal(b *testing.B, codec wire.Codec) {
+	payload := updateScenePayload()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frame, err := codec.Marshal(payload)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.SetBytes(int64(len(frame)))
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, codec wire.Codec) {
+	frame, err := codec.Marshal(updateScenePayload())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		envelope, err := codec.DecodeEnvelope(frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var scene types.UpdateScene
+		if err := codec.Unmarshal(envelope.Data, &scene); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkTranscode(b *testing.B, codec wire.Codec) {
+	frame, err := wire.Internal.Marshal(updateScenePayload())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := wire.Transcode(frame, wire.Internal, codec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalUpdateSceneJSON(b *testing.B)    { benchmarkMarshal(b, wire.JSON) }
+func BenchmarkMarshalUpdateSceneMsgPack(b *testing.B) { benchmarkMarshal(b, wire.MsgPack) }
+
+func BenchmarkUnmarshalUpdateSceneJSON(b *testing.B)    { benchmarkUnmarshal(b, wire.JSON) }
+func BenchmarkUnmarshalUpdateSceneMsgPack(b *testing.B) { benchmarkUnmarshal(b, wire.MsgPack) }
+
+// * cost of delivering a Redis payload to a client of each codec
+func BenchmarkTranscodeUpdateSceneJSON(b *testing.B)    { benchmarkTranscode(b, wire.JSON) }
+func BenchmarkTranscodeUpdateSceneMsgPack(b *testing.B) { benchmarkTranscode(b, wire.MsgPack) }
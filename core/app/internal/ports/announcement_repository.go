@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrorAnnouncementNotFound = errors.New("announcement not found")
+)
+
+type AnnouncementRepo interface {
+	ListPending() ([]models.Announcement, error)
+	ListDue(now time.Time) ([]models.Announcement, error)
+	Save(announcement models.Announcement) (*models.Announcement, error)
+	MarkSent(id uint, sentAt time.Time, nextSendAt *time.Time) error
+	Delete(id uint) error
+}
+
+type AnnouncementRepoContext struct {
+	db *gorm.DB
+}
+
+func NewAnnouncementRepoContext(db *gorm.DB) *AnnouncementRepoContext {
+	return &AnnouncementRepoContext{
+		db: db,
+	}
+}
+
+// ListPending returns the announcements that will be sent again
+func (ctx *AnnouncementRepoContext) ListPending() ([]models.Announcement, error) {
+	announcements := []models.Announcement{}
+	result := ctx.db.Where("done = ?", false).Order("send_at ASC").Find(&announcements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return announcements, nil
+}
+
+// ListDue returns the pending announcements that should have been sent by now
+func (ctx *AnnouncementRepoContext) ListDue(now time.Time) ([]models.Announcement, error) {
+	announcements := []models.Announcement{}
+	result := ctx.db.Where("done = ? AND send_at <= ?", false, now).Order("send_at ASC").Find(&announcements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return announcements, nil
+}
+
+func (ctx *AnnouncementRepoContext) Save(announcement models.Announcement) (*models.Announcement, error) {
+	result := ctx.db.Create(&announcement)
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &announcement, nil
+}
+
+// MarkSent records the delivery, the announcement is done when there is no
+// next delivery
+func (ctx *AnnouncementRepoContext) MarkSent(id uint, sentAt time.Time, nextSendAt *time.Time) error {
+	updates := map[string]interface{}{"last_sent_at": sentAt, "done": true}
+	if nextSendAt != nil {
+		updates = map[string]interface{}{"last_sent_at": sentAt, "send_at": *nextSendAt}
+	}
+
+	result := ctx.db.Model(&models.Announcement{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	return nil
+}
+
+func (ctx *AnnouncementRepoContext) Delete(id uint) error {
+	result := ctx.db.Delete(&models.Announcement{}, id)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorAnnouncementNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrorAvatarNotFound = errors.New("avatar not found")
+)
+
+type AvatarRepo interface {
+	GetByUserId(userId uint) (*models.Avatar, error)
+	Save(avatar models.Avatar) (*models.Avatar, error)
+}
+
+type AvatarRepoContext struct {
+	db *gorm.DB
+}
+
+func NewAvatarRepoContext(db *gorm.DB) *AvatarRepoContext {
+	return &AvatarRepoContext{
+		db: db,
+	}
+}
+
+func (ctx *AvatarRepoContext) GetByUserId(userId uint) (*models.Avatar, error) {
+	var avatar models.Avatar
+	result := ctx.db.First(&avatar, "user_id = ?", userId)
+	if result.Error != nil {
+		return nil, ErrorAvatarNotFound
+	}
+
+	return &avatar, nil
+}
+
+// Save creates the user's avatar or replaces the existing one
+func (ctx *AvatarRepoContext) Save(avatar models.Avatar) (*models.Avatar, error) {
+	result := ctx.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "body", "primary_color", "secondary_color", "accessories"}),
+	}).Create(&avatar)
+
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &avatar, nil
+}
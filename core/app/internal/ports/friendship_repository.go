@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrorFriendshipNotFound = errors.New("friendship not found")
+)
+
+type FriendshipRepo interface {
+	GetBetween(userId uint, otherId uint) (*models.Friendship, error)
+	ListByUserId(userId uint) ([]models.Friendship, error)
+	FriendIds(userId uint) ([]uint, error)
+	BlockedIds(userId uint) ([]uint, error)
+	Save(friendship models.Friendship) (*models.Friendship, error)
+	UpdateStatus(id uint, status string) error
+	Delete(id uint) error
+}
+
+type FriendshipRepoContext struct {
+	db *gorm.DB
+}
+
+func NewFriendshipRepoContext(db *gorm.DB) *FriendshipRepoContext {
+	return &FriendshipRepoContext{
+		db: db,
+	}
+}
+
+// GetBetween returns the friendship of the pair, in either direction
+func (ctx *FriendshipRepoContext) GetBetween(userId uint, otherId uint) (*models.Friendship, error) {
+	var friendship models.Friendship
+	result := ctx.db.First(&friendship,
+		"(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)",
+		userId, otherId, otherId, userId,
+	)
+
+	if result.Error != nil {
+		return nil, ErrorFriendshipNotFound
+	}
+
+	return &friendship, nil
+}
+
+// ListByUserId returns every friendship of the user, whatever its status
+func (ctx *FriendshipRepoContext) ListByUserId(userId uint) ([]models.Friendship, error) {
+	var friendships []models.Friendship
+	result := ctx.db.Where("requester_id = ? OR addressee_id = ?", userId, userId).
+		Order("created_at").
+		Find(&friendships)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return friendships, nil
+}
+
+// FriendIds returns the users with an accepted friendship with the user
+func (ctx *FriendshipRepoContext) FriendIds(userId uint) ([]uint, error) {
+	var friendships []models.Friendship
+	result := ctx.db.Where("(requester_id = ? OR addressee_id = ?) AND status = ?", userId, userId, models.FriendshipAccepted).
+		Find(&friendships)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	friendIds := make([]uint, 0, len(friendships))
+	for _, friendship := range friendships {
+		friendIds = append(friendIds, friendship.Other(userId))
+	}
+
+	return friendIds, nil
+}
+
+// BlockedIds returns the users the user blocked or was blocked by
+func (ctx *FriendshipRepoContext) BlockedIds(userId uint) ([]uint, error) {
+	var friendships []models.Friendship
+	result := ctx.db.Where("(requester_id = ? OR addressee_id = ?) AND status = ?", userId, userId, models.FriendshipBlocked).
+		Find(&friendships)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	blockedIds := make([]uint, 0, len(friendships))
+	for _, friendship := range friendships {
+		blockedIds = append(blockedIds, friendship.Other(userId))
+	}
+
+	return blockedIds, nil
+}
+
+func (ctx *FriendshipRepoContext) Save(friendship models.Friendship) (*models.Friendship, error) {
+	result := ctx.db.Create(&friendship)
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &friendship, nil
+}
+
+func (ctx *FriendshipRepoContext) UpdateStatus(id uint, status string) error {
+	result := ctx.db.Model(&models.Friendship{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorFriendshipNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the friendship for good so that the pair can start over
+func (ctx *FriendshipRepoContext) Delete(id uint) error {
+	result := ctx.db.Unscoped().Delete(&models.Friendship{}, id)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrorIPBanNotFound = errors.New("ip ban not found")
+)
+
+type IPBanRepo interface {
+	ListActive() ([]models.IPBan, error)
+	Save(ban models.IPBan) (*models.IPBan, error)
+	Delete(ip string) error
+}
+
+type IPBanRepoContext struct {
+	db *gorm.DB
+}
+
+func NewIPBanRepoContext(db *gorm.DB) *IPBanRepoContext {
+	return &IPBanRepoContext{
+		db: db,
+	}
+}
+
+// ListActive returns the bans that haven't expired yet
+func (ctx *IPBanRepoContext) ListActive() ([]models.IPBan, error) {
+	bans := []models.IPBan{}
+	result := ctx.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&bans)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return bans, nil
+}
+
+// Save bans the IP, replacing its previous ban
+func (ctx *IPBanRepoContext) Save(ban models.IPBan) (*models.IPBan, error) {
+	result := ctx.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ip"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "banned_by", "expires_at", "updated_at"}),
+	}).Create(&ban)
+
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &ban, nil
+}
+
+func (ctx *IPBanRepoContext) Delete(ip string) error {
+	result := ctx.db.Unscoped().Where("ip = ?", ip).Delete(&models.IPBan{})
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorIPBanNotFound
+	}
+
+	return nil
+}
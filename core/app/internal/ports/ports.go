@@ -3,13 +3,34 @@ package repositories
 import "gorm.io/gorm"
 
 type Repositories struct {
-	User UserRepoContext
+	User         UserRepoContext
+	Avatar       AvatarRepoContext
+	RefreshToken RefreshTokenRepoContext
+	UserToken    UserTokenRepoContext
+	Friendship   FriendshipRepoContext
+	Report       ReportRepoContext
+	IPBan        IPBanRepoContext
+	Announcement AnnouncementRepoContext
 }
 
 func InitializeRepositories(db *gorm.DB) (*Repositories, error) {
 	userRepo := NewUserRepoContext(db)
+	avatarRepo := NewAvatarRepoContext(db)
+	refreshTokenRepo := NewRefreshTokenRepoContext(db)
+	userTokenRepo := NewUserTokenRepoContext(db)
+	friendshipRepo := NewFriendshipRepoContext(db)
+	reportRepo := NewReportRepoContext(db)
+	ipBanRepo := NewIPBanRepoContext(db)
+	announcementRepo := NewAnnouncementRepoContext(db)
 
 	return &Repositories{
-		User: *userRepo,
+		User:         *userRepo,
+		Avatar:       *avatarRepo,
+		RefreshToken: *refreshTokenRepo,
+		UserToken:    *userTokenRepo,
+		Friendship:   *friendshipRepo,
+		Report:       *reportRepo,
+		IPBan:        *ipBanRepo,
+		Announcement: *announcementRepo,
 	}, nil
 }
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrorRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrorFailedRevoke         = errors.New("failed revoking")
+)
+
+type RefreshTokenRepo interface {
+	GetByJTI(jti string) (*models.RefreshToken, error)
+	Save(token models.RefreshToken) (*models.RefreshToken, error)
+	Rotate(jti string, replacedBy string) (bool, error)
+	RevokeSession(sessionId string) error
+	RevokeAllByUserId(userId uint) error
+	IsSessionActive(sessionId string) bool
+	GetActiveBySession(sessionId string) (*models.RefreshToken, error)
+}
+
+type RefreshTokenRepoContext struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepoContext(db *gorm.DB) *RefreshTokenRepoContext {
+	return &RefreshTokenRepoContext{
+		db: db,
+	}
+}
+
+func (ctx *RefreshTokenRepoContext) GetByJTI(jti string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	result := ctx.db.First(&token, "jti = ?", jti)
+	if result.Error != nil {
+		return nil, ErrorRefreshTokenNotFound
+	}
+
+	return &token, nil
+}
+
+func (ctx *RefreshTokenRepoContext) Save(token models.RefreshToken) (*models.RefreshToken, error) {
+	result := ctx.db.Create(&token)
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &token, nil
+}
+
+// Rotate revokes the token in favor of its replacement. It reports false when
+// the token was already revoked, which means it's being reused.
+func (ctx *RefreshTokenRepoContext) Rotate(jti string, replacedBy string) (bool, error) {
+	result := ctx.db.Model(&models.RefreshToken{}).
+		Where("jti = ? AND revoked_at IS NULL", jti).
+		Updates(map[string]interface{}{
+			"revoked_at":  time.Now(),
+			"replaced_by": replacedBy,
+		})
+
+	if result.Error != nil {
+		return false, ErrorFailedSave
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (ctx *RefreshTokenRepoContext) RevokeSession(sessionId string) error {
+	result := ctx.db.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionId).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return ErrorFailedRevoke
+	}
+
+	return nil
+}
+
+func (ctx *RefreshTokenRepoContext) RevokeAllByUserId(userId uint) error {
+	result := ctx.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return ErrorFailedRevoke
+	}
+
+	return nil
+}
+
+// IsSessionActive reports whether the session still has a usable refresh
+// token, sessions are inactive once logged out or revoked
+func (ctx *RefreshTokenRepoContext) IsSessionActive(sessionId string) bool {
+	var count int64
+	ctx.db.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionId, time.Now()).
+		Count(&count)
+
+	return count > 0
+}
+
+// GetActiveBySession returns the usable refresh token of the session, the
+// session ends when it expires
+func (ctx *RefreshTokenRepoContext) GetActiveBySession(sessionId string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	result := ctx.db.
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionId, time.Now()).
+		Order("expires_at DESC").
+		First(&token)
+
+	if result.Error != nil {
+		return nil, ErrorRefreshTokenNotFound
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrorReportNotFound = errors.New("report not found")
+)
+
+type ReportRepo interface {
+	GetById(id uint) (*models.Report, error)
+	List(status string, limit int, offset int) ([]models.Report, int64, error)
+	Save(report models.Report) (*models.Report, error)
+	Update(report *models.Report) error
+}
+
+type ReportRepoContext struct {
+	db *gorm.DB
+}
+
+func NewReportRepoContext(db *gorm.DB) *ReportRepoContext {
+	return &ReportRepoContext{
+		db: db,
+	}
+}
+
+func (ctx *ReportRepoContext) GetById(id uint) (*models.Report, error) {
+	var report models.Report
+	result := ctx.db.First(&report, "id = ?", id)
+	if result.Error != nil {
+		return nil, ErrorReportNotFound
+	}
+
+	return &report, nil
+}
+
+// List returns a page of the reports with the status, every report when it's
+// empty, oldest first, along with the total count
+func (ctx *ReportRepoContext) List(status string, limit int, offset int) ([]models.Report, int64, error) {
+	query := ctx.db.Model(&models.Report{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	reports := []models.Report{}
+	result := query.Order("created_at ASC").Limit(limit).Offset(offset).Find(&reports)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return reports, total, nil
+}
+
+func (ctx *ReportRepoContext) Save(report models.Report) (*models.Report, error) {
+	result := ctx.db.Create(&report)
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &report, nil
+}
+
+func (ctx *ReportRepoContext) Update(report *models.Report) error {
+	if err := ctx.db.Save(report).Error; err != nil {
+		return ErrorFailedSave
+	}
+
+	return nil
+}
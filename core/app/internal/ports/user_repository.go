@@ -5,22 +5,24 @@ import (
 	"core/internal/core"
 	"core/types"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 var (
-	ErrorEmailNotFound  = errors.New("email not found")
-	ErrorUserIdNotFound = errors.New("user id not found")
-	ErrorFailedSave     = errors.New("failed saving")
-	ErrorUsernameExists = errors.New("username already exists")
+	ErrorEmailNotFound    = errors.New("email not found")
+	ErrorUserIdNotFound   = errors.New("user id not found")
+	ErrorUsernameNotFound = errors.New("username not found")
+	ErrorFailedSave       = errors.New("failed saving")
+	ErrorUsernameExists   = errors.New("username already exists")
 )
 
 type UserRepo interface {
 	GetByEmail(email string) (*models.User, error)
 	GetById(id string) (*models.User, error)
 	ExistsEmail(email string) bool
-	ExistsUsername(username string) bool
+	IsUsernameTaken(username string) bool
 	Save(user models.User) (*models.User, error)
 }
 
@@ -44,6 +46,31 @@ func (ctx *UserRepoContext) GetById(id float64) (*models.User, error) {
 	return &user, nil
 }
 
+func (ctx *UserRepoContext) GetByUsername(username string) (*models.User, error) {
+	var user models.User
+	result := ctx.db.First(&user, "username = ?", username)
+	if result.Error != nil {
+		return nil, ErrorUsernameNotFound
+	}
+
+	return &user, nil
+}
+
+// GetByIds returns the users found, missing and deleted users are skipped
+func (ctx *UserRepoContext) GetByIds(ids []uint) ([]models.User, error) {
+	users := []models.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	result := ctx.db.Find(&users, ids)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return users, nil
+}
+
 func (ctx *UserRepoContext) GetByEmail(email string) (*models.User, error) {
 	var user models.User
 	result := ctx.db.First(&user, "email = ?", email)
@@ -54,15 +81,20 @@ func (ctx *UserRepoContext) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// * the exists checks include the accounts waiting to be purged, their email
+// * and username are still taken
+
 func (ctx *UserRepoContext) ExistsEmail(email string) bool {
 	var count int64
-	ctx.db.Model(&models.User{}).Where("email = ?", email).Count(&count)
+	ctx.db.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count)
 	return count > 0
 }
 
-func (ctx *UserRepoContext) ExistsUsername(username string) bool {
+// IsUsernameTaken reports whether an account uses the username, ignoring the
+// case so that look-alike names are taken too
+func (ctx *UserRepoContext) IsUsernameTaken(username string) bool {
 	var count int64
-	ctx.db.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	ctx.db.Unscoped().Model(&models.User{}).Where("LOWER(username) = LOWER(?)", username).Count(&count)
 	return count > 0
 }
 
@@ -83,7 +115,7 @@ func (ctx *UserRepoContext) Update(id uint, userUpdates types.UpdateUser) (*mode
 	}
 
 	if userUpdates.UserName != nil {
-		if ctx.ExistsUsername(*userUpdates.UserName) {
+		if ctx.IsUsernameTaken(*userUpdates.UserName) {
 			return nil, ErrorUsernameExists
 		}
 
@@ -97,6 +129,81 @@ func (ctx *UserRepoContext) Update(id uint, userUpdates types.UpdateUser) (*mode
 	return &user, nil
 }
 
+func (ctx *UserRepoContext) UpdatePassword(id uint, passwordHash string) error {
+	result := ctx.db.Model(&models.User{}).Where("id = ?", id).Update("password", passwordHash)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorUserIdNotFound
+	}
+
+	return nil
+}
+
+func (ctx *UserRepoContext) MarkEmailVerified(id uint) error {
+	result := ctx.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", time.Now())
+
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	return nil
+}
+
+// SetBanned bans the user with the reason, or lifts the ban
+func (ctx *UserRepoContext) SetBanned(id uint, banned bool, reason string) error {
+	updates := map[string]interface{}{"banned_at": nil, "ban_reason": ""}
+	if banned {
+		updates = map[string]interface{}{"banned_at": time.Now(), "ban_reason": reason}
+	}
+
+	result := ctx.db.Model(&models.User{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorUserIdNotFound
+	}
+
+	return nil
+}
+
+func (ctx *UserRepoContext) SetRole(id uint, role string) error {
+	result := ctx.db.Model(&models.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorUserIdNotFound
+	}
+
+	return nil
+}
+
+// SetRoleByEmails gives the role to the accounts of the emails, ignoring the
+// case, and returns how many accounts changed
+func (ctx *UserRepoContext) SetRoleByEmails(emails []string, role string) (int64, error) {
+	if len(emails) == 0 {
+		return 0, nil
+	}
+
+	result := ctx.db.Model(&models.User{}).
+		Where("LOWER(email) IN ? AND role <> ?", emails, role).
+		Update("role", role)
+
+	if result.Error != nil {
+		return 0, ErrorFailedSave
+	}
+
+	return result.RowsAffected, nil
+}
+
 func (ctx *UserRepoContext) Save(user models.User) (*models.User, error) {
 	result := ctx.db.Create(&user)
 	if result.Error != nil {
@@ -105,3 +212,56 @@ func (ctx *UserRepoContext) Save(user models.User) (*models.User, error) {
 
 	return &user, nil
 }
+
+// SoftDelete hides the user, the row is kept until PurgeDeletedBefore
+func (ctx *UserRepoContext) SoftDelete(id uint) error {
+	result := ctx.db.Delete(&models.User{}, id)
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrorUserIdNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeletedBefore hard deletes the users soft deleted before t along with
+// the rows that belong to them, returning how many users were purged
+func (ctx *UserRepoContext) PurgeDeletedBefore(t time.Time) (int, error) {
+	var userIds []uint
+	result := ctx.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", t).
+		Pluck("id", &userIds)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	if len(userIds) == 0 {
+		return 0, nil
+	}
+
+	err := ctx.db.Transaction(func(tx *gorm.DB) error {
+		owned := []interface{}{&models.Avatar{}, &models.RefreshToken{}, &models.UserToken{}}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id IN ?", userIds).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Unscoped().Where("requester_id IN ? OR addressee_id IN ?", userIds, userIds).Delete(&models.Friendship{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.User{}, userIds).Error
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(userIds), nil
+}
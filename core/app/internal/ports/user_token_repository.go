@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"core/internal/adapters/database/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrorUserTokenNotFound = errors.New("token not found")
+)
+
+type UserTokenRepo interface {
+	Save(token models.UserToken) (*models.UserToken, error)
+	Consume(tokenHash string, purpose string) (*models.UserToken, error)
+	DeleteByUserId(userId uint, purpose string) error
+}
+
+type UserTokenRepoContext struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepoContext(db *gorm.DB) *UserTokenRepoContext {
+	return &UserTokenRepoContext{
+		db: db,
+	}
+}
+
+func (ctx *UserTokenRepoContext) Save(token models.UserToken) (*models.UserToken, error) {
+	result := ctx.db.Create(&token)
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	return &token, nil
+}
+
+// Consume marks an unused and unexpired token as used and returns it
+func (ctx *UserTokenRepoContext) Consume(tokenHash string, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	result := ctx.db.First(&token, "token_hash = ? AND purpose = ?", tokenHash, purpose)
+	if result.Error != nil {
+		return nil, ErrorUserTokenNotFound
+	}
+
+	now := time.Now()
+	result = ctx.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return nil, ErrorFailedSave
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrorUserTokenNotFound
+	}
+
+	token.UsedAt = &now
+
+	return &token, nil
+}
+
+// DeleteByUserId invalidates the pending tokens of the user for purpose
+func (ctx *UserTokenRepoContext) DeleteByUserId(userId uint, purpose string) error {
+	result := ctx.db.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		Delete(&models.UserToken{})
+
+	if result.Error != nil {
+		return ErrorFailedSave
+	}
+
+	return nil
+}
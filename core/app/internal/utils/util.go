@@ -7,9 +7,14 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 	"sync"
 )
 
+const (
+	maxSlugLen = 40
+)
+
 func ConvertMapToSlice(usersPositions []string) []types.Position {
 	positions := []types.Position{}
 
@@ -31,6 +36,36 @@ func GetRandomId() (string, error) {
 	return rId.String(), nil
 }
 
+// Slugify turns a name into a lowercase URL friendly string, e.g. "Keep the
+// block HOT!" => "keep-the-block-hot"
+func Slugify(name string) string {
+	var builder strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			builder.WriteRune(r)
+			dash = false
+		case builder.Len() > 0 && !dash:
+			builder.WriteRune('-')
+			dash = true
+		}
+	}
+
+	slug := builder.String()
+	if len(slug) > maxSlugLen {
+		slug = slug[:maxSlugLen]
+	}
+
+	slug = strings.TrimSuffix(slug, "-")
+	if slug == "" {
+		return "room"
+	}
+
+	return slug
+}
+
 func ReadFileLines(src string, callback func(string)) error {
 	file, err := os.Open(src)
 	if err != nil {
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "lowercases and joins words", in: "Keep the block HOT!", want: "keep-the-block-hot"},
+		{name: "collapses separators", in: "rock  --  roll", want: "rock-roll"},
+		{name: "trims separators", in: "  ~hello world~  ", want: "hello-world"},
+		{name: "keeps digits", in: "Room 42", want: "room-42"},
+		{name: "drops non ascii letters", in: "café olé", want: "caf-ol"},
+		{name: "only symbols", in: "!!!", want: "room"},
+		{name: "empty", in: "", want: "room"},
+		{name: "truncated", in: strings.Repeat("a", maxSlugLen+10), want: strings.Repeat("a", maxSlugLen)},
+		{name: "no trailing dash once truncated", in: strings.Repeat("a", maxSlugLen-1) + " b", want: strings.Repeat("a", maxSlugLen-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slugify(tt.in); got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package types
+
+// AccountID returns the account of the client, it's safe to call from any
+// goroutine while a guest signs in
+func (mc *MessageClient) AccountID() uint {
+	mc.identityMu.RLock()
+	defer mc.identityMu.RUnlock()
+
+	return mc.Client.AccountID
+}
+
+// SessionID returns the login session the client authenticated with, empty
+// for guests
+func (mc *MessageClient) SessionID() string {
+	mc.identityMu.RLock()
+	defer mc.identityMu.RUnlock()
+
+	return mc.Session.SessionID
+}
+
+// SetIdentity gives the client of a guest connection the session it signed in
+// with
+func (mc *MessageClient) SetIdentity(session Session, avatar Avatar) {
+	mc.identityMu.Lock()
+	defer mc.identityMu.Unlock()
+
+	*mc.Session = session
+	mc.Client.AccountID = session.AccountID
+	mc.Client.Username = session.Username
+	mc.Client.Avatar = avatar
+	mc.Client.Role = session.Role
+}
@@ -0,0 +1,71 @@
+package types
+
+import "sync"
+
+// MessageFilter holds what a connection doesn't want to receive. Blocks are
+// between accounts and come from the database, mutes only last as long as
+// the connection.
+type MessageFilter struct {
+	mu      sync.RWMutex
+	blocked map[uint]struct{}
+	muted   map[UserID]struct{}
+}
+
+// SetBlocked replaces the blocked accounts, in either direction
+func (f *MessageFilter) SetBlocked(accountIds []uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.blocked = make(map[uint]struct{}, len(accountIds))
+	for _, accountId := range accountIds {
+		f.blocked[accountId] = struct{}{}
+	}
+}
+
+func (f *MessageFilter) Block(accountId uint, blocked bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.blocked == nil {
+		f.blocked = make(map[uint]struct{})
+	}
+
+	if blocked {
+		f.blocked[accountId] = struct{}{}
+	} else {
+		delete(f.blocked, accountId)
+	}
+}
+
+func (f *MessageFilter) Mute(userId UserID, muted bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.muted == nil {
+		f.muted = make(map[UserID]struct{})
+	}
+
+	if muted {
+		f.muted[userId] = struct{}{}
+	} else {
+		delete(f.muted, userId)
+	}
+}
+
+// Rejects reports whether messages from sender must not be delivered
+func (f *MessageFilter) Rejects(sender Sender) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if _, muted := f.muted[sender.UserID]; muted {
+		return true
+	}
+
+	if sender.AccountID == 0 {
+		return false
+	}
+
+	_, blocked := f.blocked[sender.AccountID]
+
+	return blocked
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -26,32 +27,71 @@ const (
 	BackRight  FacingDirection = 2
 
 	DefaultDirection        = FrontLeft
-	RoomIdFormat     string = "%s#%s" // e.g. "my room#334288"
+	RoomIdFormat     string = "%s#%s" // e.g. "my room#334288", only the welcome room and the rooms created before the uuid ids
 )
 
 // type Avatars map[int]Avatar
 type RoomId string
 
 type User struct {
-	UserName  string
-	UserID    UserID
-	RoomID    string
-	Position  Position
-	Direction FacingDirection
-	IsTyping  bool
+	UserName   string
+	UserID     UserID
+	AccountID  uint // * models.User id, 0 for guests
+	RoomID     string
+	Position   Position
+	Direction  FacingDirection
+	IsTyping   bool
+	Avatar     Avatar
+	Emote      string // * active emote, empty when idle
+	EmoteUntil int64  // * unix ms when the emote ends, 0 lasts until the user moves
+	IsBot      bool
 }
 
 type Client struct {
-	ID       UserID
-	RoomId   RoomId
-	Username string
-	Conn     *websocket.Conn
+	ID          UserID
+	AccountID   uint // * 0 for guests
+	RoomId      RoomId
+	Username    string
+	Avatar      Avatar
+	Conn        *websocket.Conn
+	IsBot       bool   // * server side bot, it has no websocket connection
+	Role        string // * models.User role of the account, empty for guests
+	QueueRoomId RoomId // * full room the user waits for, empty when not queued
+	WatchRoomId RoomId // * room the user spectates, empty when not watching
+}
+
+// Session is the identity a websocket connection authenticated with on the
+// upgrade, either a guest or an account (models.User)
+type Session struct {
+	AccountID uint   // * models.User id, 0 for guests
+	SessionID string // * login session of the tokens, empty for guests and tickets
+	Username  string
+	Role      string    // * models.User role, empty for guests
+	Verified  bool      // * the account's email is verified
+	ExpiresAt time.Time // * zero for guests
+}
+
+func (s *Session) IsGuest() bool {
+	return s.AccountID == 0
+}
+
+type Avatar struct {
+	Body           string   `json:"body" example:"ghost"`
+	PrimaryColor   string   `json:"primaryColor" example:"#f5f5f5"`
+	SecondaryColor string   `json:"secondaryColor" example:"#7c3aed"`
+	Accessories    []string `json:"accessories" example:"hat"`
 }
 
 type MessageClient struct {
-	Client *Client
-	Send   chan []byte
-	ConnMu sync.Mutex
+	Client      *Client
+	Session     *Session
+	Subprotocol string // * negotiated on the upgrade, encoded with wire.FromSubprotocol
+	Send        chan []byte
+	ConnMu      sync.Mutex
+	Filter      MessageFilter // * blocked accounts and muted users, applied on delivery
+	IP          string        // * remote address of the upgrade request
+
+	identityMu sync.RWMutex // * guards the account fields of Client, see SetIdentity
 }
 
 type Room struct {
@@ -77,23 +117,58 @@ type UserLeave struct {
 // }
 
 type Middlewares struct {
-	Auth gin.HandlerFunc
-	CSRF gin.HandlerFunc
+	Auth      gin.HandlerFunc
+	CSRF      gin.HandlerFunc
+	Verified  gin.HandlerFunc // * must run after Auth
+	Moderator gin.HandlerFunc // * must run after Auth
+	Admin     gin.HandlerFunc // * must run after Auth
 }
 
 type RoomData struct {
 	Name           string
+	Slug           string // * unique URL friendly alias of the room id
 	Users          []User
 	UsersPositions []string // * e.g. "Row, Col" => "1,2", "3,4", ...
 	UserIdxMap     map[UserID]UserIdx
 	Password       *string
 	IsProtected    bool
+	Layout         *RoomLayout
+	OwnerID        uint   // * models.User id of the account that created the room, 0 for guests
+	CreatedAt      int64  // timestamp
+	WelcomeText    string // * sent to the users joining, config.RoomWelcomeText when empty
+	Description    string
+	Topic          string           // * set by the owner with /topic
+	Tags           []string         // * from services.RoomTags
+	Visibility     string           // * RoomPublic when empty
+	QueueSkip      bool             // * the owner and its friends go first in the queue
+	Persistent     bool             // * not deleted once empty, closed by its owner or an admin
+	Reservations   map[UserID]int64 // * users admitted from the queue, unix time until which their slot is held
+}
+
+const (
+	RoomPublic   = "public"   // * listed in the directory
+	RoomUnlisted = "unlisted" // * joined with the room id, and the password when it has one
+	RoomPrivate  = "private"  // * joined with an invite of the owner
+)
+
+// IsListed reports whether the room is shown in the directory
+func (room *RoomData) IsListed() bool {
+	return room.Visibility == "" || room.Visibility == RoomPublic
+}
+
+type RoomLayout struct {
+	Seats []string // * e.g. "Row, Col" => "1,2", "3,4", ...
 }
 
 type UpdateUser struct {
-	RoomId   *string `json:"roomId"`
-	UserName *string `json:"username"`
-	Password *string `json:"password"`
+	RoomId      *string `json:"roomId"`
+	UserName    *string `json:"username"`
+	Password    *string `json:"password"`
+	Avatar      *Avatar `json:"-"`
+	AccountID   *uint   `json:"-"`
+	Role        *string `json:"-"`
+	QueueRoomId *string `json:"-"`
+	WatchRoomId *string `json:"-"`
 }
 
 type UpdateUserPos struct {
@@ -108,26 +183,49 @@ type UpdateUserTyping struct {
 	IsTyping bool   `json:"isTyping"`
 }
 
+type UserEmote struct {
+	Emote string `json:"emote"`
+}
+
 type UpdateUserFacingDir struct {
 	Dest string `json:"dest"` // "row,col" => e.g. "3,4", "1,3", ...
 }
 
 type NewRoom struct {
-	UserName string  `json:"userName"`
-	RoomName string  `json:"roomName"`
-	Password *string `json:"password"`
+	UserName    string   `json:"userName"`
+	RoomName    string   `json:"roomName"`
+	Password    *string  `json:"password"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Visibility  string   `json:"visibility"` // * public when empty
+	QueueSkip   bool     `json:"queueSkip"`
+	Seats       []string `json:"seats"`      // * "row,col" tiles where users can sit, anywhere when empty
+	Persistent  bool     `json:"persistent"` // * kept once empty, verified accounts only
 }
 
 type JoinRoom struct {
 	RoomId   RoomId  `json:"roomId"`
 	UserName string  `json:"userName"`
 	Password *string `json:"password"`
+	Invite   string  `json:"invite"` // * invite token, in place of the password
+}
+
+// CreateInvite asks for an invite to a room, the current one when RoomId is
+// empty
+type CreateInvite struct {
+	RoomId    RoomId `json:"roomId"`
+	ExpiresIn int    `json:"expiresIn"` // minutes, 0 is the default expiration
+}
+
+// Authenticate signs a guest connection in with a ticket from
+// POST /api/v1/ws/ticket
+type Authenticate struct {
+	Ticket string `json:"ticket"`
 }
 
 type WsPayload struct {
-	Event         string      `json:"Event"`
-	Authorization string      `json:"Authorization"`
-	Data          interface{} `json:"Data"`
+	Event string      `json:"Event"`
+	Data  interface{} `json:"Data"`
 }
 
 type Msg struct {
@@ -142,16 +240,203 @@ type DirectMsg struct {
 }
 
 type PopularRoomList struct {
-	RoomId      RoomId `json:"roomId"`
-	RoomName    string `json:"roomName"`
-	TotalConns  int    `json:"totalConns"`
-	IsProtected bool   `json:"isProtected"`
+	RoomId      RoomId   `json:"roomId"`
+	Slug        string   `json:"slug"`
+	RoomName    string   `json:"roomName"`
+	TotalConns  int      `json:"totalConns"`
+	IsProtected bool     `json:"isProtected"`
+	Description string   `json:"description"`
+	Topic       string   `json:"topic"`
+	Tags        []string `json:"tags"`
+}
+
+const (
+	ControlDisconnectAccount = "disconnectAccount"
+	ControlDeliverAccounts   = "deliverAccounts"
+	ControlAccountBlocked    = "accountBlocked"
+	ControlCloseRoom         = "closeRoom"
+	ControlDisconnectIP      = "disconnectIp"
+	ControlKickUser          = "kickUser"
+	ControlDeliverUser       = "deliverUser"
+	ControlDisconnectSession = "disconnectSession"
+)
+
+// ControlMessage is published to every node through the control channel
+type ControlMessage struct {
+	Type            string     `json:"type"`
+	AccountID       uint       `json:"accountId,omitempty"`
+	AccountIDs      []uint     `json:"accountIds,omitempty"`
+	TargetAccountID uint       `json:"targetAccountId,omitempty"` // * account blocked by AccountID
+	Blocked         bool       `json:"blocked,omitempty"`         // * false when TargetAccountID was unblocked
+	RoomId          RoomId     `json:"roomId,omitempty"`
+	UserID          UserID     `json:"userId,omitempty"` // * user kicked from RoomId or to deliver Payload to
+	SessionID       string     `json:"sessionId,omitempty"`
+	IP              string     `json:"ip,omitempty"`
+	Reason          string     `json:"reason,omitempty"`
+	Payload         *WsPayload `json:"payload,omitempty"` // * event sent to the connections of AccountIDs or UserID
+}
+
+// Sender identifies who a room message is from so that every recipient can
+// filter it on delivery
+type Sender struct {
+	UserID    UserID
+	AccountID uint // * 0 for guests
+}
+
+type MuteUser struct {
+	UserID UserID `json:"userId"`
+}
+
+const (
+	SystemScopeRoom   = "room"
+	SystemScopeGlobal = "global" // * sent to every room
+	SystemScopeUser   = "user"   // * only sent to the user, e.g. the /help output
+)
+
+// SystemMessage is a message of the server, not of a user
+type SystemMessage struct {
+	Msg    string `json:"msg"`
+	From   string `json:"from"`
+	Scope  string `json:"scope"`
+	SentAt int64  `json:"sentAt"` // timestamp
+}
+
+type RoomClosed struct {
+	RoomId RoomId `json:"roomId"`
+	Reason string `json:"reason"`
+}
+
+// LeaveQueue has no data, the user leaves the queue it's in
+type LeaveQueue struct{}
+
+type QueuePosition struct {
+	RoomId   RoomId `json:"roomId"`
+	Position int    `json:"position"` // * 1 is the next one admitted
+}
+
+// QueueReady tells a queued user that a slot of the room is held for it
+type QueueReady struct {
+	RoomId    RoomId `json:"roomId"`
+	ExpiresIn int    `json:"expiresIn"` // seconds to join before the slot goes to the next user
+}
+
+// WatchRoom subscribes to the scene and the chat of a room without joining it,
+// the access rules are the ones of JoinRoom
+type WatchRoom struct {
+	RoomId   RoomId  `json:"roomId"`
+	Password *string `json:"password"`
+	Invite   string  `json:"invite"`
+}
+
+// UnwatchRoom has no data, the user stops watching the room it watches
+type UnwatchRoom struct{}
+
+// RoomOccupancy is sent to the room when spectators come and go, and to the
+// user that starts watching
+type RoomOccupancy struct {
+	RoomId         RoomId `json:"roomId"`
+	Users          int    `json:"users"`
+	UserLimit      int    `json:"userLimit"`
+	Spectators     int    `json:"spectators"`
+	SpectatorLimit int    `json:"spectatorLimit"`
+}
+
+type RoomKicked struct {
+	RoomId RoomId `json:"roomId"`
+	By     string `json:"by"` // * username of who kicked the user
+}
+
+type RoomTopic struct {
+	RoomId RoomId `json:"roomId"`
+	Topic  string `json:"topic"`
+	By     string `json:"by,omitempty"` // * username of who set it, empty when it's sent on join
+}
+
+// HistoryEntry is a chat message kept in the room history, whispers have a
+// recipient
+type HistoryEntry struct {
+	UserID      UserID `json:"userId"`
+	AccountID   uint   `json:"accountId,omitempty"`
+	Username    string `json:"username"`
+	Msg         string `json:"msg"`
+	To          UserID `json:"to,omitempty"`
+	ToAccountID uint   `json:"toAccountId,omitempty"`
+	SentAt      int64  `json:"sentAt"` // timestamp
+}
+
+func (entry *HistoryEntry) IsWhisper() bool {
+	return len(entry.To) > 0
+}
+
+type ReportUser struct {
+	UserID  UserID `json:"userId"`
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// Presence is where an account is, an account with several connections is
+// shown in the room of the first one that is in a room
+type Presence struct {
+	AccountID uint   `json:"accountId"`
+	Username  string `json:"username"`
+	Online    bool   `json:"online"`
+	RoomId    RoomId `json:"roomId,omitempty"`
+	RoomName  string `json:"roomName,omitempty"`
+}
+
+type FriendsPresence struct {
+	Friends []Presence `json:"friends"`
+}
+
+type JoinFriend struct {
+	AccountID uint    `json:"accountId"`
+	Password  *string `json:"password"`
 }
 
 type ApiResponse map[string]any
 
+// * codes of the api error responses
+const (
+	ErrorCodeBadRequest      = "bad_request"
+	ErrorCodeValidation      = "validation_failed"
+	ErrorCodeUnauthorized    = "unauthorized"
+	ErrorCodeForbidden       = "forbidden"
+	ErrorCodeNotFound        = "not_found"
+	ErrorCodeTooManyRequests = "too_many_requests"
+	ErrorCodeInternal        = "internal_error"
+)
+
+// ErrorBody is the shape of every error response:
+// {"error": {"code": "...", "message": "...", "fields": {...}}}
+type ErrorBody struct {
+	Code    string            `json:"code" example:"bad_request"`
+	Message string            `json:"message" example:"invalid/missing params"`
+	Fields  map[string]string `json:"fields,omitempty"` // * field name => validation error
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
 func ApiError(err error) ApiResponse {
+	return ApiErrorCode(ErrorCodeBadRequest, err)
+}
+
+func ApiErrorCode(code string, err error) ApiResponse {
+	return ApiResponse{
+		"error": ErrorBody{
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
+
+func ApiValidationError(err error, fields map[string]string) ApiResponse {
 	return ApiResponse{
-		"error": err.Error(),
+		"error": ErrorBody{
+			Code:    ErrorCodeValidation,
+			Message: err.Error(),
+			Fields:  fields,
+		},
 	}
 }
@@ -65,7 +65,7 @@ export const Account = () => {
     if (!data) {
       setError("Something went wrong");
     } else {
-      setError(data.error);
+      setError(data.error?.message ?? "Something went wrong");
     }
   }, [updateError]);
 
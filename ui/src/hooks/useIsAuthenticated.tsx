@@ -26,7 +26,7 @@ export const useIsAuthenticated = () => {
     if (tokenExp < now) {
       try {
         // * cookies are sent automagically
-        const res: any = await api.get(apiRoutes.refresh);
+        const res: any = await api.post(apiRoutes.refresh, {});
 
         if (res.status === 200) {
           setIsAuthenticated(true);
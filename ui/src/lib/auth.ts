@@ -4,7 +4,7 @@ import { getCookie } from "./misc";
 
 type JwtPayload = {
   exp: number;
-  sub: number;
+  sub: string;
   username: string;
 };
 
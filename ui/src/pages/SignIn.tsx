@@ -43,7 +43,7 @@ export const SignIn = () => {
     if (!data) {
       setError("Something went wrong");
     } else {
-      setError(data.error);
+      setError(data.error?.message ?? "Something went wrong");
     }
   }, [doSigninError]);
 
@@ -56,7 +56,7 @@ export const SignUp = () => {
     if (!data) {
       setError("Something went wrong");
     } else {
-      setError(data.error);
+      setError(data.error?.message ?? "Something went wrong");
     }
   }, [doSignupError]);
 